@@ -0,0 +1,105 @@
+// Package carsclient is a Go client of the cars REST API served by
+// client-rest. The types and the Client methods are generated from
+// openapi.yaml, the document client-rest serves under /openapi.yaml.
+package carsclient
+
+//go:generate go run ./cmd/generate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client sends requests to the cars API at BaseURL, e.g.
+// http://localhost:10000. Requests carry APIKey in X-API-Key, or Token as a
+// bearer token, when set.
+type Client struct {
+	BaseURL		string
+	HTTPClient	*http.Client
+	APIKey		string
+	Token		string
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{ BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient }
+}
+
+// Error is a request the API answered with an ErrorResponse.
+type Error struct {
+	StatusCode	int
+	ErrorResponse
+}
+
+func (e *Error) Error() string {
+	if e.ChaincodeError != "" && !strings.Contains(e.Message, e.ChaincodeError) {
+		return fmt.Sprintf("%s (%s)", e.Message, e.ChaincodeError)
+	}
+
+	return e.Message
+}
+
+// do sends a request with body encoded as JSON, when it is not nil, and
+// decodes the answer into result, when it is not nil.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, result interface{}) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		request.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer " + c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		apiError := &Error{ StatusCode: response.StatusCode }
+		err := json.NewDecoder(response.Body).Decode(&apiError.ErrorResponse)
+		if err != nil || apiError.Message == "" {
+			apiError.Message = fmt.Sprintf("%s %s answered %s", method, path, response.Status)
+		}
+		return apiError
+	}
+
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
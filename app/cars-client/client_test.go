@@ -0,0 +1,87 @@
+package carsclient
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/cars-client/internal/generate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedUpToDate(t *testing.T) {
+	spec, err := ioutil.ReadFile("openapi.yaml")
+	require.NoError(t, err)
+
+	files, err := generate.Files(spec)
+	require.NoError(t, err)
+
+	for name, source := range files {
+		current, err := ioutil.ReadFile(name)
+		require.NoError(t, err)
+		require.Equal(t, string(source), string(current), "%s is out of date, run go generate", name)
+	}
+}
+
+func TestClientBuyCar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/ledger/cars/c 1/purchase", r.URL.Path)
+		require.Equal(t, "true", r.URL.Query().Get("async"))
+		require.Equal(t, "key", r.Header.Get("X-API-Key"))
+
+		var request PurchaseRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		require.Equal(t, PurchaseRequest{ Buyer: "2", AcceptMalfunctions: true }, request)
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(TransactionStatus{ TxID: "tx1", Function: "TransferOwnership", Status: TransactionStatusStatusSubmitted })
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL + "/")
+	client.APIKey = "key"
+
+	status, err := client.BuyCarAsync(context.Background(), "c 1", PurchaseRequest{ Buyer: "2", AcceptMalfunctions: true })
+	require.NoError(t, err)
+	require.Equal(t, "tx1", status.TxID)
+	require.Equal(t, TransactionStatusStatusSubmitted, status.Status)
+}
+
+func TestClientQueryCars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "brand=Audi&includeWrittenOff=true&minYear=2010", r.URL.RawQuery)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		json.NewEncoder(w).Encode(CarsPage{ Cars: []Car{ { ID: "c1", Brand: "Audi", Year: 2015 } }, FetchedRecordsCount: 1 })
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.Token = "token"
+
+	page, err := client.QueryCars(context.Background(), &QueryCarsParams{ Brand: "Audi", MinYear: 2010, IncludeWrittenOff: true })
+	require.NoError(t, err)
+	require.Equal(t, int32(1), page.FetchedRecordsCount)
+	require.Equal(t, "c1", page.Cars[0].ID)
+}
+
+func TestClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{ Code: ErrorResponseCodeNotFound, Message: "Failed to get car: Car c9 does not exist!" })
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).GetCar(context.Background(), "c9")
+	require.Error(t, err)
+
+	apiError, ok := err.(*Error)
+	require.True(t, ok)
+	require.Equal(t, http.StatusNotFound, apiError.StatusCode)
+	require.Equal(t, ErrorResponseCodeNotFound, apiError.Code)
+	require.Equal(t, "Failed to get car: Car c9 does not exist!", apiError.Error())
+}
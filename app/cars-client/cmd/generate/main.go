@@ -0,0 +1,29 @@
+// Command generate writes spec.go and generated.go of the cars client from
+// openapi.yaml. It runs in the module directory through go generate.
+package main
+
+import (
+	"io/ioutil"
+	"log"
+
+	"github.com/hyperledger/fabric-samples/cars-client/internal/generate"
+)
+
+func main() {
+	spec, err := ioutil.ReadFile("openapi.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	files, err := generate.Files(spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for name, source := range files {
+		err := ioutil.WriteFile(name, source, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
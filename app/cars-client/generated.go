@@ -0,0 +1,1048 @@
+// Code generated by go run ./cmd/generate from openapi.yaml. DO NOT EDIT.
+
+package carsclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type AmountRequest struct {
+	// An amount such as 1500 or 1500.50 EUR.
+	Amount string
+}
+
+type BlockTransaction struct {
+	TxID           string
+	ValidationCode string
+}
+
+type Car struct {
+	ID           string
+	Brand        string
+	Model        string
+	Year         int
+	Color        string
+	Owner        string
+	Malfunctions []Malfunction
+	Price        Money
+	Status       string
+}
+
+// Values of Car.Status.
+const (
+	CarStatusActive     = "active"
+	CarStatusScrapped   = "scrapped"
+	CarStatusWrittenOff = "written-off"
+)
+
+// CarPatch is the body of PATCH /ledger/cars/{id}. Missing fields keep their
+// value.
+type CarPatch struct {
+	Brand *string `json:",omitempty"`
+	Model *string `json:",omitempty"`
+	Year  *int    `json:",omitempty"`
+	Price *string `json:",omitempty"`
+	Color *string `json:",omitempty"`
+}
+
+// CarRequest is the body of POST /ledger/cars and of PUT, which takes the id
+// from the path.
+type CarRequest struct {
+	ID    string `json:",omitempty"`
+	Brand string `json:",omitempty"`
+	Model string `json:",omitempty"`
+	Year  int    `json:",omitempty"`
+	Color string `json:",omitempty"`
+	Owner string `json:",omitempty"`
+	// An amount such as 1500 or 1500.50 EUR.
+	Price string `json:",omitempty"`
+}
+
+type CarsPage struct {
+	Cars                []Car
+	FetchedRecordsCount int32
+	Bookmark            string
+}
+
+type ErrorResponse struct {
+	Code    string
+	Message string
+	// The message the chaincode rejected the transaction with.
+	ChaincodeError string `json:",omitempty"`
+	// The transaction, once it reached the orderer.
+	TxID string `json:",omitempty"`
+	// Why the transaction failed.
+	Classification string `json:",omitempty"`
+	// How often the transaction was submitted.
+	Attempts int `json:",omitempty"`
+}
+
+// Values of ErrorResponse.Code.
+const (
+	ErrorResponseCodeBadRequest           = "BAD_REQUEST"
+	ErrorResponseCodeUnauthorized         = "UNAUTHORIZED"
+	ErrorResponseCodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	ErrorResponseCodeForbidden            = "FORBIDDEN"
+	ErrorResponseCodeNotFound             = "NOT_FOUND"
+	ErrorResponseCodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	ErrorResponseCodeGone                 = "GONE"
+	ErrorResponseCodeConflict             = "CONFLICT"
+	ErrorResponseCodeTransactionInvalid   = "TRANSACTION_INVALID"
+	ErrorResponseCodeBadGateway           = "BAD_GATEWAY"
+	ErrorResponseCodeGatewayTimeout       = "GATEWAY_TIMEOUT"
+	ErrorResponseCodeInternalError        = "INTERNAL_ERROR"
+)
+
+// Values of ErrorResponse.Classification.
+const (
+	ErrorResponseClassificationMvccConflict           = "MVCC_CONFLICT"
+	ErrorResponseClassificationPhantomRead            = "PHANTOM_READ"
+	ErrorResponseClassificationEndorsementMismatch    = "ENDORSEMENT_MISMATCH"
+	ErrorResponseClassificationEndorsementUnavailable = "ENDORSEMENT_UNAVAILABLE"
+	ErrorResponseClassificationTimeout                = "TIMEOUT"
+	ErrorResponseClassificationBusinessError          = "BUSINESS_ERROR"
+	ErrorResponseClassificationInvalid                = "INVALID"
+	ErrorResponseClassificationOther                  = "OTHER"
+)
+
+// ListingRequest is the body of PUT /ledger/cars/{id}/listing.
+type ListingRequest struct {
+	// An amount such as 1500 or 1500.50 EUR.
+	AskingPrice string
+	// A duration such as 72h, empty to keep the listing open until it is
+	// withdrawn.
+	ValidFor string `json:",omitempty"`
+}
+
+type Malfunction struct {
+	Description string
+	Price       Money
+}
+
+type MalfunctionRequest struct {
+	Description string
+	Price       string
+}
+
+// Money is an amount in minor units (cents).
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// OfferRequest is the body of POST /ledger/cars/{id}/offers. A car with
+// malfunctions is only bought when AcceptMalfunctions is true.
+type OfferRequest struct {
+	Buyer string
+	// An amount such as 1500 or 1500.50 EUR.
+	Price              string
+	AcceptMalfunctions bool `json:",omitempty"`
+	// A duration such as 24h, empty to keep the offer open until it is withdrawn.
+	ValidFor string `json:",omitempty"`
+}
+
+// Written-off cars are counted in CarCount and WrittenOffCount, but add
+// nothing to MarketValue, OutstandingRepairCost and NetWorth.
+type OwnerSummary struct {
+	PersonID              string
+	CarCount              int
+	WrittenOffCount       int
+	MarketValue           Money
+	OutstandingRepairCost Money
+	Money                 Money
+	NetWorth              Money
+}
+
+type Person struct {
+	ID      string
+	Name    string
+	Surname string
+	Email   string
+	Money   Money
+}
+
+// PersonPatch is the body of PATCH /ledger/persons/{id}. Missing fields keep
+// their value.
+type PersonPatch struct {
+	Name    *string `json:",omitempty"`
+	Surname *string `json:",omitempty"`
+	Email   *string `json:",omitempty"`
+}
+
+// PersonRequest is the body of POST /ledger/persons and of PUT, which takes
+// the id from the path.
+type PersonRequest struct {
+	ID      string `json:",omitempty"`
+	Name    string `json:",omitempty"`
+	Surname string `json:",omitempty"`
+	Email   string `json:",omitempty"`
+}
+
+// A zero ExpiresAt means the offer stays open until it is withdrawn.
+type PurchaseOffer struct {
+	CarID              string
+	Buyer              string
+	Price              Money
+	AcceptMalfunctions bool
+	ExpiresAt          time.Time
+}
+
+// PurchaseRequest is the body of POST /ledger/cars/{id}/purchase, which buys
+// a listed car at its asking price. A car with malfunctions is only bought
+// when AcceptMalfunctions is true.
+type PurchaseRequest struct {
+	Buyer              string
+	AcceptMalfunctions bool `json:",omitempty"`
+}
+
+type RepairOrder struct {
+	ID           string
+	CarID        string
+	Owner        string
+	Mechanic     string
+	Malfunctions []Malfunction
+	Quote        Money
+	Status       string
+	UpdatedAt    time.Time
+}
+
+// Values of RepairOrder.Status.
+const (
+	RepairOrderStatusReported   = "reported"
+	RepairOrderStatusQuoted     = "quoted"
+	RepairOrderStatusApproved   = "approved"
+	RepairOrderStatusInProgress = "in-progress"
+	RepairOrderStatusCompleted  = "completed"
+	RepairOrderStatusCancelled  = "cancelled"
+)
+
+// RepairOrderRequest is the body of POST /ledger/cars/{id}/repair-orders.
+type RepairOrderRequest struct {
+	ID string
+}
+
+// A zero ExpiresAt means the listing stays open until it is withdrawn.
+type SaleListing struct {
+	CarID       string
+	Seller      string
+	AskingPrice Money
+	ExpiresAt   time.Time
+}
+
+// StreamEvent is a chaincode event, or a committed block when Type is block.
+// ID is <block>/<txId> for a chaincode event and <block> for a block.
+type StreamEvent struct {
+	ID          string
+	Type        string
+	Name        string `json:",omitempty"`
+	BlockNumber int64
+	TxID        string `json:",omitempty"`
+	// The JSON payload of the chaincode event.
+	Payload      json.RawMessage    `json:",omitempty"`
+	Transactions []BlockTransaction `json:",omitempty"`
+}
+
+// Values of StreamEvent.Type.
+const (
+	StreamEventTypeChaincode = "chaincode"
+	StreamEventTypeBlock     = "block"
+)
+
+type TransactionStatus struct {
+	TxID     string
+	Function string
+	Status   string
+	// The validation code of the commit, e.g. VALID or MVCC_READ_CONFLICT.
+	ValidationCode string `json:",omitempty"`
+	BlockNumber    int64  `json:",omitempty"`
+	Error          string `json:",omitempty"`
+	Submitted      time.Time
+}
+
+// Values of TransactionStatus.Status.
+const (
+	TransactionStatusStatusEndorsed  = "ENDORSED"
+	TransactionStatusStatusSubmitted = "SUBMITTED"
+	TransactionStatusStatusCommitted = "COMMITTED"
+	TransactionStatusStatusInvalid   = "INVALID"
+)
+
+// InitLedger sends POST /ledger to fill the ledger with the sample persons
+// and cars. Needs an identity with the registry or admin role, and fails with
+// 409 once the ledger has persons or cars.
+func (c *Client) InitLedger(ctx context.Context) error {
+	return c.do(ctx, "POST", "/ledger", nil, nil, nil)
+}
+
+// InitLedgerAsync sends POST /ledger with async=true. It returns the status
+// of the transaction once it is sent to the orderer.
+func (c *Client) InitLedgerAsync(ctx context.Context) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreatePerson sends POST /ledger/persons to register a person.
+func (c *Client) CreatePerson(ctx context.Context, body PersonRequest) (*Person, error) {
+	var result Person
+	err := c.do(ctx, "POST", "/ledger/persons", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreatePersonAsync sends POST /ledger/persons with async=true. It returns
+// the status of the transaction once it is sent to the orderer.
+func (c *Client) CreatePersonAsync(ctx context.Context, body PersonRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/persons", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetPerson sends GET /ledger/persons/{id} to get a person.
+func (c *Client) GetPerson(ctx context.Context, id string) (*Person, error) {
+	var result Person
+	err := c.do(ctx, "GET", "/ledger/persons/"+url.PathEscape(id), nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdatePerson sends PUT /ledger/persons/{id} to replace the details of a
+// person.
+func (c *Client) UpdatePerson(ctx context.Context, id string, body PersonRequest) (*Person, error) {
+	var result Person
+	err := c.do(ctx, "PUT", "/ledger/persons/"+url.PathEscape(id), nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdatePersonAsync sends PUT /ledger/persons/{id} with async=true. It
+// returns the status of the transaction once it is sent to the orderer.
+func (c *Client) UpdatePersonAsync(ctx context.Context, id string, body PersonRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "PUT", "/ledger/persons/"+url.PathEscape(id), query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PatchPerson sends PATCH /ledger/persons/{id} to change some details of a
+// person.
+func (c *Client) PatchPerson(ctx context.Context, id string, body PersonPatch) (*Person, error) {
+	var result Person
+	err := c.do(ctx, "PATCH", "/ledger/persons/"+url.PathEscape(id), nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PatchPersonAsync sends PATCH /ledger/persons/{id} with async=true. It
+// returns the status of the transaction once it is sent to the orderer.
+func (c *Client) PatchPersonAsync(ctx context.Context, id string, body PersonPatch) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "PATCH", "/ledger/persons/"+url.PathEscape(id), query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DepositMoney sends POST /ledger/persons/{id}/deposits to add money to the
+// balance of a person.
+func (c *Client) DepositMoney(ctx context.Context, id string, body AmountRequest) (*Person, error) {
+	var result Person
+	err := c.do(ctx, "POST", "/ledger/persons/"+url.PathEscape(id)+"/deposits", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DepositMoneyAsync sends POST /ledger/persons/{id}/deposits with async=true.
+// It returns the status of the transaction once it is sent to the orderer.
+func (c *Client) DepositMoneyAsync(ctx context.Context, id string, body AmountRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/persons/"+url.PathEscape(id)+"/deposits", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WithdrawMoney sends POST /ledger/persons/{id}/withdrawals to take money
+// from the balance of a person.
+func (c *Client) WithdrawMoney(ctx context.Context, id string, body AmountRequest) (*Person, error) {
+	var result Person
+	err := c.do(ctx, "POST", "/ledger/persons/"+url.PathEscape(id)+"/withdrawals", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WithdrawMoneyAsync sends POST /ledger/persons/{id}/withdrawals with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) WithdrawMoneyAsync(ctx context.Context, id string, body AmountRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/persons/"+url.PathEscape(id)+"/withdrawals", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetCarsByOwnerParams are the query parameters of GetCarsByOwner. Zero
+// values are not sent.
+type GetCarsByOwnerParams struct {
+	// Only cars of this color.
+	Color string
+	// Also list written-off cars.
+	IncludeWrittenOff bool
+}
+
+func (p *GetCarsByOwnerParams) values(query url.Values) {
+	if p.Color != "" {
+		query.Set("color", p.Color)
+	}
+	if p.IncludeWrittenOff {
+		query.Set("includeWrittenOff", "true")
+	}
+}
+
+// GetCarsByOwner sends GET /ledger/persons/{id}/cars to get the cars of a
+// person.
+func (c *Client) GetCarsByOwner(ctx context.Context, id string, params *GetCarsByOwnerParams) ([]Car, error) {
+	query := url.Values{}
+	if params != nil {
+		params.values(query)
+	}
+	var result []Car
+	err := c.do(ctx, "GET", "/ledger/persons/"+url.PathEscape(id)+"/cars", query, nil, &result)
+	return result, err
+}
+
+// GetOwnerSummary sends GET /ledger/persons/{id}/summary to get what the cars
+// of a person are worth.
+func (c *Client) GetOwnerSummary(ctx context.Context, id string) (*OwnerSummary, error) {
+	var result OwnerSummary
+	err := c.do(ctx, "GET", "/ledger/persons/"+url.PathEscape(id)+"/summary", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueryCarsParams are the query parameters of QueryCars. Zero values are not
+// sent.
+type QueryCarsParams struct {
+	Brand   string
+	Model   string
+	MinYear int
+	MaxYear int
+	// An amount such as 1500 or 1500.50 EUR.
+	MinPrice string
+	// An amount such as 1500 or 1500.50 EUR.
+	MaxPrice string
+	// true or false.
+	HasMalfunctions string
+	// Also list written-off cars.
+	IncludeWrittenOff bool
+	// Cars per page, 10 by default.
+	PageSize int
+	Bookmark string
+}
+
+func (p *QueryCarsParams) values(query url.Values) {
+	if p.Brand != "" {
+		query.Set("brand", p.Brand)
+	}
+	if p.Model != "" {
+		query.Set("model", p.Model)
+	}
+	if p.MinYear != 0 {
+		query.Set("minYear", strconv.Itoa(p.MinYear))
+	}
+	if p.MaxYear != 0 {
+		query.Set("maxYear", strconv.Itoa(p.MaxYear))
+	}
+	if p.MinPrice != "" {
+		query.Set("minPrice", p.MinPrice)
+	}
+	if p.MaxPrice != "" {
+		query.Set("maxPrice", p.MaxPrice)
+	}
+	if p.HasMalfunctions != "" {
+		query.Set("hasMalfunctions", p.HasMalfunctions)
+	}
+	if p.IncludeWrittenOff {
+		query.Set("includeWrittenOff", "true")
+	}
+	if p.PageSize != 0 {
+		query.Set("pageSize", strconv.Itoa(p.PageSize))
+	}
+	if p.Bookmark != "" {
+		query.Set("bookmark", p.Bookmark)
+	}
+}
+
+// QueryCars sends GET /ledger/cars to query cars page by page. The Bookmark
+// of a page is passed as bookmark to get the next page.
+func (c *Client) QueryCars(ctx context.Context, params *QueryCarsParams) (*CarsPage, error) {
+	query := url.Values{}
+	if params != nil {
+		params.values(query)
+	}
+	var result CarsPage
+	err := c.do(ctx, "GET", "/ledger/cars", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateCar sends POST /ledger/cars to register a car.
+func (c *Client) CreateCar(ctx context.Context, body CarRequest) (*Car, error) {
+	var result Car
+	err := c.do(ctx, "POST", "/ledger/cars", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateCarAsync sends POST /ledger/cars with async=true. It returns the
+// status of the transaction once it is sent to the orderer.
+func (c *Client) CreateCarAsync(ctx context.Context, body CarRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/cars", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetCarsByColorParams are the query parameters of GetCarsByColor. Zero
+// values are not sent.
+type GetCarsByColorParams struct {
+	// Also list written-off cars.
+	IncludeWrittenOff bool
+}
+
+func (p *GetCarsByColorParams) values(query url.Values) {
+	if p.IncludeWrittenOff {
+		query.Set("includeWrittenOff", "true")
+	}
+}
+
+// GetCarsByColor sends GET /ledger/cars/colored/{color} to get the cars of a
+// color.
+func (c *Client) GetCarsByColor(ctx context.Context, color string, params *GetCarsByColorParams) ([]Car, error) {
+	query := url.Values{}
+	if params != nil {
+		params.values(query)
+	}
+	var result []Car
+	err := c.do(ctx, "GET", "/ledger/cars/colored/"+url.PathEscape(color), query, nil, &result)
+	return result, err
+}
+
+// GetCar sends GET /ledger/cars/{id} to get a car.
+func (c *Client) GetCar(ctx context.Context, id string) (*Car, error) {
+	var result Car
+	err := c.do(ctx, "GET", "/ledger/cars/"+url.PathEscape(id), nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateCar sends PUT /ledger/cars/{id} to replace the details of a car.
+// Color and Owner are ignored, they change through PATCH and a purchase.
+func (c *Client) UpdateCar(ctx context.Context, id string, body CarRequest) (*Car, error) {
+	var result Car
+	err := c.do(ctx, "PUT", "/ledger/cars/"+url.PathEscape(id), nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateCarAsync sends PUT /ledger/cars/{id} with async=true. It returns the
+// status of the transaction once it is sent to the orderer.
+func (c *Client) UpdateCarAsync(ctx context.Context, id string, body CarRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "PUT", "/ledger/cars/"+url.PathEscape(id), query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ScrapCar sends DELETE /ledger/cars/{id} to scrap a car.
+func (c *Client) ScrapCar(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/ledger/cars/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ScrapCarAsync sends DELETE /ledger/cars/{id} with async=true. It returns
+// the status of the transaction once it is sent to the orderer.
+func (c *Client) ScrapCarAsync(ctx context.Context, id string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "DELETE", "/ledger/cars/"+url.PathEscape(id), query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PatchCar sends PATCH /ledger/cars/{id} to change some details or the color
+// of a car. The patched car is submitted in a single transaction.
+func (c *Client) PatchCar(ctx context.Context, id string, body CarPatch) (*Car, error) {
+	var result Car
+	err := c.do(ctx, "PATCH", "/ledger/cars/"+url.PathEscape(id), nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PatchCarAsync sends PATCH /ledger/cars/{id} with async=true. It returns the
+// status of the transaction once it is sent to the orderer.
+func (c *Client) PatchCarAsync(ctx context.Context, id string, body CarPatch) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "PATCH", "/ledger/cars/"+url.PathEscape(id), query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddMalfunction sends POST /ledger/cars/{id}/malfunctions to report a
+// malfunction of a car.
+func (c *Client) AddMalfunction(ctx context.Context, id string, body MalfunctionRequest) (*Car, error) {
+	var result Car
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/malfunctions", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddMalfunctionAsync sends POST /ledger/cars/{id}/malfunctions with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) AddMalfunctionAsync(ctx context.Context, id string, body MalfunctionRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/malfunctions", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BuyCar sends POST /ledger/cars/{id}/purchase to buy a listed car at its
+// asking price.
+func (c *Client) BuyCar(ctx context.Context, id string, body PurchaseRequest) (*Car, error) {
+	var result Car
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/purchase", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BuyCarAsync sends POST /ledger/cars/{id}/purchase with async=true. It
+// returns the status of the transaction once it is sent to the orderer.
+func (c *Client) BuyCarAsync(ctx context.Context, id string, body PurchaseRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/purchase", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetSaleListing sends GET /ledger/cars/{id}/listing to get the sale listing
+// of a car.
+func (c *Client) GetSaleListing(ctx context.Context, id string) (*SaleListing, error) {
+	var result SaleListing
+	err := c.do(ctx, "GET", "/ledger/cars/"+url.PathEscape(id)+"/listing", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListCarForSale sends PUT /ledger/cars/{id}/listing to list a car for sale.
+// Submitted by the owner. Listing the car again replaces the asking price and
+// expiry.
+func (c *Client) ListCarForSale(ctx context.Context, id string, body ListingRequest) (*SaleListing, error) {
+	var result SaleListing
+	err := c.do(ctx, "PUT", "/ledger/cars/"+url.PathEscape(id)+"/listing", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListCarForSaleAsync sends PUT /ledger/cars/{id}/listing with async=true. It
+// returns the status of the transaction once it is sent to the orderer.
+func (c *Client) ListCarForSaleAsync(ctx context.Context, id string, body ListingRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "PUT", "/ledger/cars/"+url.PathEscape(id)+"/listing", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WithdrawListing sends DELETE /ledger/cars/{id}/listing to withdraw the sale
+// listing of a car. Closes the offers for the car too.
+func (c *Client) WithdrawListing(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/ledger/cars/"+url.PathEscape(id)+"/listing", nil, nil, nil)
+}
+
+// WithdrawListingAsync sends DELETE /ledger/cars/{id}/listing with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) WithdrawListingAsync(ctx context.Context, id string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "DELETE", "/ledger/cars/"+url.PathEscape(id)+"/listing", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetOffers sends GET /ledger/cars/{id}/offers to get the offers for a listed
+// car.
+func (c *Client) GetOffers(ctx context.Context, id string) ([]PurchaseOffer, error) {
+	var result []PurchaseOffer
+	err := c.do(ctx, "GET", "/ledger/cars/"+url.PathEscape(id)+"/offers", nil, nil, &result)
+	return result, err
+}
+
+// MakeOffer sends POST /ledger/cars/{id}/offers to offer a price for a listed
+// car. Submitted by the buyer. A new offer of the buyer replaces the previous
+// one.
+func (c *Client) MakeOffer(ctx context.Context, id string, body OfferRequest) (*PurchaseOffer, error) {
+	var result PurchaseOffer
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/offers", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MakeOfferAsync sends POST /ledger/cars/{id}/offers with async=true. It
+// returns the status of the transaction once it is sent to the orderer.
+func (c *Client) MakeOfferAsync(ctx context.Context, id string, body OfferRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/offers", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetOffer sends GET /ledger/cars/{id}/offers/{buyer} to get the offer of a
+// buyer for a car.
+func (c *Client) GetOffer(ctx context.Context, id string, buyer string) (*PurchaseOffer, error) {
+	var result PurchaseOffer
+	err := c.do(ctx, "GET", "/ledger/cars/"+url.PathEscape(id)+"/offers/"+url.PathEscape(buyer), nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WithdrawOffer sends DELETE /ledger/cars/{id}/offers/{buyer} to withdraw the
+// offer of a buyer.
+func (c *Client) WithdrawOffer(ctx context.Context, id string, buyer string) error {
+	return c.do(ctx, "DELETE", "/ledger/cars/"+url.PathEscape(id)+"/offers/"+url.PathEscape(buyer), nil, nil, nil)
+}
+
+// WithdrawOfferAsync sends DELETE /ledger/cars/{id}/offers/{buyer} with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) WithdrawOfferAsync(ctx context.Context, id string, buyer string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "DELETE", "/ledger/cars/"+url.PathEscape(id)+"/offers/"+url.PathEscape(buyer), query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AcceptOffer sends POST /ledger/cars/{id}/offers/{buyer}/acceptance to sell
+// a car for the offer of a buyer. Submitted by the owner of the car.
+func (c *Client) AcceptOffer(ctx context.Context, id string, buyer string) (*Car, error) {
+	var result Car
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/offers/"+url.PathEscape(buyer)+"/acceptance", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AcceptOfferAsync sends POST /ledger/cars/{id}/offers/{buyer}/acceptance
+// with async=true. It returns the status of the transaction once it is sent
+// to the orderer.
+func (c *Client) AcceptOfferAsync(ctx context.Context, id string, buyer string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/offers/"+url.PathEscape(buyer)+"/acceptance", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRepairOrdersForCar sends GET /ledger/cars/{id}/repair-orders to get the
+// repair orders of a car.
+func (c *Client) GetRepairOrdersForCar(ctx context.Context, id string) ([]RepairOrder, error) {
+	var result []RepairOrder
+	err := c.do(ctx, "GET", "/ledger/cars/"+url.PathEscape(id)+"/repair-orders", nil, nil, &result)
+	return result, err
+}
+
+// OpenRepairOrder sends POST /ledger/cars/{id}/repair-orders to report the
+// malfunctions of a car for repair. Submitted by the owner of the car.
+func (c *Client) OpenRepairOrder(ctx context.Context, id string, body RepairOrderRequest) (*RepairOrder, error) {
+	var result RepairOrder
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/repair-orders", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// OpenRepairOrderAsync sends POST /ledger/cars/{id}/repair-orders with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) OpenRepairOrderAsync(ctx context.Context, id string, body RepairOrderRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/cars/"+url.PathEscape(id)+"/repair-orders", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRepairOrder sends GET /ledger/repair-orders/{id} to get a repair order.
+func (c *Client) GetRepairOrder(ctx context.Context, id string) (*RepairOrder, error) {
+	var result RepairOrder
+	err := c.do(ctx, "GET", "/ledger/repair-orders/"+url.PathEscape(id), nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QuoteRepairOrder sends POST /ledger/repair-orders/{id}/quote to quote the
+// price of a repair. Needs an identity with the mechanic role.
+func (c *Client) QuoteRepairOrder(ctx context.Context, id string, body AmountRequest) (*RepairOrder, error) {
+	var result RepairOrder
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/quote", nil, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QuoteRepairOrderAsync sends POST /ledger/repair-orders/{id}/quote with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) QuoteRepairOrderAsync(ctx context.Context, id string, body AmountRequest) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/quote", query, body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ApproveRepairOrder sends POST /ledger/repair-orders/{id}/approval to
+// approve the quote of a repair. The quoted price is taken from the owner.
+func (c *Client) ApproveRepairOrder(ctx context.Context, id string) (*RepairOrder, error) {
+	var result RepairOrder
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/approval", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ApproveRepairOrderAsync sends POST /ledger/repair-orders/{id}/approval with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) ApproveRepairOrderAsync(ctx context.Context, id string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/approval", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StartRepairOrder sends POST /ledger/repair-orders/{id}/start to start an
+// approved repair. Submitted by the mechanic who quoted the repair.
+func (c *Client) StartRepairOrder(ctx context.Context, id string) (*RepairOrder, error) {
+	var result RepairOrder
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/start", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StartRepairOrderAsync sends POST /ledger/repair-orders/{id}/start with
+// async=true. It returns the status of the transaction once it is sent to the
+// orderer.
+func (c *Client) StartRepairOrderAsync(ctx context.Context, id string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/start", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CompleteRepairOrder sends POST /ledger/repair-orders/{id}/completion to
+// complete a repair. The repaired malfunctions are removed and the quote is
+// paid to the mechanic.
+func (c *Client) CompleteRepairOrder(ctx context.Context, id string) (*RepairOrder, error) {
+	var result RepairOrder
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/completion", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CompleteRepairOrderAsync sends POST /ledger/repair-orders/{id}/completion
+// with async=true. It returns the status of the transaction once it is sent
+// to the orderer.
+func (c *Client) CompleteRepairOrderAsync(ctx context.Context, id string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/completion", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CancelRepairOrder sends POST /ledger/repair-orders/{id}/cancellation to
+// cancel a repair that has not started. A price already taken on approval is
+// returned to the owner.
+func (c *Client) CancelRepairOrder(ctx context.Context, id string) (*RepairOrder, error) {
+	var result RepairOrder
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/cancellation", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CancelRepairOrderAsync sends POST /ledger/repair-orders/{id}/cancellation
+// with async=true. It returns the status of the transaction once it is sent
+// to the orderer.
+func (c *Client) CancelRepairOrderAsync(ctx context.Context, id string) (*TransactionStatus, error) {
+	query := url.Values{}
+	query.Set("async", "true")
+	var result TransactionStatus
+	err := c.do(ctx, "POST", "/ledger/repair-orders/"+url.PathEscape(id)+"/cancellation", query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetTransactionParams are the query parameters of GetTransaction. Zero
+// values are not sent.
+type GetTransactionParams struct {
+	// A duration such as 10s to wait for the transaction to be committed or
+	// invalid, at most the 30-second request timeout.
+	Wait string
+}
+
+func (p *GetTransactionParams) values(query url.Values) {
+	if p.Wait != "" {
+		query.Set("wait", p.Wait)
+	}
+}
+
+// GetTransaction sends GET /transactions/{txId} to get the status of a
+// transaction.
+func (c *Client) GetTransaction(ctx context.Context, txId string, params *GetTransactionParams) (*TransactionStatus, error) {
+	query := url.Values{}
+	if params != nil {
+		params.values(query)
+	}
+	var result TransactionStatus
+	err := c.do(ctx, "GET", "/transactions/"+url.PathEscape(txId), query, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,9 @@
+module github.com/hyperledger/fabric-samples/cars-client
+
+go 1.14
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/stretchr/testify v1.5.1
+	gopkg.in/yaml.v2 v2.3.0
+)
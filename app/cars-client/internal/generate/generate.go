@@ -0,0 +1,449 @@
+// Package generate writes the Go sources of the cars client from the OpenAPI
+// document of the cars API: spec.go embeds the document, generated.go holds a
+// type per component schema and a Client method per JSON operation.
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-samples/cars-client/openapi"
+)
+
+const header = "// Code generated by go run ./cmd/generate from openapi.yaml. DO NOT EDIT.\n\n"
+
+// Files returns the generated sources by file name.
+func Files(spec []byte) (map[string][]byte, error) {
+	doc, err := openapi.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	specFile, err := specSource(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	g := generator{ doc: doc }
+	generated, err := g.source()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		"spec.go": specFile,
+		"generated.go": generated,
+	}, nil
+}
+
+func specSource(spec []byte) ([]byte, error) {
+	if bytes.Contains(spec, []byte("`")) {
+		return nil, fmt.Errorf("openapi.yaml must not contain backquotes")
+	}
+
+	var b bytes.Buffer
+	b.WriteString(header)
+	b.WriteString("package carsclient\n\n")
+	b.WriteString("// Spec is the OpenAPI document of the cars API, as served by client-rest\n")
+	b.WriteString("// under /openapi.yaml.\n")
+	fmt.Fprintf(&b, "var Spec = []byte(`%s`)\n", spec)
+
+	return format.Source(b.Bytes())
+}
+
+type generator struct {
+	doc	*openapi.Document
+	b	bytes.Buffer
+}
+
+func (g *generator) source() ([]byte, error) {
+	names := make([]string, 0, len(g.doc.Components.Schemas))
+	for name := range g.doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := g.schema(name, g.doc.Components.Schemas[name])
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	for _, path := range g.doc.Paths {
+		for _, operation := range path.Operations {
+			err := g.operation(path.Path, operation)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(operation.Method), path.Path, err)
+			}
+		}
+	}
+
+	body := g.b.String()
+
+	var b bytes.Buffer
+	b.WriteString(header)
+	b.WriteString("package carsclient\n\nimport (\n")
+	for _, pkg := range []string{ "context", "encoding/json", "net/url", "strconv", "time" } {
+		if strings.Contains(body, pkg[strings.LastIndex(pkg, "/") + 1:] + ".") {
+			fmt.Fprintf(&b, "\t%q\n", pkg)
+		}
+	}
+	b.WriteString(")\n")
+	b.WriteString(body)
+
+	source, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w\n%s", err, b.Bytes())
+	}
+
+	return source, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.b, format, args...)
+}
+
+// comment writes text as a comment wrapped at 80 columns, ending with a
+// period.
+func (g *generator) comment(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if !strings.HasSuffix(text, ".") {
+		text += "."
+	}
+
+	line := "//"
+	for _, word := range strings.Fields(text) {
+		if len(line) + len(word) + 1 > 78 {
+			g.printf("%s\n", line)
+			line = "//"
+		}
+		line += " " + word
+	}
+	g.printf("%s\n", line)
+}
+
+func (g *generator) schema(name string, schema *openapi.Schema) error {
+	if schema.Type != "object" {
+		return fmt.Errorf("only object schemas are supported")
+	}
+
+	g.printf("\n")
+	g.comment(schema.Description)
+	g.printf("type %s struct {\n", name)
+	for _, property := range schema.Properties {
+		required := schema.IsRequired(property.Name)
+
+		goType, err := g.goType(property.Schema)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", property.Name, err)
+		}
+
+		g.comment(property.Schema.Description)
+		if required {
+			g.printf("%s %s\n", property.Name, goType)
+		} else {
+			g.printf("%s %s `json:\",omitempty\"`\n", property.Name, goType)
+		}
+	}
+	g.printf("}\n")
+
+	for _, property := range schema.Properties {
+		if len(property.Schema.Enum) == 0 {
+			continue
+		}
+
+		g.printf("\n// Values of %s.%s.\nconst (\n", name, property.Name)
+		for _, value := range property.Schema.Enum {
+			g.printf("%s%s%s = %q\n", name, property.Name, identifier(value), value)
+		}
+		g.printf(")\n")
+	}
+
+	return nil
+}
+
+// goType returns the Go type of a schema. Nullable scalars are pointers, so
+// that a missing value differs from a zero value.
+func (g *generator) goType(schema *openapi.Schema) (string, error) {
+	if schema.Ref != "" {
+		return openapi.RefName(schema.Ref), nil
+	}
+
+	var goType string
+	switch schema.Type {
+	case "":
+		return "json.RawMessage", nil
+	case "string":
+		goType = "string"
+		if schema.Format == "date-time" {
+			goType = "time.Time"
+		}
+	case "integer":
+		goType = "int"
+		if schema.Format != "" {
+			goType = schema.Format
+		}
+	case "boolean":
+		goType = "bool"
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+
+		items, err := g.goType(schema.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + items, nil
+	default:
+		return "", fmt.Errorf("type %s is not supported", schema.Type)
+	}
+
+	if schema.Nullable {
+		return "*" + goType, nil
+	}
+
+	return goType, nil
+}
+
+// operation writes the Client method of an operation, and an Async variant
+// when the operation may answer 202. Operations that answer anything but JSON
+// are left to the caller.
+func (g *generator) operation(path string, operation *openapi.Operation) error {
+	success, accepted, err := g.successResponses(operation)
+	if err != nil {
+		return err
+	}
+
+	var result *openapi.Schema
+	if success != nil {
+		for contentType, media := range success.Content {
+			if contentType != "application/json" {
+				return nil
+			}
+			result = media.Schema
+		}
+	}
+
+	name := identifier(operation.OperationID)
+	method := strings.ToUpper(operation.Method)
+
+	var pathParams []string
+	var queryParams []*openapi.Parameter
+	async := false
+	for _, p := range operation.Parameters {
+		parameter, err := g.doc.Parameter(p)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case parameter.In == "path":
+			pathParams = append(pathParams, parameter.Name)
+		case parameter.In == "query" && parameter.Name == "async":
+			async = true
+		case parameter.In == "query":
+			queryParams = append(queryParams, parameter)
+		default:
+			return fmt.Errorf("parameters in %s are not supported", parameter.In)
+		}
+	}
+
+	args := []string{ "ctx context.Context" }
+	for _, p := range pathParams {
+		args = append(args, p + " string")
+	}
+
+	if len(queryParams) > 0 {
+		err := g.params(name, queryParams)
+		if err != nil {
+			return err
+		}
+		args = append(args, fmt.Sprintf("params *%sParams", name))
+	}
+
+	body := "nil"
+	if operation.RequestBody != nil {
+		media, ok := operation.RequestBody.Content["application/json"]
+		if !ok || media.Schema == nil || media.Schema.Ref == "" {
+			return fmt.Errorf("the request body must refer to a schema")
+		}
+
+		body = "body"
+		args = append(args, "body " + openapi.RefName(media.Schema.Ref))
+	}
+
+	resultType := ""
+	if result != nil {
+		resultType, err = g.goType(result)
+		if err != nil {
+			return err
+		}
+	}
+
+	doc := fmt.Sprintf("%s sends %s %s", name, method, path)
+	if operation.Summary != "" {
+		doc += " to " + strings.ToLower(operation.Summary[:1]) + operation.Summary[1:]
+	}
+
+	g.printf("\n")
+	g.comment(doc + ". " + operation.Description)
+	g.call(name, args, method, path, pathParams, len(queryParams) > 0, false, body, resultType)
+
+	if async && accepted {
+		g.printf("\n")
+		g.comment(fmt.Sprintf("%sAsync sends %s %s with async=true. It returns the status of the transaction once it is sent to the orderer.", name, method, path))
+		g.call(name + "Async", args, method, path, pathParams, len(queryParams) > 0, true, body, "TransactionStatus")
+	}
+
+	return nil
+}
+
+// successResponses returns the first 2xx response of an operation other than
+// 202, and whether it may answer 202.
+func (g *generator) successResponses(operation *openapi.Operation) (*openapi.Response, bool, error) {
+	codes := make([]string, 0, len(operation.Responses))
+	for code := range operation.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var success *openapi.Response
+	accepted := false
+	for _, code := range codes {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		if code == strconv.Itoa(http.StatusAccepted) {
+			accepted = true
+			continue
+		}
+		if success != nil {
+			continue
+		}
+
+		response, err := g.doc.Response(operation.Responses[code])
+		if err != nil {
+			return nil, false, err
+		}
+		success = response
+	}
+
+	return success, accepted, nil
+}
+
+func (g *generator) params(name string, parameters []*openapi.Parameter) error {
+	g.printf("\n")
+	g.comment(fmt.Sprintf("%sParams are the query parameters of %s. Zero values are not sent.", name, name))
+	g.printf("type %sParams struct {\n", name)
+	for _, p := range parameters {
+		goType, err := g.goType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+
+		g.comment(p.Description)
+		g.printf("%s %s\n", identifier(p.Name), goType)
+	}
+	g.printf("}\n")
+
+	g.printf("\nfunc (p *%sParams) values(query url.Values) {\n", name)
+	for _, p := range parameters {
+		field := "p." + identifier(p.Name)
+		switch p.Schema.Type + p.Schema.Format {
+		case "string":
+			g.printf("if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, p.Name, field)
+		case "integer":
+			g.printf("if %s != 0 {\nquery.Set(%q, strconv.Itoa(%s))\n}\n", field, p.Name, field)
+		case "integerint64":
+			g.printf("if %s != 0 {\nquery.Set(%q, strconv.FormatInt(%s, 10))\n}\n", field, p.Name, field)
+		case "boolean":
+			g.printf("if %s {\nquery.Set(%q, \"true\")\n}\n", field, p.Name)
+		default:
+			return fmt.Errorf("parameter %s: type %s is not supported", p.Name, p.Schema.Type)
+		}
+	}
+	g.printf("}\n")
+
+	return nil
+}
+
+func (g *generator) call(name string, args []string, method string, path string, pathParams []string, hasParams bool, async bool, body string, resultType string) {
+	returns := "error"
+	if resultType != "" && strings.HasPrefix(resultType, "[]") {
+		returns = fmt.Sprintf("(%s, error)", resultType)
+	} else if resultType != "" {
+		returns = fmt.Sprintf("(*%s, error)", resultType)
+	}
+
+	g.printf("func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), returns)
+
+	query := "nil"
+	if hasParams || async {
+		query = "query"
+		g.printf("query := url.Values{}\n")
+		if hasParams {
+			g.printf("if params != nil {\nparams.values(query)\n}\n")
+		}
+		if async {
+			g.printf("query.Set(\"async\", \"true\")\n")
+		}
+	}
+
+	target := pathExpression(path, pathParams)
+	switch {
+	case resultType == "":
+		g.printf("return c.do(ctx, %q, %s, %s, %s, nil)\n", method, target, query, body)
+	case strings.HasPrefix(resultType, "[]"):
+		g.printf("var result %s\n", resultType)
+		g.printf("err := c.do(ctx, %q, %s, %s, %s, &result)\n", method, target, query, body)
+		g.printf("return result, err\n")
+	default:
+		g.printf("var result %s\n", resultType)
+		g.printf("err := c.do(ctx, %q, %s, %s, %s, &result)\n", method, target, query, body)
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("return &result, nil\n")
+	}
+
+	g.printf("}\n")
+}
+
+// pathExpression returns the Go expression of a path template whose
+// parameters are escaped, e.g. "/ledger/cars/" + url.PathEscape(id).
+func pathExpression(path string, pathParams []string) string {
+	expression := strconv.Quote(path)
+	for _, p := range pathParams {
+		expression = strings.Replace(expression, "{" + p + "}", "\" + url.PathEscape(" + p + ") + \"", 1)
+	}
+
+	return strings.TrimSuffix(expression, " + \"\"")
+}
+
+// identifier turns a name such as getCar, written-off or BAD_REQUEST into an
+// exported Go identifier.
+func identifier(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == ' ' || r == '.'
+	})
+
+	allUpper := strings.ToUpper(name) == name
+
+	var b strings.Builder
+	for _, word := range words {
+		if allUpper {
+			word = strings.ToLower(word)
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+
+	return b.String()
+}
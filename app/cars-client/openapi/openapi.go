@@ -0,0 +1,254 @@
+// Package openapi reads the part of an OpenAPI 3 document the cars API uses:
+// paths with their operations, and the schemas, parameters and responses of
+// its components. Paths, operations and properties keep the order of the
+// document.
+package openapi
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Methods are the operations a path item may have, in the order they are
+// listed in.
+var Methods = []string{ "get", "put", "post", "delete", "patch" }
+
+type Document struct {
+	OpenAPI		string		`yaml:"openapi"`
+	Info		Info		`yaml:"info"`
+	Paths		[]Path		`yaml:"-"`
+	Components	Components	`yaml:"components"`
+}
+
+type Info struct {
+	Title		string	`yaml:"title"`
+	Version		string	`yaml:"version"`
+}
+
+// Path is a path template with its operations, e.g. /ledger/cars/{id}.
+type Path struct {
+	Path		string
+	Operations	[]*Operation
+}
+
+type Operation struct {
+	Method		string			`yaml:"-"`
+	OperationID	string			`yaml:"operationId"`
+	Summary		string			`yaml:"summary"`
+	Description	string			`yaml:"description"`
+	Tags		[]string		`yaml:"tags"`
+	Parameters	[]*Parameter	`yaml:"parameters"`
+	RequestBody	*RequestBody	`yaml:"requestBody"`
+	Responses	Responses		`yaml:"responses"`
+}
+
+type Parameter struct {
+	Ref			string	`yaml:"$ref"`
+	Name		string	`yaml:"name"`
+	In			string	`yaml:"in"`
+	Description	string	`yaml:"description"`
+	Required	bool	`yaml:"required"`
+	Schema		*Schema	`yaml:"schema"`
+}
+
+type RequestBody struct {
+	Required	bool					`yaml:"required"`
+	Content		map[string]MediaType	`yaml:"content"`
+}
+
+// Responses are the responses of an operation by status code, or default.
+type Responses map[string]*Response
+
+type Response struct {
+	Ref			string					`yaml:"$ref"`
+	Description	string					`yaml:"description"`
+	Content		map[string]MediaType	`yaml:"content"`
+}
+
+type MediaType struct {
+	Schema	*Schema	`yaml:"schema"`
+}
+
+type Schema struct {
+	Ref			string		`yaml:"$ref"`
+	Type		string		`yaml:"type"`
+	Format		string		`yaml:"format"`
+	Description	string		`yaml:"description"`
+	Nullable	bool		`yaml:"nullable"`
+	Enum		[]string	`yaml:"enum"`
+	Items		*Schema		`yaml:"items"`
+	Required	[]string	`yaml:"required"`
+	Properties	Properties	`yaml:"properties"`
+}
+
+// Properties are the properties of an object schema in document order.
+type Properties []Property
+
+type Property struct {
+	Name	string
+	Schema	*Schema
+}
+
+type Components struct {
+	Schemas		map[string]*Schema		`yaml:"schemas"`
+	Parameters	map[string]*Parameter	`yaml:"parameters"`
+	Responses	map[string]*Response	`yaml:"responses"`
+}
+
+// Parse reads an OpenAPI 3 document.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Paths	yaml.MapSlice	`yaml:"paths"`
+	}
+	err = yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range raw.Paths {
+		path := Path{ Path: fmt.Sprint(item.Key) }
+
+		var operations map[string]*Operation
+		err := remarshal(item.Value, &operations)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", path.Path, err)
+		}
+
+		for _, method := range Methods {
+			if operation, ok := operations[method]; ok {
+				operation.Method = method
+				path.Operations = append(path.Operations, operation)
+			}
+		}
+
+		doc.Paths = append(doc.Paths, path)
+	}
+
+	return &doc, nil
+}
+
+func (p *Properties) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items yaml.MapSlice
+	err := unmarshal(&items)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		property := Property{ Name: fmt.Sprint(item.Key) }
+		err := remarshal(item.Value, &property.Schema)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", property.Name, err)
+		}
+
+		*p = append(*p, property)
+	}
+
+	return nil
+}
+
+func remarshal(value interface{}, target interface{}) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, target)
+}
+
+// RefName returns the name of the component a reference such as
+// #/components/schemas/Car points to.
+func RefName(ref string) string {
+	return ref[strings.LastIndex(ref, "/") + 1:]
+}
+
+// Parameter returns p, or the component parameter it refers to.
+func (d *Document) Parameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+
+	resolved, ok := d.Components.Parameters[RefName(p.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("parameter %s does not exist", p.Ref)
+	}
+
+	return resolved, nil
+}
+
+// Response returns r, or the component response it refers to.
+func (d *Document) Response(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+
+	resolved, ok := d.Components.Responses[RefName(r.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("response %s does not exist", r.Ref)
+	}
+
+	return resolved, nil
+}
+
+// Schema returns s, or the component schema it refers to.
+func (d *Document) Schema(s *Schema) (*Schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+
+	resolved, ok := d.Components.Schemas[RefName(s.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("schema %s does not exist", s.Ref)
+	}
+
+	return resolved, nil
+}
+
+// Operation returns the operation of method on the path template path.
+func (d *Document) Operation(method string, path string) (*Operation, bool) {
+	method = strings.ToLower(method)
+
+	for _, p := range d.Paths {
+		if p.Path != path {
+			continue
+		}
+
+		for _, operation := range p.Operations {
+			if operation.Method == method {
+				return operation, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Property returns the property called name.
+func (s *Schema) Property(name string) (*Schema, bool) {
+	for _, property := range s.Properties {
+		if property.Name == name {
+			return property.Schema, true
+		}
+	}
+
+	return nil, false
+}
+
+// IsRequired tells whether the object schema requires the property name.
+func (s *Schema) IsRequired(name string) bool {
+	for _, required := range s.Required {
+		if required == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,1307 @@
+// Code generated by go run ./cmd/generate from openapi.yaml. DO NOT EDIT.
+
+package carsclient
+
+// Spec is the OpenAPI document of the cars API, as served by client-rest
+// under /openapi.yaml.
+var Spec = []byte(`openapi: 3.0.3
+info:
+  title: Cars REST API
+  version: 1.0.0
+  description: >-
+    Persons and cars of the cars ledger, served by app/client-rest. Writes
+    answer once their transaction is committed, or with 202 as soon as it is
+    sent to the orderer when async is true. Every failure is answered with an
+    ErrorResponse.
+servers:
+  - url: http://localhost:10000
+security:
+  - {}
+  - apiKey: []
+  - bearer: []
+tags:
+  - name: persons
+  - name: cars
+  - name: repairs
+  - name: transactions
+  - name: events
+paths:
+  /ledger:
+    post:
+      operationId: initLedger
+      summary: Fill the ledger with the sample persons and cars
+      description: Needs an identity with the registry or admin role, and fails with 409 once the ledger has persons or cars.
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '204':
+          description: The ledger was initialized
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/persons:
+    post:
+      operationId: createPerson
+      summary: Register a person
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PersonRequest'
+      responses:
+        '201':
+          description: The person as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Person'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/persons/{id}:
+    get:
+      operationId: getPerson
+      summary: Get a person
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/PersonID'
+      responses:
+        '200':
+          description: The person
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Person'
+        default:
+          $ref: '#/components/responses/Error'
+    put:
+      operationId: updatePerson
+      summary: Replace the details of a person
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/PersonID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PersonRequest'
+      responses:
+        '200':
+          description: The person as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Person'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+    patch:
+      operationId: patchPerson
+      summary: Change some details of a person
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/PersonID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PersonPatch'
+      responses:
+        '200':
+          description: The person as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Person'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/persons/{id}/deposits:
+    post:
+      operationId: depositMoney
+      summary: Add money to the balance of a person
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/PersonID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/AmountRequest'
+      responses:
+        '200':
+          description: The person as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Person'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/persons/{id}/withdrawals:
+    post:
+      operationId: withdrawMoney
+      summary: Take money from the balance of a person
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/PersonID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/AmountRequest'
+      responses:
+        '200':
+          description: The person as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Person'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/persons/{id}/cars:
+    get:
+      operationId: getCarsByOwner
+      summary: Get the cars of a person
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/PersonID'
+        - name: color
+          in: query
+          description: Only cars of this color
+          schema:
+            type: string
+        - $ref: '#/components/parameters/IncludeWrittenOff'
+      responses:
+        '200':
+          description: The cars of the person
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Car'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/persons/{id}/summary:
+    get:
+      operationId: getOwnerSummary
+      summary: Get what the cars of a person are worth
+      tags: [persons]
+      parameters:
+        - $ref: '#/components/parameters/PersonID'
+      responses:
+        '200':
+          description: The summary of the person
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/OwnerSummary'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars:
+    get:
+      operationId: queryCars
+      summary: Query cars page by page
+      description: The Bookmark of a page is passed as bookmark to get the next page.
+      tags: [cars]
+      parameters:
+        - name: brand
+          in: query
+          schema:
+            type: string
+        - name: model
+          in: query
+          schema:
+            type: string
+        - name: minYear
+          in: query
+          schema:
+            type: integer
+        - name: maxYear
+          in: query
+          schema:
+            type: integer
+        - name: minPrice
+          in: query
+          description: An amount such as 1500 or 1500.50 EUR
+          schema:
+            type: string
+        - name: maxPrice
+          in: query
+          description: An amount such as 1500 or 1500.50 EUR
+          schema:
+            type: string
+        - name: hasMalfunctions
+          in: query
+          description: true or false
+          schema:
+            type: string
+        - $ref: '#/components/parameters/IncludeWrittenOff'
+        - name: pageSize
+          in: query
+          description: Cars per page, 10 by default
+          schema:
+            type: integer
+        - name: bookmark
+          in: query
+          schema:
+            type: string
+      responses:
+        '200':
+          description: A page of cars
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/CarsPage'
+        default:
+          $ref: '#/components/responses/Error'
+    post:
+      operationId: createCar
+      summary: Register a car
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/CarRequest'
+      responses:
+        '201':
+          description: The car as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Car'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/colored/{color}:
+    get:
+      operationId: getCarsByColor
+      summary: Get the cars of a color
+      tags: [cars]
+      parameters:
+        - name: color
+          in: path
+          required: true
+          schema:
+            type: string
+        - $ref: '#/components/parameters/IncludeWrittenOff'
+      responses:
+        '200':
+          description: The cars of the color
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Car'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}:
+    get:
+      operationId: getCar
+      summary: Get a car
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+      responses:
+        '200':
+          description: The car
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Car'
+        default:
+          $ref: '#/components/responses/Error'
+    put:
+      operationId: updateCar
+      summary: Replace the details of a car
+      description: Color and Owner are ignored, they change through PATCH and a purchase.
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/CarRequest'
+      responses:
+        '200':
+          description: The car as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Car'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+    patch:
+      operationId: patchCar
+      summary: Change some details or the color of a car
+      description: The patched car is submitted in a single transaction.
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/CarPatch'
+      responses:
+        '200':
+          description: The car as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Car'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+    delete:
+      operationId: scrapCar
+      summary: Scrap a car
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '204':
+          description: The car was scrapped
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}/malfunctions:
+    post:
+      operationId: addMalfunction
+      summary: Report a malfunction of a car
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/MalfunctionRequest'
+      responses:
+        '201':
+          description: The car as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Car'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}/purchase:
+    post:
+      operationId: buyCar
+      summary: Buy a listed car at its asking price
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/PurchaseRequest'
+      responses:
+        '200':
+          description: The car as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Car'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}/listing:
+    get:
+      operationId: getSaleListing
+      summary: Get the sale listing of a car
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+      responses:
+        '200':
+          description: The listing
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/SaleListing'
+        default:
+          $ref: '#/components/responses/Error'
+    put:
+      operationId: listCarForSale
+      summary: List a car for sale
+      description: >-
+        Submitted by the owner. Listing the car again replaces the asking price
+        and expiry.
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/ListingRequest'
+      responses:
+        '200':
+          description: The listing as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/SaleListing'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+    delete:
+      operationId: withdrawListing
+      summary: Withdraw the sale listing of a car
+      description: Closes the offers for the car too.
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '204':
+          description: The listing was withdrawn
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}/offers:
+    get:
+      operationId: getOffers
+      summary: Get the offers for a listed car
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+      responses:
+        '200':
+          description: The offers for the car
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/PurchaseOffer'
+        default:
+          $ref: '#/components/responses/Error'
+    post:
+      operationId: makeOffer
+      summary: Offer a price for a listed car
+      description: Submitted by the buyer. A new offer of the buyer replaces the previous one.
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/OfferRequest'
+      responses:
+        '201':
+          description: The offer as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/PurchaseOffer'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}/offers/{buyer}:
+    get:
+      operationId: getOffer
+      summary: Get the offer of a buyer for a car
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/BuyerID'
+      responses:
+        '200':
+          description: The offer
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/PurchaseOffer'
+        default:
+          $ref: '#/components/responses/Error'
+    delete:
+      operationId: withdrawOffer
+      summary: Withdraw the offer of a buyer
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/BuyerID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '204':
+          description: The offer was withdrawn
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}/offers/{buyer}/acceptance:
+    post:
+      operationId: acceptOffer
+      summary: Sell a car for the offer of a buyer
+      description: Submitted by the owner of the car.
+      tags: [cars]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/BuyerID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '200':
+          description: The car as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Car'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/cars/{id}/repair-orders:
+    get:
+      operationId: getRepairOrdersForCar
+      summary: Get the repair orders of a car
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+      responses:
+        '200':
+          description: The repair orders of the car
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/RepairOrder'
+        default:
+          $ref: '#/components/responses/Error'
+    post:
+      operationId: openRepairOrder
+      summary: Report the malfunctions of a car for repair
+      description: Submitted by the owner of the car.
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/CarID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/RepairOrderRequest'
+      responses:
+        '201':
+          description: The repair order as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/RepairOrder'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/repair-orders/{id}:
+    get:
+      operationId: getRepairOrder
+      summary: Get a repair order
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/RepairOrderID'
+      responses:
+        '200':
+          description: The repair order
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/RepairOrder'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/repair-orders/{id}/quote:
+    post:
+      operationId: quoteRepairOrder
+      summary: Quote the price of a repair
+      description: Needs an identity with the mechanic role.
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/RepairOrderID'
+        - $ref: '#/components/parameters/Async'
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/AmountRequest'
+      responses:
+        '200':
+          description: The repair order as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/RepairOrder'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/repair-orders/{id}/approval:
+    post:
+      operationId: approveRepairOrder
+      summary: Approve the quote of a repair
+      description: The quoted price is taken from the owner.
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/RepairOrderID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '200':
+          description: The repair order as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/RepairOrder'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/repair-orders/{id}/start:
+    post:
+      operationId: startRepairOrder
+      summary: Start an approved repair
+      description: Submitted by the mechanic who quoted the repair.
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/RepairOrderID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '200':
+          description: The repair order as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/RepairOrder'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/repair-orders/{id}/completion:
+    post:
+      operationId: completeRepairOrder
+      summary: Complete a repair
+      description: The repaired malfunctions are removed and the quote is paid to the mechanic.
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/RepairOrderID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '200':
+          description: The repair order as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/RepairOrder'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /ledger/repair-orders/{id}/cancellation:
+    post:
+      operationId: cancelRepairOrder
+      summary: Cancel a repair that has not started
+      description: A price already taken on approval is returned to the owner.
+      tags: [repairs]
+      parameters:
+        - $ref: '#/components/parameters/RepairOrderID'
+        - $ref: '#/components/parameters/Async'
+      responses:
+        '200':
+          description: The repair order as stored
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/RepairOrder'
+        '202':
+          $ref: '#/components/responses/Accepted'
+        default:
+          $ref: '#/components/responses/Error'
+  /transactions/{txId}:
+    get:
+      operationId: getTransaction
+      summary: Get the status of a transaction
+      tags: [transactions]
+      parameters:
+        - name: txId
+          in: path
+          required: true
+          schema:
+            type: string
+        - name: wait
+          in: query
+          description: >-
+            A duration such as 10s to wait for the transaction to be committed
+            or invalid, at most the 30-second request timeout
+          schema:
+            type: string
+      responses:
+        '200':
+          description: The status of the transaction
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/TransactionStatus'
+        default:
+          $ref: '#/components/responses/Error'
+  /events:
+    get:
+      operationId: streamEvents
+      summary: Stream committed events
+      description: >-
+        Server-Sent Events, or JSON messages over a WebSocket when the request
+        upgrades to one. A reconnecting stream sends Last-Event-ID, or
+        lastEventId for a WebSocket, to continue after the last event it got.
+        A stream asking for events older than the kept ones is answered with
+        410.
+      tags: [events]
+      parameters:
+        - name: event
+          in: query
+          description: Comma-separated names of the chaincode events to stream
+          schema:
+            type: string
+        - name: car
+          in: query
+          schema:
+            type: string
+        - name: owner
+          in: query
+          description: Only events of the person, or of the cars the person owns or sells or buys
+          schema:
+            type: string
+        - name: blocks
+          in: query
+          description: Also stream every committed block
+          schema:
+            type: boolean
+        - name: fromBlock
+          in: query
+          schema:
+            type: integer
+            format: int64
+        - name: lastEventId
+          in: query
+          schema:
+            type: string
+        - name: access_token
+          in: query
+          description: The bearer token, as browsers cannot set headers on a stream
+          schema:
+            type: string
+      responses:
+        '200':
+          description: The stream of events
+          content:
+            text/event-stream:
+              schema:
+                $ref: '#/components/schemas/StreamEvent'
+        default:
+          $ref: '#/components/responses/Error'
+  /openapi.yaml:
+    get:
+      operationId: getOpenAPI
+      summary: Get this document
+      responses:
+        '200':
+          description: The OpenAPI document of the API
+          content:
+            application/yaml:
+              schema:
+                type: string
+        default:
+          $ref: '#/components/responses/Error'
+components:
+  securitySchemes:
+    apiKey:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    bearer:
+      type: http
+      scheme: bearer
+      bearerFormat: JWT
+  parameters:
+    PersonID:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: string
+    CarID:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: string
+    BuyerID:
+      name: buyer
+      in: path
+      required: true
+      schema:
+        type: string
+    RepairOrderID:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: string
+    Async:
+      name: async
+      in: query
+      description: Answer 202 once the transaction is sent to the orderer
+      schema:
+        type: boolean
+    IncludeWrittenOff:
+      name: includeWrittenOff
+      in: query
+      description: Also list written-off cars
+      schema:
+        type: boolean
+  responses:
+    Accepted:
+      description: >-
+        The transaction was sent to the orderer. Location points to its status
+        under /transactions.
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/TransactionStatus'
+    Error:
+      description: The request failed
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/ErrorResponse'
+  schemas:
+    Money:
+      type: object
+      description: Money is an amount in minor units (cents).
+      required: [Amount, Currency]
+      properties:
+        Amount:
+          type: integer
+          format: int64
+        Currency:
+          type: string
+    Person:
+      type: object
+      required: [ID, Name, Surname, Email, Money]
+      properties:
+        ID:
+          type: string
+        Name:
+          type: string
+        Surname:
+          type: string
+        Email:
+          type: string
+        Money:
+          $ref: '#/components/schemas/Money'
+    Malfunction:
+      type: object
+      required: [Description, Price]
+      properties:
+        Description:
+          type: string
+        Price:
+          $ref: '#/components/schemas/Money'
+    Car:
+      type: object
+      required: [ID, Brand, Model, Year, Color, Owner, Malfunctions, Price, Status]
+      properties:
+        ID:
+          type: string
+        Brand:
+          type: string
+        Model:
+          type: string
+        Year:
+          type: integer
+        Color:
+          type: string
+        Owner:
+          type: string
+        Malfunctions:
+          type: array
+          items:
+            $ref: '#/components/schemas/Malfunction'
+        Price:
+          $ref: '#/components/schemas/Money'
+        Status:
+          type: string
+          enum: [active, scrapped, written-off]
+    CarsPage:
+      type: object
+      required: [Cars, FetchedRecordsCount, Bookmark]
+      properties:
+        Cars:
+          type: array
+          items:
+            $ref: '#/components/schemas/Car'
+        FetchedRecordsCount:
+          type: integer
+          format: int32
+        Bookmark:
+          type: string
+    OwnerSummary:
+      type: object
+      description: >-
+        Written-off cars are counted in CarCount and WrittenOffCount, but add
+        nothing to MarketValue, OutstandingRepairCost and NetWorth.
+      required: [PersonID, CarCount, WrittenOffCount, MarketValue, OutstandingRepairCost, Money, NetWorth]
+      properties:
+        PersonID:
+          type: string
+        CarCount:
+          type: integer
+        WrittenOffCount:
+          type: integer
+        MarketValue:
+          $ref: '#/components/schemas/Money'
+        OutstandingRepairCost:
+          $ref: '#/components/schemas/Money'
+        Money:
+          $ref: '#/components/schemas/Money'
+        NetWorth:
+          $ref: '#/components/schemas/Money'
+    PersonRequest:
+      type: object
+      description: >-
+        PersonRequest is the body of POST /ledger/persons and of PUT, which
+        takes the id from the path.
+      properties:
+        ID:
+          type: string
+        Name:
+          type: string
+        Surname:
+          type: string
+        Email:
+          type: string
+    PersonPatch:
+      type: object
+      description: >-
+        PersonPatch is the body of PATCH /ledger/persons/{id}. Missing fields
+        keep their value.
+      properties:
+        Name:
+          type: string
+          nullable: true
+        Surname:
+          type: string
+          nullable: true
+        Email:
+          type: string
+          nullable: true
+    AmountRequest:
+      type: object
+      required: [Amount]
+      properties:
+        Amount:
+          type: string
+          description: An amount such as 1500 or 1500.50 EUR
+    CarRequest:
+      type: object
+      description: >-
+        CarRequest is the body of POST /ledger/cars and of PUT, which takes the
+        id from the path.
+      properties:
+        ID:
+          type: string
+        Brand:
+          type: string
+        Model:
+          type: string
+        Year:
+          type: integer
+        Color:
+          type: string
+        Owner:
+          type: string
+        Price:
+          type: string
+          description: An amount such as 1500 or 1500.50 EUR
+    CarPatch:
+      type: object
+      description: >-
+        CarPatch is the body of PATCH /ledger/cars/{id}. Missing fields keep
+        their value.
+      properties:
+        Brand:
+          type: string
+          nullable: true
+        Model:
+          type: string
+          nullable: true
+        Year:
+          type: integer
+          nullable: true
+        Price:
+          type: string
+          nullable: true
+        Color:
+          type: string
+          nullable: true
+    MalfunctionRequest:
+      type: object
+      required: [Description, Price]
+      properties:
+        Description:
+          type: string
+        Price:
+          type: string
+    PurchaseRequest:
+      type: object
+      description: >-
+        PurchaseRequest is the body of POST /ledger/cars/{id}/purchase, which
+        buys a listed car at its asking price. A car with malfunctions is only
+        bought when AcceptMalfunctions is true.
+      required: [Buyer]
+      properties:
+        Buyer:
+          type: string
+        AcceptMalfunctions:
+          type: boolean
+    SaleListing:
+      type: object
+      description: A zero ExpiresAt means the listing stays open until it is withdrawn.
+      required: [CarID, Seller, AskingPrice, ExpiresAt]
+      properties:
+        CarID:
+          type: string
+        Seller:
+          type: string
+        AskingPrice:
+          $ref: '#/components/schemas/Money'
+        ExpiresAt:
+          type: string
+          format: date-time
+    PurchaseOffer:
+      type: object
+      description: A zero ExpiresAt means the offer stays open until it is withdrawn.
+      required: [CarID, Buyer, Price, AcceptMalfunctions, ExpiresAt]
+      properties:
+        CarID:
+          type: string
+        Buyer:
+          type: string
+        Price:
+          $ref: '#/components/schemas/Money'
+        AcceptMalfunctions:
+          type: boolean
+        ExpiresAt:
+          type: string
+          format: date-time
+    ListingRequest:
+      type: object
+      description: ListingRequest is the body of PUT /ledger/cars/{id}/listing.
+      required: [AskingPrice]
+      properties:
+        AskingPrice:
+          type: string
+          description: An amount such as 1500 or 1500.50 EUR
+        ValidFor:
+          type: string
+          description: A duration such as 72h, empty to keep the listing open until it is withdrawn
+    OfferRequest:
+      type: object
+      description: >-
+        OfferRequest is the body of POST /ledger/cars/{id}/offers. A car with
+        malfunctions is only bought when AcceptMalfunctions is true.
+      required: [Buyer, Price]
+      properties:
+        Buyer:
+          type: string
+        Price:
+          type: string
+          description: An amount such as 1500 or 1500.50 EUR
+        AcceptMalfunctions:
+          type: boolean
+        ValidFor:
+          type: string
+          description: A duration such as 24h, empty to keep the offer open until it is withdrawn
+    RepairOrder:
+      type: object
+      required: [ID, CarID, Owner, Mechanic, Malfunctions, Quote, Status, UpdatedAt]
+      properties:
+        ID:
+          type: string
+        CarID:
+          type: string
+        Owner:
+          type: string
+        Mechanic:
+          type: string
+        Malfunctions:
+          type: array
+          items:
+            $ref: '#/components/schemas/Malfunction'
+        Quote:
+          $ref: '#/components/schemas/Money'
+        Status:
+          type: string
+          enum: [reported, quoted, approved, in-progress, completed, cancelled]
+        UpdatedAt:
+          type: string
+          format: date-time
+    RepairOrderRequest:
+      type: object
+      description: RepairOrderRequest is the body of POST /ledger/cars/{id}/repair-orders.
+      required: [ID]
+      properties:
+        ID:
+          type: string
+    ErrorResponse:
+      type: object
+      required: [Code, Message]
+      properties:
+        Code:
+          type: string
+          enum:
+            - BAD_REQUEST
+            - UNAUTHORIZED
+            - UNSUPPORTED_MEDIA_TYPE
+            - FORBIDDEN
+            - NOT_FOUND
+            - METHOD_NOT_ALLOWED
+            - GONE
+            - CONFLICT
+            - TRANSACTION_INVALID
+            - BAD_GATEWAY
+            - GATEWAY_TIMEOUT
+            - INTERNAL_ERROR
+        Message:
+          type: string
+        ChaincodeError:
+          type: string
+          description: The message the chaincode rejected the transaction with
+        TxID:
+          type: string
+          description: The transaction, once it reached the orderer
+        Classification:
+          type: string
+          description: Why the transaction failed
+          enum:
+            - MVCC_CONFLICT
+            - PHANTOM_READ
+            - ENDORSEMENT_MISMATCH
+            - ENDORSEMENT_UNAVAILABLE
+            - TIMEOUT
+            - BUSINESS_ERROR
+            - INVALID
+            - OTHER
+        Attempts:
+          type: integer
+          description: How often the transaction was submitted
+    TransactionStatus:
+      type: object
+      required: [TxID, Function, Status, Submitted]
+      properties:
+        TxID:
+          type: string
+        Function:
+          type: string
+        Status:
+          type: string
+          enum: [ENDORSED, SUBMITTED, COMMITTED, INVALID]
+        ValidationCode:
+          type: string
+          description: The validation code of the commit, e.g. VALID or MVCC_READ_CONFLICT
+        BlockNumber:
+          type: integer
+          format: int64
+        Error:
+          type: string
+        Submitted:
+          type: string
+          format: date-time
+    BlockTransaction:
+      type: object
+      required: [TxID, ValidationCode]
+      properties:
+        TxID:
+          type: string
+        ValidationCode:
+          type: string
+    StreamEvent:
+      type: object
+      description: >-
+        StreamEvent is a chaincode event, or a committed block when Type is
+        block. ID is
+        <block>/<txId> for a chaincode event and <block> for a block.
+      required: [ID, Type, BlockNumber]
+      properties:
+        ID:
+          type: string
+        Type:
+          type: string
+          enum: [chaincode, block]
+        Name:
+          type: string
+        BlockNumber:
+          type: integer
+          format: int64
+        TxID:
+          type: string
+        Payload:
+          description: The JSON payload of the chaincode event
+        Transactions:
+          type: array
+          items:
+            $ref: '#/components/schemas/BlockTransaction'
+`)
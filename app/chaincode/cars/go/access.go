@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Roles are read from the "role" attribute of the submitting client's
+// certificate, e.g. registered with --id.attrs 'role=mechanic:ecert'. Several
+// roles may be given as a comma separated list.
+const (
+	roleAttribute	= "role"
+
+	RoleMechanic	= "mechanic"
+	RoleDealer		= "dealer"
+	RoleRegistry	= "registry"
+	RoleAdmin		= "admin"
+
+	identityIndex	= "identity~person"
+)
+
+// LinkPersonIdentity binds a person to the client identity that may act on
+// their behalf. clientId is the value returned by GetID() of the client
+// identity library. Only the registry may link or relink persons.
+func (s *SmartContract) LinkPersonIdentity(ctx contractapi.TransactionContextInterface, personId string, mspId string, clientId string) error {
+	err := assertRole(ctx, "link person identities", RoleRegistry)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(mspId) == "" || strings.TrimSpace(clientId) == "" {
+		return fmt.Errorf("MSP id and client id must not be empty!")
+	}
+
+	person, err := s.GetPerson(ctx, personId)
+	if err != nil {
+		return err
+	}
+
+	return linkIdentity(ctx, person, mspId, clientId)
+}
+
+// GetCallerPerson returns the person linked to the submitting client identity.
+func (s *SmartContract) GetCallerPerson(ctx contractapi.TransactionContextInterface) (*Person, error) {
+	mspId, clientId, err := clientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	personId, err := personIdForIdentity(ctx, mspId, clientId)
+	if err != nil {
+		return nil, err
+	}
+	if personId == "" {
+		return nil, fmt.Errorf("Submitting client is not linked to any person!")
+	}
+
+	return s.GetPerson(ctx, personId)
+}
+
+// linkIdentity stores the identity on the person and keeps the identity~person
+// index pointing at it, so a client identity belongs to at most one person.
+func linkIdentity(ctx contractapi.TransactionContextInterface, person *Person, mspId string, clientId string) error {
+	linkedId, err := personIdForIdentity(ctx, mspId, clientId)
+	if err != nil {
+		return err
+	}
+	if linkedId != "" && linkedId != person.ID {
+		return fmt.Errorf("Client identity is already linked to person %s!", linkedId)
+	}
+
+	if person.ClientID != "" {
+		oldKey, err := ctx.GetStub().CreateCompositeKey(identityIndex, []string{person.MSPID, person.ClientID})
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().DelState(oldKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(identityIndex, []string{mspId, clientId})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, []byte(person.ID))
+	if err != nil {
+		return err
+	}
+
+	person.MSPID = mspId
+	person.ClientID = clientId
+
+	return putPerson(ctx, person)
+}
+
+func personIdForIdentity(ctx contractapi.TransactionContextInterface, mspId string, clientId string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(identityIndex, []string{mspId, clientId})
+	if err != nil {
+		return "", err
+	}
+
+	personId, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read from world state: %v", err)
+	}
+
+	return string(personId), nil
+}
+
+func clientIdentity(ctx contractapi.TransactionContextInterface) (string, string, error) {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to get client MSP id: %v", err)
+	}
+
+	clientId, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to get client id: %v", err)
+	}
+
+	return mspId, clientId, nil
+}
+
+func hasRole(ctx contractapi.TransactionContextInterface, role string) (bool, error) {
+	value, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get client attribute %s: %v", roleAttribute, err)
+	}
+	if !found {
+		return false, nil
+	}
+
+	for _, granted := range strings.Split(value, ",") {
+		if strings.TrimSpace(granted) == role {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isPerson reports whether the submitting client is the identity linked to
+// the person. Persons that were never linked cannot be impersonated.
+func isPerson(ctx contractapi.TransactionContextInterface, person *Person) (bool, error) {
+	if person.ClientID == "" {
+		return false, nil
+	}
+
+	mspId, clientId, err := clientIdentity(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return person.MSPID == mspId && person.ClientID == clientId, nil
+}
+
+func assertRole(ctx contractapi.TransactionContextInterface, action string, roles ...string) error {
+	for _, role := range roles {
+		granted, err := hasRole(ctx, role)
+		if err != nil {
+			return err
+		}
+		if granted {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Submitting client is not allowed to %s!", action)
+}
+
+// assertPersonOrRole allows the person themselves or a client holding any of
+// the given roles.
+func assertPersonOrRole(ctx contractapi.TransactionContextInterface, person *Person, action string, roles ...string) error {
+	self, err := isPerson(ctx, person)
+	if err != nil {
+		return err
+	}
+	if self {
+		return nil
+	}
+
+	return assertRole(ctx, action, roles...)
+}
+
+func (s *SmartContract) assertCarOwnerOrRole(ctx contractapi.TransactionContextInterface, car *Car, action string, roles ...string) error {
+	owner, err := s.GetPerson(ctx, car.Owner)
+	if err != nil {
+		return err
+	}
+
+	return assertPersonOrRole(ctx, owner, action, roles...)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkPersonIdentity(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.LinkPersonIdentity(l.tx(petarClient), "1", "Org4MSP", "someone")
+	require.EqualError(t, err, "Submitting client is not allowed to link person identities!")
+
+	err = l.contract.LinkPersonIdentity(l.tx(registryClient), "1", "Org4MSP", "marko")
+	require.EqualError(t, err, "Client identity is already linked to person 2!")
+
+	err = l.contract.LinkPersonIdentity(l.tx(registryClient), "1", "", "petar")
+	require.EqualError(t, err, "MSP id and client id must not be empty!")
+
+	err = l.contract.LinkPersonIdentity(l.tx(registryClient), "42", "Org4MSP", "someone")
+	require.EqualError(t, err, "Person with id 42 does not exist!")
+
+	newPetar := &testIdentity { mspId: "Org4MSP", id: "petar-new" }
+	err = l.contract.LinkPersonIdentity(l.tx(registryClient), "1", newPetar.mspId, newPetar.id)
+	require.NoError(t, err)
+
+	person, err := l.contract.GetCallerPerson(l.tx(newPetar))
+	require.NoError(t, err)
+	require.Equal(t, "1", person.ID)
+
+	_, err = l.contract.GetCallerPerson(l.tx(petarClient))
+	require.EqualError(t, err, "Submitting client is not linked to any person!")
+
+	err = l.contract.DepositMoney(l.tx(petarClient), "1", "10")
+	require.EqualError(t, err, "Submitting client is not allowed to deposit money!")
+}
+
+func TestGetCallerPerson(t *testing.T) {
+	l := newSeededLedger(t)
+
+	person, err := l.contract.GetCallerPerson(l.tx(markoClient))
+	require.NoError(t, err)
+	require.Equal(t, "2", person.ID)
+	require.Equal(t, "marko", person.ClientID)
+
+	_, err = l.contract.GetCallerPerson(l.tx(strangerClient))
+	require.EqualError(t, err, "Submitting client is not linked to any person!")
+}
+
+func TestUnlinkedPersonCannotBeImpersonated(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.CreatePerson(l.tx(registryClient), "5", "Ana", "Anic", "ana@gmail.com")
+	require.NoError(t, err)
+
+	anonymous := &testIdentity { mspId: "", id: "" }
+	err = l.contract.DepositMoney(l.tx(anonymous), "5", "10")
+	require.EqualError(t, err, "Submitting client is not allowed to deposit money!")
+}
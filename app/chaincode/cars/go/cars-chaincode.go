@@ -3,24 +3,32 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 type SmartContract struct {
 	contractapi.Contract
+	stateDatabase	stateDatabase
 }
 
 
 type Person struct {
+	DocType	string
 	ID		string
 	Name	string
 	Surname	string
 	Email	string
-	Money	float32
+	Money	Money
+	MSPID	string
+	ClientID	string
 }
 
 type Car struct {
+	DocType			string
 	ID				string
 	Brand			string
 	Model			string
@@ -28,74 +36,101 @@ type Car struct {
 	Color			string
 	Owner			string
 	Malfunctions	[]Malfunction
-	Price			float32
+	Price			Money
+	Status			string
 }
 
 type Malfunction struct {
 	Description		string
-	Price			float32
+	Price			Money
 }
 
+const (
+	CarStatusActive		= "active"
+	CarStatusScrapped	= "scrapped"
+	CarStatusWrittenOff	= "written-off"
+
+	personDocType		= "person"
+	carDocType			= "car"
+
+	personKeyType		= "person~ID"
+	carKeyType			= "car~ID"
+	colorOwnerIndex		= "color~owner~ID"
+	firstCarYear		= 1886
+)
+
+// InitLedger fills an empty ledger with the sample persons and cars. Only the
+// registry or an admin may run it, and it refuses to run again, as it would
+// overwrite identity links, balances and owners.
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	err := assertRole(ctx, "initialize the ledger", RoleRegistry, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	for _, keyType := range []string{personKeyType, carKeyType} {
+		initialized, err := hasRecords(ctx, keyType)
+		if err != nil {
+			return err
+		}
+		if initialized {
+			return fmt.Errorf("Ledger is already initialized!")
+		}
+	}
+
 	persons := []Person {
-		{ ID: "1", Name: "Petar", Surname: "Petrovic", Email: "petar@gmail.com", Money: 7700.0 },
-		{ ID: "2", Name: "Marko", Surname: "Markovic", Email: "marko@gmail.com", Money: 2850.0 },
-		{ ID: "3", Name: "Stefan", Surname: "Stefanovic", Email: "stefan@gmail.com", Money: 5100.0 },
+		{ ID: "1", Name: "Petar", Surname: "Petrovic", Email: "petar@gmail.com", Money: NewMoney(7700_00) },
+		{ ID: "2", Name: "Marko", Surname: "Markovic", Email: "marko@gmail.com", Money: NewMoney(2850_00) },
+		{ ID: "3", Name: "Stefan", Surname: "Stefanovic", Email: "stefan@gmail.com", Money: NewMoney(5100_00) },
 	}
 
 	cars := []Car {
 		{ ID: "c1", Brand: "Jeep", Model: "Renegade", Year: 2015, Color: "black", Owner: "1", Malfunctions: []Malfunction{
-			{ Description: "Popravak motora", Price: 32.3 },
-			{ Description: "Popravak brave na vratima", Price: 12.5 },
-		}, Price: 5200.0 },
+			{ Description: "Popravak motora", Price: NewMoney(32_30) },
+			{ Description: "Popravak brave na vratima", Price: NewMoney(12_50) },
+		}, Price: NewMoney(5200_00) },
 		{ ID: "c2", Brand: "Dacia", Model: "Duster", Year: 2019, Color: "gray", Owner: "1", Malfunctions: []Malfunction{
-			{ Description: "Curenje ulja", Price: 23.5 },
-		}, Price: 3900.0},
-		{ ID: "c3", Brand: "Toyota", Model: "RAV4", Year: 2018, Color: "black", Owner: "2", Malfunctions: []Malfunction{}, Price: 4150.0},
+			{ Description: "Curenje ulja", Price: NewMoney(23_50) },
+		}, Price: NewMoney(3900_00)},
+		{ ID: "c3", Brand: "Toyota", Model: "RAV4", Year: 2018, Color: "black", Owner: "2", Malfunctions: []Malfunction{}, Price: NewMoney(4150_00)},
 		{ ID: "c4", Brand: "Audi", Model: "A6", Year: 2010, Color: "red", Owner: "3", Malfunctions: []Malfunction{
-			{ Description: "Popravak motora", Price: 28.0 },
-			{ Description: "Zamena retrovizora", Price: 8.0 },
-			{ Description: "Zamena stop svetla", Price: 5.0 },
-		}, Price: 2700.0},
+			{ Description: "Popravak motora", Price: NewMoney(28_00) },
+			{ Description: "Zamena retrovizora", Price: NewMoney(8_00) },
+			{ Description: "Zamena stop svetla", Price: NewMoney(5_00) },
+		}, Price: NewMoney(2700_00)},
 		{ ID: "c5", Brand: "Audi", Model: "R8", Year: 2015, Color: "white", Owner: "2", Malfunctions: []Malfunction{
-			{ Description: "Popravak klime", Price: 20.0 },
-		}, Price: 4300.0},
+			{ Description: "Popravak klime", Price: NewMoney(20_00) },
+		}, Price: NewMoney(4300_00)},
 		{ ID: "c6", Brand: "BMW", Model: "IX3", Year: 2020, Color: "blue", Owner: "2", Malfunctions: []Malfunction{
-			{ Description: "Popravak kocnice", Price: 24.7 },
-		}, Price: 5000.0},
+			{ Description: "Popravak kocnice", Price: NewMoney(24_70) },
+		}, Price: NewMoney(5000_00)},
 	}
 
 	for _, person := range persons {
-		personJson, err := json.Marshal(person)
+		err := putPerson(ctx, &person)
 		if err != nil {
 			return err
 		}
-
-		err = ctx.GetStub().PutState(person.ID, personJson)
-		if err != nil {
-			return fmt.Errorf("Failed to put to world state! %v", err)
-		}
 	}
 
 	for _, car := range cars {
-		carJson, err := json.Marshal(car)
+		car.Status = CarStatusActive
+		err := putCar(ctx, &car)
 		if err != nil {
 			return err
 		}
 
-		err = ctx.GetStub().PutState(car.ID, carJson)
+		err = putColorIndex(ctx, &car)
 		if err != nil {
-			return fmt.Errorf("Failed to put to world state! %v", err)
+			return err
 		}
 
-		index := "color~owner~ID"
-		key, err := ctx.GetStub().CreateCompositeKey(index, []string{car.Color, car.Owner, car.ID})
+		err = putQueryIndexes(ctx, &car)
 		if err != nil {
 			return err
 		}
 
-		value := []byte{0x00}
-		err = ctx.GetStub().PutState(key, value)
+		err = putOwnerIndex(ctx, &car)
 		if err != nil {
 			return err
 		}
@@ -104,15 +139,30 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	return nil
 }
 
-func (s *SmartContract) GetPerson(ctx contractapi.TransactionContextInterface, id string) (*Person, error) {
-	personJson, err := ctx.GetStub().GetState(id)
+// hasRecords tells whether any record is stored under keyType.
+func hasRecords(ctx contractapi.TransactionContextInterface, keyType string) (bool, error) {
+	recordsIter, err := ctx.GetStub().GetStateByPartialCompositeKey(keyType, []string{})
+	if err != nil {
+		return false, err
+	}
+	defer recordsIter.Close()
 
-	if personJson == nil {
-		return nil, fmt.Errorf("Person with id %s does not exist!", id)
+	return recordsIter.HasNext(), nil
+}
+
+func (s *SmartContract) GetPerson(ctx contractapi.TransactionContextInterface, id string) (*Person, error) {
+	key, err := personKey(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+
+	personJson, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to load person from world state: %v", err)
 	}
+	if personJson == nil {
+		return nil, fmt.Errorf("Person with id %s does not exist!", id)
+	}
 
 	var person Person
 	err = json.Unmarshal(personJson, &person)
@@ -120,18 +170,166 @@ func (s *SmartContract) GetPerson(ctx contractapi.TransactionContextInterface, i
 		return nil, err
 	}
 
+	if person.DocType != personDocType {
+		return nil, fmt.Errorf("Record with id %s is not a person!", id)
+	}
+
 	return &person, nil
 }
 
+func (s *SmartContract) CreatePerson(ctx contractapi.TransactionContextInterface, id string, name string, surname string, email string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("Person id must not be empty!")
+	}
+
+	err := validatePersonDetails(name, surname, email)
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.OwnerExists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("Person with id %s already exists!", id)
+	}
+
+	person := Person {
+		ID: id,
+		Name: name,
+		Surname: surname,
+		Email: email,
+		Money: NewMoney(0),
+	}
+
+	// The registry registers persons on their behalf and links identities
+	// later; anyone else registers themselves.
+	registry, err := hasRole(ctx, RoleRegistry)
+	if err != nil {
+		return err
+	}
+
+	if registry {
+		err = putPerson(ctx, &person)
+	} else {
+		var mspId, clientId string
+		mspId, clientId, err = clientIdentity(ctx)
+		if err == nil {
+			err = linkIdentity(ctx, &person, mspId, clientId)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventPersonRegistered, PersonRegisteredEvent { PersonID: id })
+}
+
+func (s *SmartContract) UpdatePerson(ctx contractapi.TransactionContextInterface, id string, name string, surname string, email string) error {
+	person, err := s.GetPerson(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = assertPersonOrRole(ctx, person, "update the person", RoleRegistry)
+	if err != nil {
+		return err
+	}
+
+	err = validatePersonDetails(name, surname, email)
+	if err != nil {
+		return err
+	}
+
+	person.Name = name
+	person.Surname = surname
+	person.Email = email
+
+	err = putPerson(ctx, person)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventPersonUpdated, PersonUpdatedEvent { PersonID: id })
+}
+
+func (s *SmartContract) DepositMoney(ctx contractapi.TransactionContextInterface, id string, amount string) error {
+	money, err := parsePositiveAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	person, err := s.GetPerson(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = assertPersonOrRole(ctx, person, "deposit money", RoleRegistry)
+	if err != nil {
+		return err
+	}
+
+	person.Money, err = person.Money.Add(money)
+	if err != nil {
+		return err
+	}
+
+	err = putPerson(ctx, person)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventBalanceChanged, BalanceChangedEvent { PersonID: id, Change: money, Balance: person.Money })
+}
+
+func (s *SmartContract) WithdrawMoney(ctx contractapi.TransactionContextInterface, id string, amount string) error {
+	money, err := parsePositiveAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	person, err := s.GetPerson(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = assertPersonOrRole(ctx, person, "withdraw money")
+	if err != nil {
+		return err
+	}
+
+	if person.Money.LessThan(money) {
+		return fmt.Errorf("Person does not have enough money!")
+	}
+
+	person.Money, err = person.Money.Sub(money)
+	if err != nil {
+		return err
+	}
+
+	err = putPerson(ctx, person)
+	if err != nil {
+		return err
+	}
+
+	withdrawn := Money { Amount: -money.Amount, Currency: money.Currency }
+	return emitEvent(ctx, EventBalanceChanged, BalanceChangedEvent { PersonID: id, Change: withdrawn, Balance: person.Money })
+}
+
 func (s *SmartContract) GetCar(ctx contractapi.TransactionContextInterface, id string) (*Car, error) {
-	carJson, err := ctx.GetStub().GetState(id)
+	key, err := carKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
+	carJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load car from world state: %v", err)
+	}
 	if carJson == nil {
 		return nil, fmt.Errorf("Car with id %s does not exist!", id)
 	}
-	if err != nil {
-		return nil, fmt.Errorf("Failed to load person from world state: %v", err)
-	}
 
 	var car Car
 	err = json.Unmarshal(carJson, &car)
@@ -139,10 +337,218 @@ func (s *SmartContract) GetCar(ctx contractapi.TransactionContextInterface, id s
 		return nil, err
 	}
 
+	if car.DocType != carDocType {
+		return nil, fmt.Errorf("Record with id %s is not a car!", id)
+	}
+
+	if car.Status == "" {
+		car.Status = CarStatusActive
+	}
+
 	return &car, nil
 }
 
-func (s *SmartContract) GetCarsByColor(ctx contractapi.TransactionContextInterface, color string) ([]*Car, error) {
+func (s *SmartContract) CreateCar(ctx contractapi.TransactionContextInterface, id string, brand string, model string, year int, color string, ownerId string, price string) error {
+	err := assertRole(ctx, "create cars", RoleDealer, RoleRegistry)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("Car id must not be empty!")
+	}
+	if strings.TrimSpace(color) == "" {
+		return fmt.Errorf("Car color must not be empty!")
+	}
+
+	carPrice, err := parsePositiveAmount(price)
+	if err != nil {
+		return err
+	}
+
+	err = validateCarDetails(ctx, brand, model, year)
+	if err != nil {
+		return err
+	}
+
+	key, err := carKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("Car with id %s already exists!", id)
+	}
+
+	personExists, err := s.OwnerExists(ctx, ownerId)
+	if err != nil {
+		return err
+	}
+	if !personExists {
+		return fmt.Errorf("Person with id %s does not exist!", ownerId)
+	}
+
+	car := Car {
+		ID: id,
+		Brand: brand,
+		Model: model,
+		Year: year,
+		Color: color,
+		Owner: ownerId,
+		Malfunctions: []Malfunction{},
+		Price: carPrice,
+		Status: CarStatusActive,
+	}
+
+	err = putCar(ctx, &car)
+	if err != nil {
+		return err
+	}
+
+	err = putColorIndex(ctx, &car)
+	if err != nil {
+		return err
+	}
+
+	err = putQueryIndexes(ctx, &car)
+	if err != nil {
+		return err
+	}
+
+	err = putOwnerIndex(ctx, &car)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCarCreated, CarCreatedEvent { CarID: id, Owner: ownerId, Price: carPrice })
+}
+
+func (s *SmartContract) UpdateCar(ctx contractapi.TransactionContextInterface, id string, brand string, model string, year int, price string) error {
+	return s.updateCar(ctx, id, brand, model, year, price, nil)
+}
+
+// UpdateCarAndColor replaces the details and the color of the car in one
+// transaction. A new color needs the owner, like ChangeColor.
+func (s *SmartContract) UpdateCarAndColor(ctx contractapi.TransactionContextInterface, id string, brand string, model string, year int, price string, color string) error {
+	if strings.TrimSpace(color) == "" {
+		return fmt.Errorf("Car color must not be empty!")
+	}
+
+	return s.updateCar(ctx, id, brand, model, year, price, &color)
+}
+
+// updateCar replaces the details of the car, and its color when color is not
+// nil. A transaction that only changes the color emits ColorChanged.
+func (s *SmartContract) updateCar(ctx contractapi.TransactionContextInterface, id string, brand string, model string, year int, price string, color *string) error {
+	car, err := s.GetCar(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = assertCarActive(car)
+	if err != nil {
+		return err
+	}
+
+	colorChanged := color != nil && *color != car.Color
+	if colorChanged {
+		err = s.assertCarOwnerOrRole(ctx, car, "change the color of the car")
+	} else {
+		err = s.assertCarOwnerOrRole(ctx, car, "update the car", RoleRegistry)
+	}
+	if err != nil {
+		return err
+	}
+
+	carPrice, err := parsePositiveAmount(price)
+	if err != nil {
+		return err
+	}
+
+	err = validateCarDetails(ctx, brand, model, year)
+	if err != nil {
+		return err
+	}
+
+	detailsChanged := brand != car.Brand || model != car.Model || year != car.Year || carPrice != car.Price
+	prevColor := car.Color
+
+	err = deleteQueryIndexes(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	if colorChanged {
+		err = deleteColorIndex(ctx, car)
+		if err != nil {
+			return err
+		}
+
+		car.Color = *color
+	}
+
+	car.Brand = brand
+	car.Model = model
+	car.Year = year
+	car.Price = carPrice
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = putQueryIndexes(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	if colorChanged {
+		err = putColorIndex(ctx, car)
+		if err != nil {
+			return err
+		}
+
+		if !detailsChanged {
+			return emitEvent(ctx, EventColorChanged, ColorChangedEvent { CarID: id, Owner: car.Owner, PreviousColor: prevColor, Color: car.Color })
+		}
+	}
+
+	return emitEvent(ctx, EventCarUpdated, CarUpdatedEvent {
+		CarID: id,
+		Owner: car.Owner,
+		Brand: brand,
+		Model: model,
+		Year: year,
+		Price: carPrice,
+		Color: car.Color,
+	})
+}
+
+func (s *SmartContract) ScrapCar(ctx contractapi.TransactionContextInterface, id string) error {
+	car, err := s.GetCar(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if car.Status == CarStatusScrapped {
+		return fmt.Errorf("Car with id %s is already scrapped!", id)
+	}
+
+	err = s.assertCarOwnerOrRole(ctx, car, "scrap the car", RoleRegistry)
+	if err != nil {
+		return err
+	}
+
+	return s.scrapCar(ctx, car, "scrapped by owner")
+}
+
+// GetCarsByColor returns the cars of the given color. Written-off cars are
+// left out unless includeWrittenOff is set.
+func (s *SmartContract) GetCarsByColor(ctx contractapi.TransactionContextInterface, color string, includeWrittenOff bool) ([]*Car, error) {
 	carsIter, err := ctx.GetStub().GetStateByPartialCompositeKey("color~owner~ID", []string{color})
 	if err != nil {
 		return nil, err
@@ -167,13 +573,19 @@ func (s *SmartContract) GetCarsByColor(ctx contractapi.TransactionContextInterfa
 			return nil, err
 		}
 
+		if car.Status == CarStatusWrittenOff && !includeWrittenOff {
+			continue
+		}
+
 		cars = append(cars, car)
 	}
 
 	return cars, nil
 }
 
-func (s *SmartContract) GetCarsByOwnerAndColor(ctx contractapi.TransactionContextInterface, ownerId string, color string) ([]*Car, error) {
+// GetCarsByOwnerAndColor returns the owner's cars of the given color. Written-off
+// cars are left out unless includeWrittenOff is set.
+func (s *SmartContract) GetCarsByOwnerAndColor(ctx contractapi.TransactionContextInterface, ownerId string, color string, includeWrittenOff bool) ([]*Car, error) {
 	personExists, err := s.OwnerExists(ctx, ownerId)
 	if err != nil {
 		return nil, err
@@ -207,6 +619,10 @@ func (s *SmartContract) GetCarsByOwnerAndColor(ctx contractapi.TransactionContex
 			return nil, err
 		}
 
+		if car.Status == CarStatusWrittenOff && !includeWrittenOff {
+			continue
+		}
+
 		cars = append(cars, car)
 	}
 
@@ -214,12 +630,26 @@ func (s *SmartContract) GetCarsByOwnerAndColor(ctx contractapi.TransactionContex
 }
 
 func (s *SmartContract) OwnerExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	owner, err := ctx.GetStub().GetState(id)
+	key, err := personKey(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	ownerJson, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("Failed to get person: %v", err)
 	}
+	if ownerJson == nil {
+		return false, nil
+	}
+
+	var owner Person
+	err = json.Unmarshal(ownerJson, &owner)
+	if err != nil {
+		return false, err
+	}
 
-	return owner != nil, nil
+	return owner.DocType == personDocType, nil
 }
 
 func (s *SmartContract) ChangeColor(ctx contractapi.TransactionContextInterface, carId string, color string) (bool, error) {
@@ -228,15 +658,24 @@ func (s *SmartContract) ChangeColor(ctx contractapi.TransactionContextInterface,
 		return false, err
 	}
 
-	prevColor := car.Color
+	err = assertCarActive(car)
+	if err != nil {
+		return false, err
+	}
 
-	car.Color = color
-	carJson, err := json.Marshal(car)
+	err = s.assertCarOwnerOrRole(ctx, car, "change the color of the car")
 	if err != nil {
 		return false, err
 	}
 
-	err = ctx.GetStub().PutState(carId, carJson)
+	if strings.TrimSpace(color) == "" {
+		return false, fmt.Errorf("Car color must not be empty!")
+	}
+
+	prevColor := car.Color
+
+	car.Color = color
+	err = putCar(ctx, car)
 	if err != nil {
 		return false, err
 	}
@@ -262,13 +701,23 @@ func (s *SmartContract) ChangeColor(ctx contractapi.TransactionContextInterface,
 		return false, err
 	}
 
+	err = emitEvent(ctx, EventColorChanged, ColorChangedEvent { CarID: carId, Owner: car.Owner, PreviousColor: prevColor, Color: color })
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
-func (s *SmartContract) AddNewMalfunction(ctx contractapi.TransactionContextInterface, carId string, description string, price float32) error {
+func (s *SmartContract) AddNewMalfunction(ctx contractapi.TransactionContextInterface, carId string, description string, price string) error {
+	malfunctionPrice, err := parsePositiveAmount(price)
+	if err != nil {
+		return err
+	}
+
 	malfunction := Malfunction {
 		Description: description,
-		Price: price,
+		Price: malfunctionPrice,
 	}
 
 	car, err := s.GetCar(ctx, carId)
@@ -276,68 +725,74 @@ func (s *SmartContract) AddNewMalfunction(ctx contractapi.TransactionContextInte
 		return err
 	}
 
-	car.Malfunctions = append(car.Malfunctions, malfunction)
-
-	repairPrice := float32(0)
-	for _, malfunction := range car.Malfunctions {
-		repairPrice += malfunction.Price
+	err = assertCarActive(car)
+	if err != nil {
+		return err
 	}
 
-	if repairPrice > car.Price {
-		return ctx.GetStub().DelState(carId)
-	} else {
-		carJson, err := json.Marshal(car)
-		if err != nil {
-			return err
-		}
+	err = s.assertCarOwnerOrRole(ctx, car, "report malfunctions of the car", RoleMechanic)
+	if err != nil {
+		return err
+	}
 
-		err = ctx.GetStub().PutState(carId, carJson)
-		if err != nil {
-			return err
-		}
+	car.Malfunctions = append(car.Malfunctions, malfunction)
 
-		return nil
+	repairPrice, err := sumMalfunctions(car.Malfunctions, car.Price.Currency)
+	if err != nil {
+		return err
 	}
-}
 
-func (s *SmartContract) RepairCar(ctx contractapi.TransactionContextInterface, carId string) (bool, error) {
-	car, err := s.GetCar(ctx, carId)
+	writeOff, err := s.GetWriteOffConfig(ctx)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	owner, err := s.GetPerson(ctx, car.Owner)
-	if err != nil {
-		return false, err
+	if exceedsThreshold(repairPrice, car.Price, writeOff.ThresholdPercent) {
+		return s.writeOffCar(ctx, car, repairPrice, writeOff.ThresholdPercent)
 	}
 
-	toPayForRepairement := float32(0)
-	for _, malfunction := range car.Malfunctions {
-		toPayForRepairement += malfunction.Price
-		if owner.Money < toPayForRepairement {
-			return false, fmt.Errorf("Owner does not have enough money to pay!")
-		}
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
 	}
 
-	car.Malfunctions = []Malfunction{}
-	owner.Money -= toPayForRepairement
+	return emitEvent(ctx, EventMalfunctionReported, MalfunctionReportedEvent {
+		CarID: carId,
+		Owner: car.Owner,
+		Malfunction: malfunction,
+		TotalRepairCost: repairPrice,
+	})
+}
 
-	carJson, err := json.Marshal(car)
+// BuyCar buys a listed car for its asking price. The listing is the seller's
+// consent and submitting BuyCar is the buyer's, so the sale settles at once.
+// answer must be "yes" when the car has malfunctions.
+func (s *SmartContract) BuyCar(ctx contractapi.TransactionContextInterface, carId string, buyerId string, answer string) (bool, error) {
+	car, listing, err := s.getOpenListing(ctx, carId)
 	if err != nil {
 		return false, err
 	}
 
-	ownerJson, err := json.Marshal(owner)
+	buyer, err := s.GetPerson(ctx, buyerId)
 	if err != nil {
 		return false, err
 	}
 
-	err = ctx.GetStub().PutState(carId, carJson)
+	err = assertPersonOrRole(ctx, buyer, "buy cars for the buyer")
 	if err != nil {
 		return false, err
 	}
 
-	err = ctx.GetStub().PutState(owner.ID, ownerJson)
+	if car.Owner == buyer.ID {
+		return false, fmt.Errorf("Buyer is already owner of the car!")
+	}
+
+	okayWithMalfunctions := answer == "yes"
+	if len(car.Malfunctions) > 0 && !okayWithMalfunctions {
+		return false, fmt.Errorf("Buyer does not want to buy the car.")
+	}
+
+	err = s.settleSale(ctx, car, buyerId, listing.AskingPrice)
 	if err != nil {
 		return false, err
 	}
@@ -345,110 +800,171 @@ func (s *SmartContract) RepairCar(ctx contractapi.TransactionContextInterface, c
 	return true, nil
 }
 
-func (s *SmartContract) BuyCar(ctx contractapi.TransactionContextInterface, carId string, buyerId string, answer string) (bool, error) {
-	car, err := s.GetCar(ctx, carId)
-	if err != nil {
-		return false, err
-	}
+func personKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(personKeyType, []string{id})
+}
 
-	buyer, err := s.GetPerson(ctx, buyerId)
+func carKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(carKeyType, []string{id})
+}
+
+func putPerson(ctx contractapi.TransactionContextInterface, person *Person) error {
+	key, err := personKey(ctx, person.ID)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	var okayWithMalfunctions bool
-	if answer == "yes" {
-		okayWithMalfunctions = true
-	} else if answer == "no" {
-		okayWithMalfunctions = false
+	person.DocType = personDocType
+	personJson, err := json.Marshal(person)
+	if err != nil {
+		return err
 	}
 
-	currentOwner, err := s.GetPerson(ctx, car.Owner)
+	err = ctx.GetStub().PutState(key, personJson)
 	if err != nil {
-		return false, err
+		return fmt.Errorf("Failed to put to world state! %v", err)
 	}
 
-	if car.Owner == buyer.ID {
-		return false, fmt.Errorf("Buyer is already owner of the car!")
+	return nil
+}
+
+func validatePersonDetails(name string, surname string, email string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("Person name must not be empty!")
+	}
+	if strings.TrimSpace(surname) == "" {
+		return fmt.Errorf("Person surname must not be empty!")
 	}
 
-	carPrice := float32(0)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return fmt.Errorf("Email %s is not valid!", email)
+	}
 
-	if car.Malfunctions == nil || len(car.Malfunctions) == 0 {
-		carPrice = car.Price
-	} else if okayWithMalfunctions {
-		moneyForMalfunctions := float32(0)
-		for _, malfunction := range car.Malfunctions {
-			moneyForMalfunctions += malfunction.Price
-		}
+	return nil
+}
 
-		carPrice = car.Price - moneyForMalfunctions
-	} else {
-		return false, fmt.Errorf("Buyer does not want to buy the car.")
+func putCar(ctx contractapi.TransactionContextInterface, car *Car) error {
+	key, err := carKey(ctx, car.ID)
+	if err != nil {
+		return err
 	}
 
-	car.Owner = buyerId
+	car.DocType = carDocType
+	carJson, err := json.Marshal(car)
+	if err != nil {
+		return err
+	}
 
-	if buyer.Money >= carPrice {
-		currentOwner.Money += carPrice
-		buyer.Money -= carPrice
-	} else {
-		return false, fmt.Errorf("Buyer does not have enough money!")
+	err = ctx.GetStub().PutState(key, carJson)
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state! %v", err)
 	}
 
-	carJson, err := json.Marshal(car)
+	return nil
+}
+
+func putColorIndex(ctx contractapi.TransactionContextInterface, car *Car) error {
+	key, err := ctx.GetStub().CreateCompositeKey(colorOwnerIndex, []string{car.Color, car.Owner, car.ID})
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	err = ctx.GetStub().PutState(carId, carJson)
+	value := []byte{0x00}
+	return ctx.GetStub().PutState(key, value)
+}
+
+func deleteColorIndex(ctx contractapi.TransactionContextInterface, car *Car) error {
+	key, err := ctx.GetStub().CreateCompositeKey(colorOwnerIndex, []string{car.Color, car.Owner, car.ID})
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	buyerJson, err := json.Marshal(buyer)
+	return ctx.GetStub().DelState(key)
+}
+
+// scrapCar retires the car: the record stays on the ledger with the scrapped
+// status so its history is kept, but it is removed from the color, query and
+// owner indexes and its open sale and repair orders are closed.
+func (s *SmartContract) scrapCar(ctx contractapi.TransactionContextInterface, car *Car, reason string) error {
+	car.Status = CarStatusScrapped
+
+	err := putCar(ctx, car)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	err = ctx.GetStub().PutState(buyerId, buyerJson)
+	err = s.cancelOpenRepairOrders(ctx, car.ID)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	sellerJson, err := json.Marshal(currentOwner)
+	err = deleteColorIndex(ctx, car)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	err = ctx.GetStub().PutState(currentOwner.ID, sellerJson) 
+	err = deleteQueryIndexes(ctx, car)
 	if err != nil {
-		return false, nil
+		return err
 	}
 
-	colorBuyerIndexKey, err := ctx.GetStub().CreateCompositeKey("color~owner~ID", []string{car.Color, buyerId, car.ID})
+	err = deleteOwnerIndex(ctx, car)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	value := []byte{0x00}
-	err = ctx.GetStub().PutState(colorBuyerIndexKey, value)
+	err = deleteSaleRecords(ctx, car.ID)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	colorSellerIndexKey, err := ctx.GetStub().CreateCompositeKey("color~owner~ID", []string{car.Color, currentOwner.ID, car.ID})
+	return emitEvent(ctx, EventCarScrapped, CarScrappedEvent { CarID: car.ID, Owner: car.Owner, Reason: reason })
+}
+
+func assertCarActive(car *Car) error {
+	switch car.Status {
+	case CarStatusScrapped:
+		return fmt.Errorf("Car with id %s is scrapped!", car.ID)
+	case CarStatusWrittenOff:
+		return fmt.Errorf("Car with id %s is written off!", car.ID)
+	}
+
+	return nil
+}
+
+func validateCarDetails(ctx contractapi.TransactionContextInterface, brand string, model string, year int) error {
+	if strings.TrimSpace(brand) == "" {
+		return fmt.Errorf("Car brand must not be empty!")
+	}
+	if strings.TrimSpace(model) == "" {
+		return fmt.Errorf("Car model must not be empty!")
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return false, err
+		return err
+	}
+
+	maxYear := time.Unix(txTimestamp.GetSeconds(), 0).UTC().Year() + 1
+	if year < firstCarYear || year > maxYear {
+		return fmt.Errorf("Car year must be between %d and %d!", firstCarYear, maxYear)
 	}
 
-	err = ctx.GetStub().DelState(colorSellerIndexKey)
+	return nil
+}
+
+func parsePositiveAmount(value string) (Money, error) {
+	amount, err := ParseMoney(value)
 	if err != nil {
-		return false, err
+		return Money{}, err
 	}
 
-	return true, nil
+	if !amount.IsPositive() {
+		return Money{}, fmt.Errorf("Amount must be greater than zero!")
+	}
 
+	return amount, nil
 }
 
 func main() {
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractMetadata(t *testing.T) {
+	_, err := contractapi.NewChaincode(new(SmartContract))
+	require.NoError(t, err)
+}
+
+func TestInitLedger(t *testing.T) {
+	l := newTestLedger(t)
+
+	err := l.contract.InitLedger(l.tx(registryClient))
+	require.NoError(t, err)
+
+	petar := l.person("1")
+	require.Equal(t, "Petrovic", petar.Surname)
+	require.Equal(t, NewMoney(7700_00), petar.Money)
+	require.Empty(t, petar.ClientID)
+
+	car := l.car("c1")
+	require.Equal(t, "Jeep", car.Brand)
+	require.Equal(t, CarStatusActive, car.Status)
+	require.Len(t, car.Malfunctions, 2)
+
+	cars, err := l.contract.GetCarsByColor(l.tx(strangerClient), "black", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c3"}, carIds(cars))
+}
+
+func TestInitLedgerAccess(t *testing.T) {
+	l := newTestLedger(t)
+
+	err := l.contract.InitLedger(l.tx(strangerClient))
+	require.EqualError(t, err, "Submitting client is not allowed to initialize the ledger!")
+
+	err = l.contract.InitLedger(l.tx(adminClient))
+	require.NoError(t, err)
+
+	err = l.contract.LinkPersonIdentity(l.tx(registryClient), "1", petarClient.mspId, petarClient.id)
+	require.NoError(t, err)
+
+	err = l.contract.InitLedger(l.tx(registryClient))
+	require.EqualError(t, err, "Ledger is already initialized!")
+	require.Equal(t, petarClient.id, l.person("1").ClientID)
+}
+
+func TestGetPerson(t *testing.T) {
+	l := newSeededLedger(t)
+
+	_, err := l.contract.GetPerson(l.tx(strangerClient), "42")
+	require.EqualError(t, err, "Person with id 42 does not exist!")
+}
+
+func TestCreatePerson(t *testing.T) {
+	l := newSeededLedger(t)
+
+	newcomer := &testIdentity { mspId: "Org4MSP", id: "jovana" }
+	err := l.contract.CreatePerson(l.tx(newcomer), "4", "Jovana", "Jovanovic", "jovana@gmail.com")
+	require.NoError(t, err)
+	l.requireEvent(EventPersonRegistered, nil)
+
+	person, err := l.contract.GetCallerPerson(l.tx(newcomer))
+	require.NoError(t, err)
+	require.Equal(t, "4", person.ID)
+	require.Equal(t, NewMoney(0), person.Money)
+
+	err = l.contract.CreatePerson(l.tx(registryClient), "5", "Ana", "Anic", "ana@gmail.com")
+	require.NoError(t, err)
+	require.Empty(t, l.person("5").ClientID)
+
+	err = l.contract.CreatePerson(l.tx(registryClient), "1", "Petar", "Petrovic", "petar@gmail.com")
+	require.EqualError(t, err, "Person with id 1 already exists!")
+
+	err = l.contract.CreatePerson(l.tx(newcomer), "6", "Jovana", "Jovanovic", "jovana@gmail.com")
+	require.EqualError(t, err, "Client identity is already linked to person 4!")
+
+	err = l.contract.CreatePerson(l.tx(registryClient), "7", "Ana", "Anic", "not an email")
+	require.EqualError(t, err, "Email not an email is not valid!")
+
+	err = l.contract.CreatePerson(l.tx(registryClient), " ", "Ana", "Anic", "ana@gmail.com")
+	require.EqualError(t, err, "Person id must not be empty!")
+}
+
+func TestUpdatePerson(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.UpdatePerson(l.tx(petarClient), "1", "Petar", "Petrovic", "petar.petrovic@gmail.com")
+	require.NoError(t, err)
+	l.requireEvent(EventPersonUpdated, nil)
+	require.Equal(t, "petar.petrovic@gmail.com", l.person("1").Email)
+
+	err = l.contract.UpdatePerson(l.tx(registryClient), "1", "Pera", "Petrovic", "petar@gmail.com")
+	require.NoError(t, err)
+	require.Equal(t, "Pera", l.person("1").Name)
+
+	err = l.contract.UpdatePerson(l.tx(markoClient), "1", "Petar", "Petrovic", "petar@gmail.com")
+	require.EqualError(t, err, "Submitting client is not allowed to update the person!")
+
+	err = l.contract.UpdatePerson(l.tx(petarClient), "1", "", "Petrovic", "petar@gmail.com")
+	require.EqualError(t, err, "Person name must not be empty!")
+}
+
+func TestDepositMoney(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.DepositMoney(l.tx(petarClient), "1", "100.50")
+	require.NoError(t, err)
+
+	var event BalanceChangedEvent
+	l.requireEvent(EventBalanceChanged, &event)
+	require.Equal(t, NewMoney(100_50), event.Change)
+	require.Equal(t, NewMoney(7800_50), event.Balance)
+	require.Equal(t, int64(7800_50), l.balance("1"))
+
+	err = l.contract.DepositMoney(l.tx(registryClient), "1", "0.50")
+	require.NoError(t, err)
+	require.Equal(t, int64(7801_00), l.balance("1"))
+
+	err = l.contract.DepositMoney(l.tx(markoClient), "1", "10")
+	require.EqualError(t, err, "Submitting client is not allowed to deposit money!")
+
+	err = l.contract.DepositMoney(l.tx(petarClient), "1", "-10")
+	require.EqualError(t, err, "Amount must be greater than zero!")
+
+	err = l.contract.DepositMoney(l.tx(petarClient), "1", "1.005")
+	require.Error(t, err)
+}
+
+func TestWithdrawMoney(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.WithdrawMoney(l.tx(markoClient), "2", "850")
+	require.NoError(t, err)
+
+	var event BalanceChangedEvent
+	l.requireEvent(EventBalanceChanged, &event)
+	require.Equal(t, int64(-850_00), event.Change.Amount)
+	require.Equal(t, int64(2000_00), l.balance("2"))
+
+	err = l.contract.WithdrawMoney(l.tx(markoClient), "2", "2000.01")
+	require.EqualError(t, err, "Person does not have enough money!")
+
+	err = l.contract.WithdrawMoney(l.tx(registryClient), "2", "10")
+	require.EqualError(t, err, "Submitting client is not allowed to withdraw money!")
+}
+
+func TestGetCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	_, err := l.contract.GetCar(l.tx(strangerClient), "c42")
+	require.EqualError(t, err, "Car with id c42 does not exist!")
+}
+
+func TestCreateCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.CreateCar(l.tx(dealerClient), "c7", "Skoda", "Octavia", 2020, "green", "3", "3100")
+	require.NoError(t, err)
+
+	var event CarCreatedEvent
+	l.requireEvent(EventCarCreated, &event)
+	require.Equal(t, CarCreatedEvent { CarID: "c7", Owner: "3", Price: NewMoney(3100_00) }, event)
+
+	car := l.car("c7")
+	require.Equal(t, CarStatusActive, car.Status)
+	require.Empty(t, car.Malfunctions)
+
+	cars, err := l.contract.GetCarsByOwnerAndColor(l.tx(strangerClient), "3", "green", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c7"}, carIds(cars))
+
+	err = l.contract.CreateCar(l.tx(registryClient), "c8", "Skoda", "Fabia", 2018, "green", "3", "1500")
+	require.NoError(t, err)
+
+	err = l.contract.CreateCar(l.tx(petarClient), "c9", "Skoda", "Fabia", 2018, "green", "1", "1500")
+	require.EqualError(t, err, "Submitting client is not allowed to create cars!")
+
+	err = l.contract.CreateCar(l.tx(dealerClient), "c1", "Skoda", "Fabia", 2018, "green", "1", "1500")
+	require.EqualError(t, err, "Car with id c1 already exists!")
+
+	err = l.contract.CreateCar(l.tx(dealerClient), "c9", "Skoda", "Fabia", 2018, "green", "42", "1500")
+	require.EqualError(t, err, "Person with id 42 does not exist!")
+
+	err = l.contract.CreateCar(l.tx(dealerClient), "c9", "Skoda", "Fabia", 1800, "green", "1", "1500")
+	require.EqualError(t, err, "Car year must be between 1886 and 2022!")
+
+	err = l.contract.CreateCar(l.tx(dealerClient), "c9", "Skoda", "Fabia", 2018, "", "1", "1500")
+	require.EqualError(t, err, "Car color must not be empty!")
+
+	err = l.contract.CreateCar(l.tx(dealerClient), "c9", "Skoda", "Fabia", 2018, "green", "1", "0")
+	require.EqualError(t, err, "Amount must be greater than zero!")
+}
+
+func TestUpdateCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.UpdateCar(l.tx(petarClient), "c1", "Jeep", "Compass", 2016, "5000")
+	require.NoError(t, err)
+	l.requireEvent(EventCarUpdated, nil)
+
+	car := l.car("c1")
+	require.Equal(t, "Compass", car.Model)
+	require.Equal(t, 2016, car.Year)
+	require.Equal(t, NewMoney(5000_00), car.Price)
+
+	err = l.contract.UpdateCar(l.tx(registryClient), "c1", "Jeep", "Compass", 2016, "4900")
+	require.NoError(t, err)
+
+	err = l.contract.UpdateCar(l.tx(markoClient), "c1", "Jeep", "Compass", 2016, "1")
+	require.EqualError(t, err, "Submitting client is not allowed to update the car!")
+
+	err = l.contract.UpdateCar(l.tx(petarClient), "c1", "", "Compass", 2016, "4900")
+	require.EqualError(t, err, "Car brand must not be empty!")
+}
+
+func TestUpdateCarAndColor(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.UpdateCarAndColor(l.tx(petarClient), "c1", "Jeep", "Compass", 2016, "5000", "orange")
+	require.NoError(t, err)
+
+	var updated CarUpdatedEvent
+	l.requireEvent(EventCarUpdated, &updated)
+	require.Equal(t, "orange", updated.Color)
+	require.Equal(t, "1", updated.Owner)
+
+	car := l.car("c1")
+	require.Equal(t, "Compass", car.Model)
+	require.Equal(t, "orange", car.Color)
+	require.Equal(t, NewMoney(5000_00), car.Price)
+
+	cars, err := l.contract.GetCarsByColor(l.tx(strangerClient), "orange", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, carIds(cars))
+
+	err = l.contract.UpdateCarAndColor(l.tx(petarClient), "c1", "Jeep", "Compass", 2016, "5000", "red")
+	require.NoError(t, err)
+
+	var colorChanged ColorChangedEvent
+	l.requireEvent(EventColorChanged, &colorChanged)
+	require.Equal(t, ColorChangedEvent{ CarID: "c1", Owner: "1", PreviousColor: "orange", Color: "red" }, colorChanged)
+
+	cars, err = l.contract.GetCarsByColor(l.tx(strangerClient), "orange", false)
+	require.NoError(t, err)
+	require.Empty(t, cars)
+
+	err = l.contract.UpdateCarAndColor(l.tx(registryClient), "c1", "Jeep", "Compass", 2016, "4900", "red")
+	require.NoError(t, err)
+
+	err = l.contract.UpdateCarAndColor(l.tx(registryClient), "c1", "Jeep", "Compass", 2016, "4900", "blue")
+	require.EqualError(t, err, "Submitting client is not allowed to change the color of the car!")
+
+	err = l.contract.UpdateCarAndColor(l.tx(petarClient), "c1", "Jeep", "Compass", 2016, "4900", " ")
+	require.EqualError(t, err, "Car color must not be empty!")
+	require.Equal(t, "red", l.car("c1").Color)
+}
+
+func TestScrapCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.ScrapCar(l.tx(stefanClient), "c3")
+	require.EqualError(t, err, "Submitting client is not allowed to scrap the car!")
+
+	err = l.contract.ScrapCar(l.tx(markoClient), "c3")
+	require.NoError(t, err)
+
+	var event CarScrappedEvent
+	l.requireEvent(EventCarScrapped, &event)
+	require.Equal(t, "scrapped by owner", event.Reason)
+
+	require.Equal(t, CarStatusScrapped, l.car("c3").Status)
+
+	cars, err := l.contract.GetCarsByColor(l.tx(strangerClient), "black", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, carIds(cars))
+
+	_, err = l.contract.GetSaleListing(l.tx(strangerClient), "c3")
+	require.EqualError(t, err, "Car with id c3 is not listed for sale!")
+
+	err = l.contract.ScrapCar(l.tx(markoClient), "c3")
+	require.EqualError(t, err, "Car with id c3 is already scrapped!")
+
+	_, err = l.contract.ChangeColor(l.tx(markoClient), "c3", "red")
+	require.EqualError(t, err, "Car with id c3 is scrapped!")
+}
+
+func TestGetCarsByColor(t *testing.T) {
+	l := newSeededLedger(t)
+
+	cars, err := l.contract.GetCarsByColor(l.tx(strangerClient), "white", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c5"}, carIds(cars))
+
+	cars, err = l.contract.GetCarsByColor(l.tx(strangerClient), "purple", false)
+	require.NoError(t, err)
+	require.Empty(t, cars)
+}
+
+func TestGetCarsByOwnerAndColor(t *testing.T) {
+	l := newSeededLedger(t)
+
+	cars, err := l.contract.GetCarsByOwnerAndColor(l.tx(strangerClient), "1", "black", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, carIds(cars))
+
+	cars, err = l.contract.GetCarsByOwnerAndColor(l.tx(strangerClient), "3", "black", false)
+	require.NoError(t, err)
+	require.Empty(t, cars)
+
+	_, err = l.contract.GetCarsByOwnerAndColor(l.tx(strangerClient), "42", "black", false)
+	require.EqualError(t, err, "Person with id 42 does not exist!")
+}
+
+func TestOwnerExists(t *testing.T) {
+	l := newSeededLedger(t)
+
+	exists, err := l.contract.OwnerExists(l.tx(strangerClient), "1")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = l.contract.OwnerExists(l.tx(strangerClient), "42")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestChangeColor(t *testing.T) {
+	l := newSeededLedger(t)
+
+	changed, err := l.contract.ChangeColor(l.tx(petarClient), "c1", "green")
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	var event ColorChangedEvent
+	l.requireEvent(EventColorChanged, &event)
+	require.Equal(t, ColorChangedEvent { CarID: "c1", Owner: "1", PreviousColor: "black", Color: "green" }, event)
+
+	cars, err := l.contract.GetCarsByColor(l.tx(strangerClient), "black", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c3"}, carIds(cars))
+
+	cars, err = l.contract.GetCarsByOwnerAndColor(l.tx(strangerClient), "1", "green", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, carIds(cars))
+
+	_, err = l.contract.ChangeColor(l.tx(registryClient), "c1", "red")
+	require.EqualError(t, err, "Submitting client is not allowed to change the color of the car!")
+
+	_, err = l.contract.ChangeColor(l.tx(petarClient), "c1", " ")
+	require.EqualError(t, err, "Car color must not be empty!")
+}
+
+func TestAddNewMalfunction(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.AddNewMalfunction(l.tx(markoClient), "c3", "Zamena guma", "120")
+	require.NoError(t, err)
+
+	var event MalfunctionReportedEvent
+	l.requireEvent(EventMalfunctionReported, &event)
+	require.Equal(t, NewMoney(120_00), event.TotalRepairCost)
+
+	err = l.contract.AddNewMalfunction(l.tx(mechanicClient), "c3", "Zamena ulja", "30.50")
+	require.NoError(t, err)
+
+	car := l.car("c3")
+	require.Equal(t, []Malfunction{
+		{ Description: "Zamena guma", Price: NewMoney(120_00) },
+		{ Description: "Zamena ulja", Price: NewMoney(30_50) },
+	}, car.Malfunctions)
+	require.Equal(t, CarStatusActive, car.Status)
+
+	err = l.contract.AddNewMalfunction(l.tx(stefanClient), "c3", "Ogrebotina", "10")
+	require.EqualError(t, err, "Submitting client is not allowed to report malfunctions of the car!")
+
+	err = l.contract.AddNewMalfunction(l.tx(markoClient), "c3", "Ogrebotina", "abc")
+	require.Error(t, err)
+}
+
+func TestBuyCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	bought, err := l.contract.BuyCar(l.tx(petarClient), "c3", "1", "no")
+	require.NoError(t, err)
+	require.True(t, bought)
+
+	var event CarSoldEvent
+	l.requireEvent(EventCarSold, &event)
+	require.Equal(t, CarSoldEvent { CarID: "c3", Seller: "2", Buyer: "1", Price: NewMoney(4000_00) }, event)
+
+	require.Equal(t, "1", l.car("c3").Owner)
+	require.Equal(t, int64(3700_00), l.balance("1"))
+	require.Equal(t, int64(6850_00), l.balance("2"))
+
+	cars, err := l.contract.GetCarsByOwnerAndColor(l.tx(strangerClient), "1", "black", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c3"}, carIds(cars))
+
+	_, err = l.contract.GetSaleListing(l.tx(strangerClient), "c3")
+	require.EqualError(t, err, "Car with id c3 is not listed for sale!")
+}
+
+func TestBuyCarWithMalfunctions(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c5", "2000", "")
+	require.NoError(t, err)
+
+	for _, answer := range []string{"no", "maybe", "YES", ""} {
+		_, err = l.contract.BuyCar(l.tx(petarClient), "c5", "1", answer)
+		require.EqualError(t, err, "Buyer does not want to buy the car.", "answer %q", answer)
+	}
+	require.Equal(t, "2", l.car("c5").Owner)
+
+	bought, err := l.contract.BuyCar(l.tx(petarClient), "c5", "1", "yes")
+	require.NoError(t, err)
+	require.True(t, bought)
+	require.Equal(t, "1", l.car("c5").Owner)
+	require.Len(t, l.car("c5").Malfunctions, 1)
+}
+
+func TestBuyCarSameOwner(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(petarClient), "c1", "5000", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(petarClient), "c1", "1", "yes")
+	require.EqualError(t, err, "Buyer is already owner of the car!")
+	require.Equal(t, int64(7700_00), l.balance("1"))
+}
+
+func TestBuyCarInsufficientFunds(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(petarClient), "c1", "5000", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(markoClient), "c1", "2", "yes")
+	require.EqualError(t, err, "Buyer does not have enough money!")
+
+	require.Equal(t, "1", l.car("c1").Owner)
+	require.Equal(t, int64(2850_00), l.balance("2"))
+	require.Equal(t, int64(7700_00), l.balance("1"))
+}
+
+func TestBuyCarRequiresListingAndBuyer(t *testing.T) {
+	l := newSeededLedger(t)
+
+	_, err := l.contract.BuyCar(l.tx(petarClient), "c3", "1", "yes")
+	require.EqualError(t, err, "Car with id c3 is not listed for sale!")
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(stefanClient), "c3", "1", "yes")
+	require.EqualError(t, err, "Submitting client is not allowed to buy cars for the buyer!")
+
+	_, err = l.contract.BuyCar(l.tx(petarClient), "c3", "42", "yes")
+	require.EqualError(t, err, "Person with id 42 does not exist!")
+
+	_, err = l.contract.BuyCar(l.tx(petarClient), "c42", "1", "yes")
+	require.EqualError(t, err, "Car with id c42 does not exist!")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Names of the chaincode events emitted by the cars contract. Fabric keeps a
+// single event per transaction, so every transaction emits at most one of them.
+const (
+	EventCarCreated				= "CarCreated"
+	EventCarUpdated				= "CarUpdated"
+	EventCarSold				= "CarSold"
+	EventCarRepaired			= "CarRepaired"
+	EventMalfunctionReported	= "MalfunctionReported"
+	EventCarScrapped			= "CarScrapped"
+	EventWrittenOff				= "WrittenOff"
+	EventColorChanged			= "ColorChanged"
+	EventPersonRegistered		= "PersonRegistered"
+	EventPersonUpdated			= "PersonUpdated"
+	EventBalanceChanged			= "BalanceChanged"
+	EventRepairOrderUpdated		= "RepairOrderUpdated"
+)
+
+type CarCreatedEvent struct {
+	CarID	string
+	Owner	string
+	Price	Money
+}
+
+type CarUpdatedEvent struct {
+	CarID	string
+	Owner	string
+	Brand	string
+	Model	string
+	Year	int
+	Price	Money
+	Color	string
+}
+
+type CarSoldEvent struct {
+	CarID		string
+	Seller		string
+	Buyer		string
+	Price		Money
+}
+
+type CarRepairedEvent struct {
+	CarID			string
+	Owner			string
+	Mechanic		string
+	Cost			Money
+	Malfunctions	[]Malfunction
+}
+
+type MalfunctionReportedEvent struct {
+	CarID			string
+	Owner			string
+	Malfunction		Malfunction
+	TotalRepairCost	Money
+}
+
+type CarScrappedEvent struct {
+	CarID	string
+	Owner	string
+	Reason	string
+}
+
+type WrittenOffEvent struct {
+	CarID				string
+	Owner				string
+	Price				Money
+	RepairCost			Money
+	ThresholdPercent	int
+}
+
+type ColorChangedEvent struct {
+	CarID			string
+	Owner			string
+	PreviousColor	string
+	Color			string
+}
+
+type PersonRegisteredEvent struct {
+	PersonID	string
+}
+
+type PersonUpdatedEvent struct {
+	PersonID	string
+}
+
+type RepairOrderUpdatedEvent struct {
+	OrderID		string
+	CarID		string
+	Owner		string
+	Status		string
+	Mechanic	string
+	Quote		Money
+}
+
+type BalanceChangedEvent struct {
+	PersonID	string
+	Change		Money
+	Balance		Money
+}
+
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(name, payloadJson)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	ChangeCreated				= "created"
+	ChangeOwner					= "owner_changed"
+	ChangeColor					= "color_changed"
+	ChangeDetails				= "details_changed"
+	ChangePrice					= "price_changed"
+	ChangeStatus				= "status_changed"
+	ChangeMalfunctionAdded		= "malfunction_added"
+	ChangeMalfunctionRepaired	= "malfunction_repaired"
+	ChangeDeleted				= "deleted"
+)
+
+type CarChange struct {
+	Type	string
+	From	string
+	To		string
+}
+
+type CarHistoryRecord struct {
+	TxId		string
+	Timestamp	time.Time
+	IsDelete	bool
+	Car			*Car
+	Changes		[]CarChange
+}
+
+// GetCarHistory returns every version of the car from the oldest to the most
+// recent one, together with what changed compared to the previous version.
+// Versions written before the ledger was migrated to the car~ID namespace are
+// read from the car's plain ID key. The migration deletes that key in the
+// transaction that writes the car~ID key, which moves the car rather than
+// deleting it, so that deletion is left out.
+func (s *SmartContract) GetCarHistory(ctx contractapi.TransactionContextInterface, carId string) ([]CarHistoryRecord, error) {
+	key, err := carKey(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	legacyRecords, err := getCarHistoryForKey(ctx, carId, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := getCarHistoryForKey(ctx, key, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(legacyRecords) > 0 && len(records) > 0 {
+		moved := legacyRecords[len(legacyRecords)-1]
+		if moved.IsDelete && moved.TxId == records[0].TxId {
+			legacyRecords = legacyRecords[:len(legacyRecords)-1]
+		}
+	}
+
+	records = append(legacyRecords, records...)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("Car with id %s does not exist!", carId)
+	}
+
+	var previous *Car
+	for i := range records {
+		records[i].Changes = diffCars(previous, records[i].Car, records[i].IsDelete)
+		if records[i].IsDelete {
+			previous = nil
+		} else {
+			previous = records[i].Car
+		}
+	}
+
+	return records, nil
+}
+
+// getCarHistoryForKey returns the versions stored under key in chronological
+// order, whatever order the peer returns them in.
+func getCarHistoryForKey(ctx contractapi.TransactionContextInterface, key string, carId string) ([]CarHistoryRecord, error) {
+	historyIter, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer historyIter.Close()
+
+	records := make([]CarHistoryRecord, 0)
+	for historyIter.HasNext() {
+		modification, err := historyIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		car := Car { ID: carId, Malfunctions: []Malfunction{} }
+		if len(modification.Value) > 0 {
+			err = json.Unmarshal(modification.Value, &car)
+			if err != nil {
+				return nil, err
+			}
+
+			if car.Status == "" {
+				car.Status = CarStatusActive
+			}
+			if car.Malfunctions == nil {
+				car.Malfunctions = []Malfunction{}
+			}
+		}
+
+		var timestamp time.Time
+		if modification.Timestamp != nil {
+			timestamp = time.Unix(modification.Timestamp.GetSeconds(), int64(modification.Timestamp.GetNanos())).UTC()
+		}
+
+		records = append(records, CarHistoryRecord {
+			TxId: modification.TxId,
+			Timestamp: timestamp,
+			IsDelete: modification.IsDelete,
+			Car: &car,
+		})
+	}
+
+	if len(records) > 1 && records[0].Timestamp.After(records[len(records)-1].Timestamp) {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+
+	return records, nil
+}
+
+func diffCars(previous *Car, current *Car, isDelete bool) []CarChange {
+	changes := make([]CarChange, 0)
+
+	if isDelete {
+		return append(changes, CarChange { Type: ChangeDeleted })
+	}
+	if previous == nil {
+		return append(changes, CarChange { Type: ChangeCreated, To: current.Owner })
+	}
+
+	if previous.Owner != current.Owner {
+		changes = append(changes, CarChange { Type: ChangeOwner, From: previous.Owner, To: current.Owner })
+	}
+	if previous.Color != current.Color {
+		changes = append(changes, CarChange { Type: ChangeColor, From: previous.Color, To: current.Color })
+	}
+	if previous.Brand != current.Brand || previous.Model != current.Model || previous.Year != current.Year {
+		changes = append(changes, CarChange {
+			Type: ChangeDetails,
+			From: describeCar(previous),
+			To: describeCar(current),
+		})
+	}
+	if previous.Price != current.Price {
+		changes = append(changes, CarChange { Type: ChangePrice, From: previous.Price.String(), To: current.Price.String() })
+	}
+	if previous.Status != current.Status {
+		changes = append(changes, CarChange { Type: ChangeStatus, From: previous.Status, To: current.Status })
+	}
+
+	added, repaired := diffMalfunctions(previous.Malfunctions, current.Malfunctions)
+	for _, malfunction := range added {
+		changes = append(changes, CarChange { Type: ChangeMalfunctionAdded, To: describeMalfunction(malfunction) })
+	}
+	for _, malfunction := range repaired {
+		changes = append(changes, CarChange { Type: ChangeMalfunctionRepaired, From: describeMalfunction(malfunction) })
+	}
+
+	return changes
+}
+
+// diffMalfunctions compares the two lists as multisets, so reporting the same
+// malfunction twice shows up as two additions.
+func diffMalfunctions(previous []Malfunction, current []Malfunction) ([]Malfunction, []Malfunction) {
+	remaining := make(map[Malfunction]int)
+	for _, malfunction := range previous {
+		remaining[malfunction]++
+	}
+
+	var added []Malfunction
+	for _, malfunction := range current {
+		if remaining[malfunction] > 0 {
+			remaining[malfunction]--
+		} else {
+			added = append(added, malfunction)
+		}
+	}
+
+	var repaired []Malfunction
+	for _, malfunction := range previous {
+		if remaining[malfunction] > 0 {
+			remaining[malfunction]--
+			repaired = append(repaired, malfunction)
+		}
+	}
+
+	return added, repaired
+}
+
+func describeCar(car *Car) string {
+	return car.Brand + " " + car.Model + " " + strconv.Itoa(car.Year)
+}
+
+func describeMalfunction(malfunction Malfunction) string {
+	return malfunction.Description + " (" + malfunction.Price.String() + ")"
+}
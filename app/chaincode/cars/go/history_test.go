@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCarHistory(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.CreateCar(l.tx(dealerClient), "c7", "Skoda", "Octavia", 2020, "green", "2", "3100")
+	require.NoError(t, err)
+
+	_, err = l.contract.ChangeColor(l.tx(markoClient), "c7", "white")
+	require.NoError(t, err)
+
+	err = l.contract.AddNewMalfunction(l.tx(markoClient), "c7", "Zamena guma", "120")
+	require.NoError(t, err)
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c7", "3000", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(petarClient), "c7", "1", "yes")
+	require.NoError(t, err)
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c7")
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "120")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+
+	err = l.contract.StartRepairOrder(l.tx(mechanicClient), "r1")
+	require.NoError(t, err)
+
+	err = l.contract.CompleteRepairOrder(l.tx(mechanicClient), "r1")
+	require.NoError(t, err)
+
+	history, err := l.contract.GetCarHistory(l.tx(strangerClient), "c7")
+	require.NoError(t, err)
+	require.Len(t, history, 5)
+
+	require.Equal(t, []CarChange{{ Type: ChangeCreated, To: "2" }}, history[0].Changes)
+	require.Equal(t, []CarChange{{ Type: ChangeColor, From: "green", To: "white" }}, history[1].Changes)
+	require.Equal(t, []CarChange{{ Type: ChangeMalfunctionAdded, To: "Zamena guma (120.00 EUR)" }}, history[2].Changes)
+	require.Equal(t, []CarChange{{ Type: ChangeOwner, From: "2", To: "1" }}, history[3].Changes)
+	require.Equal(t, []CarChange{{ Type: ChangeMalfunctionRepaired, From: "Zamena guma (120.00 EUR)" }}, history[4].Changes)
+
+	for i := 1; i < len(history); i++ {
+		require.True(t, history[i-1].Timestamp.Before(history[i].Timestamp))
+		require.NotEqual(t, history[i-1].TxId, history[i].TxId)
+	}
+	require.Equal(t, "1", history[4].Car.Owner)
+}
+
+func TestGetCarHistoryUnknownCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	_, err := l.contract.GetCarHistory(l.tx(strangerClient), "c42")
+	require.EqualError(t, err, "Car with id c42 does not exist!")
+}
+
+func TestGetCarHistoryAcrossMigration(t *testing.T) {
+	l := newSeededLedger(t)
+
+	l.tx(registryClient)
+	err := l.stub.PutState("c9", []byte(`{"ID":"c9","Brand":"Fiat","Model":"Punto","Year":2008,"Color":"yellow","Owner":"1","Price":1500.5}`))
+	require.NoError(t, err)
+
+	l.tx(registryClient)
+	err = l.stub.PutState("c9", []byte(`{"ID":"c9","Brand":"Fiat","Model":"Punto","Year":2008,"Color":"blue","Owner":"1","Price":1500.5}`))
+	require.NoError(t, err)
+
+	_, err = l.contract.MigrateLedger(l.tx(registryClient))
+	require.NoError(t, err)
+
+	_, err = l.contract.ChangeColor(l.tx(petarClient), "c9", "red")
+	require.NoError(t, err)
+
+	history, err := l.contract.GetCarHistory(l.tx(strangerClient), "c9")
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+
+	require.Equal(t, []CarChange{{ Type: ChangeCreated, To: "1" }}, history[0].Changes)
+	require.Equal(t, []CarChange{{ Type: ChangeColor, From: "yellow", To: "blue" }}, history[1].Changes)
+	require.False(t, history[2].IsDelete)
+	require.Empty(t, history[2].Changes)
+	require.Equal(t, []CarChange{{ Type: ChangeColor, From: "blue", To: "red" }}, history[3].Changes)
+}
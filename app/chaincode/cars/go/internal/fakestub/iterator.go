@@ -0,0 +1,65 @@
+package fakestub
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+var errIteratorClosed = errors.New("iterator is closed")
+
+// stateIterator iterates over a snapshot of query results, so writes of the
+// running transaction never show up in it.
+type stateIterator struct {
+	records	[]*queryresult.KV
+	next	int
+	closed	bool
+}
+
+func (iter *stateIterator) HasNext() bool {
+	return !iter.closed && iter.next < len(iter.records)
+}
+
+func (iter *stateIterator) Next() (*queryresult.KV, error) {
+	if iter.closed {
+		return nil, errIteratorClosed
+	}
+	if iter.next >= len(iter.records) {
+		return nil, errors.New("no more query results")
+	}
+
+	iter.next++
+	return iter.records[iter.next-1], nil
+}
+
+func (iter *stateIterator) Close() error {
+	iter.closed = true
+	return nil
+}
+
+type historyIterator struct {
+	modifications	[]*queryresult.KeyModification
+	next			int
+	closed			bool
+}
+
+func (iter *historyIterator) HasNext() bool {
+	return !iter.closed && iter.next < len(iter.modifications)
+}
+
+func (iter *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if iter.closed {
+		return nil, errIteratorClosed
+	}
+	if iter.next >= len(iter.modifications) {
+		return nil, errors.New("no more history")
+	}
+
+	iter.next++
+	return iter.modifications[iter.next-1], nil
+}
+
+func (iter *historyIterator) Close() error {
+	iter.closed = true
+	return nil
+}
@@ -0,0 +1,172 @@
+package fakestub
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// collection is the committed state of a private data collection. Every
+// collection is available to the stub, as if the peer were a member of all.
+type collection struct {
+	state				*store
+	validationParams	map[string][]byte
+}
+
+func (s *Stub) collection(name string) *collection {
+	c, exists := s.collections[name]
+	if !exists {
+		c = &collection { state: newStore(), validationParams: make(map[string][]byte) }
+		s.collections[name] = c
+	}
+
+	return c
+}
+
+func (c *collection) commit(writes map[string][]byte, params map[string][]byte) {
+	for key, value := range writes {
+		c.state.put(key, value)
+		if len(value) == 0 {
+			delete(c.validationParams, key)
+		}
+	}
+	for key, ep := range params {
+		c.validationParams[key] = ep
+	}
+}
+
+// CommittedPrivateData returns the committed value of key in the collection,
+// outside of any transaction.
+func (s *Stub) CommittedPrivateData(collection string, key string) []byte {
+	return s.collection(collection).state.get(key)
+}
+
+func assertCollection(collection string) error {
+	if collection == "" {
+		return errors.New("collection must not be an empty string")
+	}
+
+	return nil
+}
+
+func (s *Stub) GetPrivateData(collection string, key string) ([]byte, error) {
+	if err := assertCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := s.assertTx(); err != nil {
+		return nil, err
+	}
+
+	return s.collection(collection).state.get(key), nil
+}
+
+// GetPrivateDataHash returns the SHA-256 hash of the committed value, which
+// the peer keeps on the public ledger; nil if the key does not exist.
+func (s *Stub) GetPrivateDataHash(collection string, key string) ([]byte, error) {
+	value, err := s.GetPrivateData(collection, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	if err := assertCollection(collection); err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+
+	return s.writePrivateData(collection, key, value)
+}
+
+func (s *Stub) DelPrivateData(collection string, key string) error {
+	if err := assertCollection(collection); err != nil {
+		return err
+	}
+
+	return s.writePrivateData(collection, key, nil)
+}
+
+func (s *Stub) writePrivateData(collection string, key string, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.assertTx(); err != nil {
+		return err
+	}
+
+	writes, exists := s.tx.privateWrites[collection]
+	if !exists {
+		writes = make(map[string][]byte)
+		s.tx.privateWrites[collection] = writes
+	}
+
+	writes[key] = value
+	return nil
+}
+
+func (s *Stub) SetPrivateDataValidationParameter(collection string, key string, ep []byte) error {
+	if err := assertCollection(collection); err != nil {
+		return err
+	}
+	if err := s.assertTx(); err != nil {
+		return err
+	}
+
+	params, exists := s.tx.privateParams[collection]
+	if !exists {
+		params = make(map[string][]byte)
+		s.tx.privateParams[collection] = params
+	}
+
+	params[key] = ep
+	return nil
+}
+
+func (s *Stub) GetPrivateDataValidationParameter(collection string, key string) ([]byte, error) {
+	if err := assertCollection(collection); err != nil {
+		return nil, err
+	}
+	if err := s.assertTx(); err != nil {
+		return nil, err
+	}
+
+	return s.collection(collection).validationParams[key], nil
+}
+
+func (s *Stub) GetPrivateDataByRange(collection string, startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if err := assertCollection(collection); err != nil {
+		return nil, err
+	}
+
+	iter, _, err := s.rangeQuery(s.collection(collection).state, startKey, endKey, true, 0, "")
+	return iter, err
+}
+
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	if err := assertCollection(collection); err != nil {
+		return nil, err
+	}
+
+	startKey, endKey, err := partialCompositeKeyRange(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, _, err := s.rangeQuery(s.collection(collection).state, startKey, endKey, false, 0, "")
+	return iter, err
+}
+
+func (s *Stub) GetPrivateDataQueryResult(collection string, query string) (shim.StateQueryIteratorInterface, error) {
+	if err := assertCollection(collection); err != nil {
+		return nil, err
+	}
+
+	iter, _, err := s.richQuery(s.collection(collection).state, "ExecuteQuery", query, 0, "")
+	return iter, err
+}
@@ -0,0 +1,90 @@
+package fakestub
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivateData(t *testing.T) {
+	stub := NewStub("cc")
+
+	stub.StartTx("tx1", start)
+	require.NoError(t, stub.PutPrivateData("Org1Collection", "a", []byte("1")))
+	require.NoError(t, stub.PutPrivateData("Org1Collection", "b", []byte("2")))
+	require.NoError(t, stub.PutPrivateData("Org2Collection", "a", []byte("3")))
+
+	value, err := stub.GetPrivateData("Org1Collection", "a")
+	require.NoError(t, err)
+	require.Nil(t, value)
+	stub.Commit()
+
+	stub.StartTx("tx2", start)
+
+	value, err = stub.GetPrivateData("Org1Collection", "a")
+	require.NoError(t, err)
+	require.Equal(t, "1", string(value))
+
+	hash, err := stub.GetPrivateDataHash("Org2Collection", "a")
+	require.NoError(t, err)
+	expected := sha256.Sum256([]byte("3"))
+	require.Equal(t, expected[:], hash)
+
+	hash, err = stub.GetPrivateDataHash("Org2Collection", "b")
+	require.NoError(t, err)
+	require.Nil(t, hash)
+
+	iter, err := stub.GetPrivateDataByRange("Org1Collection", "", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, keys(t, iter))
+
+	require.NoError(t, stub.DelPrivateData("Org1Collection", "a"))
+	stub.Commit()
+
+	require.Nil(t, stub.CommittedPrivateData("Org1Collection", "a"))
+	require.Equal(t, "2", string(stub.CommittedPrivateData("Org1Collection", "b")))
+	require.Nil(t, stub.CommittedState("a"))
+}
+
+func TestPrivateDataValidation(t *testing.T) {
+	stub := NewStub("cc")
+	stub.StartTx("tx1", start)
+
+	require.EqualError(t, stub.PutPrivateData("", "a", []byte("1")), "collection must not be an empty string")
+	require.EqualError(t, stub.PutPrivateData("Org1Collection", "", []byte("1")), "key must not be an empty string")
+
+	_, err := stub.GetPrivateData("", "a")
+	require.EqualError(t, err, "collection must not be an empty string")
+
+	_, err = stub.GetPrivateDataQueryResult("Org1Collection", `{"selector":{}}`)
+	require.EqualError(t, err, "ExecuteQuery not supported for leveldb")
+}
+
+func TestPrivateDataQueries(t *testing.T) {
+	stub := NewStub("cc")
+	stub.SetStateDatabase(CouchDB)
+
+	stub.StartTx("tx1", start)
+	for _, id := range []string{"a2", "a1"} {
+		key, err := stub.CreateCompositeKey("asset", []string{id})
+		require.NoError(t, err)
+		require.NoError(t, stub.PutPrivateData("Org1Collection", key, []byte(`{"ID":"` + id + `","Size":5}`)))
+	}
+	require.NoError(t, stub.SetPrivateDataValidationParameter("Org1Collection", "a1", []byte("policy")))
+	stub.Commit()
+
+	stub.StartTx("tx2", start)
+
+	iter, err := stub.GetPrivateDataByPartialCompositeKey("Org1Collection", "asset", []string{})
+	require.NoError(t, err)
+	require.Len(t, keys(t, iter), 2)
+
+	iter, err = stub.GetPrivateDataQueryResult("Org1Collection", `{"selector":{"ID":"a1"}}`)
+	require.NoError(t, err)
+	require.Len(t, keys(t, iter), 1)
+
+	ep, err := stub.GetPrivateDataValidationParameter("Org1Collection", "a1")
+	require.NoError(t, err)
+	require.Equal(t, "policy", string(ep))
+}
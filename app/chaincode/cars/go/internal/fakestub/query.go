@@ -0,0 +1,563 @@
+package fakestub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// noMoreResults is the bookmark CouchDB returns for a page without results.
+const noMoreResults = "nil"
+
+// richQuery is a CouchDB Mango query. Only JSON objects are documents; other
+// values are stored as attachments by the peer and never match a selector.
+type richQuery struct {
+	Selector	map[string]interface{}	`json:"selector"`
+	Sort		[]interface{}			`json:"sort"`
+	Limit		*int					`json:"limit"`
+	Skip		int						`json:"skip"`
+	Fields		[]string				`json:"fields"`
+}
+
+type sortField struct {
+	path		string
+	descending	bool
+}
+
+type document struct {
+	record	*queryresult.KV
+	fields	map[string]interface{}
+}
+
+func parseRichQuery(query string) (*richQuery, error) {
+	var parsed richQuery
+	err := json.Unmarshal([]byte(query), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %s: %v", query, err)
+	}
+	if parsed.Selector == nil {
+		return nil, fmt.Errorf("invalid query %s: selector is missing", query)
+	}
+
+	return &parsed, nil
+}
+
+func (q *richQuery) sortFields() ([]sortField, error) {
+	fields := make([]sortField, 0, len(q.Sort))
+	for _, entry := range q.Sort {
+		switch entry := entry.(type) {
+		case string:
+			fields = append(fields, sortField { path: entry })
+		case map[string]interface{}:
+			if len(entry) != 1 {
+				return nil, fmt.Errorf("invalid sort %v: expected a single field", entry)
+			}
+			for path, direction := range entry {
+				switch direction {
+				case "asc":
+					fields = append(fields, sortField { path: path })
+				case "desc":
+					fields = append(fields, sortField { path: path, descending: true })
+				default:
+					return nil, fmt.Errorf("invalid sort direction %v of field %s", direction, path)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid sort %v", entry)
+		}
+	}
+
+	return fields, nil
+}
+
+// execute returns the documents matching the query, ordered by the sort
+// fields and then by key. Documents missing a sort field are left out, as
+// they would be missing from the index CouchDB sorts with.
+func (q *richQuery) execute(records []*queryresult.KV) ([]*queryresult.KV, error) {
+	fields, err := q.sortFields()
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]*document, 0)
+	for _, record := range records {
+		var value interface{}
+		if json.Unmarshal(record.Value, &value) != nil {
+			continue
+		}
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			continue
+		}
+		object["_id"] = record.Key
+
+		matches, err := matchSelector(object, q.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
+
+		sortable := true
+		for _, field := range fields {
+			if _, exists := lookup(object, field.path); !exists {
+				sortable = false
+			}
+		}
+		if sortable {
+			documents = append(documents, &document { record: record, fields: object })
+		}
+	}
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		for _, field := range fields {
+			a, _ := lookup(documents[i].fields, field.path)
+			b, _ := lookup(documents[j].fields, field.path)
+
+			order := collate(a, b)
+			if field.descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+
+		return false
+	})
+
+	results := make([]*queryresult.KV, 0, len(documents))
+	for _, document := range documents {
+		result, err := q.project(document)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// project keeps only the requested fields of a document.
+func (q *richQuery) project(document *document) (*queryresult.KV, error) {
+	if len(q.Fields) == 0 {
+		return document.record, nil
+	}
+
+	projection := make(map[string]interface{})
+	for _, path := range q.Fields {
+		value, exists := lookup(document.fields, path)
+		if !exists || path == "_id" {
+			continue
+		}
+
+		target := projection
+		parts := strings.Split(path, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, isObject := target[part].(map[string]interface{})
+			if !isObject {
+				next = make(map[string]interface{})
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+
+	value, err := json.Marshal(projection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queryresult.KV { Namespace: document.record.Namespace, Key: document.record.Key, Value: value }, nil
+}
+
+// page applies skip and limit, or pageSize and bookmark when paginated. The
+// bookmark is opaque, never empty and "nil" once a page comes back empty.
+func (q *richQuery) page(results []*queryresult.KV, pageSize int32, bookmark string) ([]*queryresult.KV, string, error) {
+	offset := q.Skip
+	if bookmark == noMoreResults {
+		offset = len(results)
+	} else if bookmark != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(bookmark)
+		if err == nil {
+			offset, err = strconv.Atoi(string(decoded))
+		}
+		if err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("invalid bookmark %s", bookmark)
+		}
+	}
+	if offset > len(results) {
+		offset = len(results)
+	}
+	results = results[offset:]
+
+	limit := len(results)
+	if pageSize > 0 {
+		limit = int(pageSize)
+	} else if q.Limit != nil {
+		limit = *q.Limit
+	}
+	if limit < len(results) {
+		results = results[:limit]
+	}
+
+	if len(results) == 0 {
+		return results, noMoreResults, nil
+	}
+
+	next := strconv.Itoa(offset + len(results))
+	return results, base64.RawURLEncoding.EncodeToString([]byte(next)), nil
+}
+
+// lookup resolves a dotted field path in a document.
+func lookup(value interface{}, path string) (interface{}, bool) {
+	for _, part := range strings.Split(path, ".") {
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			return nil, false
+		}
+
+		value, isObject = object[part]
+		if !isObject {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// matchSelector matches a document against a selector, whose keys are
+// either combination operators or field paths.
+func matchSelector(document interface{}, selector map[string]interface{}) (bool, error) {
+	for key, condition := range selector {
+		var matches bool
+		var err error
+
+		if strings.HasPrefix(key, "$") {
+			matches, err = matchCombination(key, condition, func(selector interface{}) (bool, error) {
+				object, isObject := selector.(map[string]interface{})
+				if !isObject {
+					return false, fmt.Errorf("invalid selector %v", selector)
+				}
+				return matchSelector(document, object)
+			})
+		} else {
+			value, exists := lookup(document, key)
+			matches, err = matchCondition(value, exists, condition)
+		}
+
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// matchCombination evaluates $and, $or, $nor and $not with match applied to
+// each of their selectors.
+func matchCombination(operator string, argument interface{}, match func(interface{}) (bool, error)) (bool, error) {
+	if operator == "$not" {
+		matches, err := match(argument)
+		return !matches, err
+	}
+
+	if operator != "$and" && operator != "$or" && operator != "$nor" {
+		return false, fmt.Errorf("invalid operator %s", operator)
+	}
+
+	selectors, isArray := argument.([]interface{})
+	if !isArray {
+		return false, fmt.Errorf("invalid operator %s: expected an array", operator)
+	}
+
+	// $or is satisfied by the first match, $and and $nor are broken by the
+	// first mismatch and match respectively.
+	for _, selector := range selectors {
+		matches, err := match(selector)
+		if err != nil {
+			return false, err
+		}
+
+		if matches == (operator != "$and") {
+			return operator == "$or", nil
+		}
+	}
+
+	return operator != "$or", nil
+}
+
+// matchCondition matches a field value against a condition. A condition that
+// is not an object compares for equality; object keys are operators or
+// nested field paths. As in CouchDB, a missing field only matches $exists
+// false and negations.
+func matchCondition(value interface{}, exists bool, condition interface{}) (bool, error) {
+	conditions, isObject := condition.(map[string]interface{})
+	if !isObject {
+		return exists && collate(value, condition) == 0, nil
+	}
+
+	for key, argument := range conditions {
+		var matches bool
+		var err error
+
+		switch key {
+		case "$and", "$or", "$nor", "$not":
+			matches, err = matchCombination(key, argument, func(condition interface{}) (bool, error) {
+				return matchCondition(value, exists, condition)
+			})
+		case "$exists":
+			wanted, isBool := argument.(bool)
+			if !isBool {
+				return false, fmt.Errorf("invalid operator $exists: expected a boolean")
+			}
+			matches = exists == wanted
+		default:
+			if !exists {
+				return false, nil
+			}
+
+			if strings.HasPrefix(key, "$") {
+				matches, err = matchOperator(key, value, argument)
+			} else {
+				nested, nestedExists := lookup(value, key)
+				matches, err = matchCondition(nested, nestedExists, argument)
+			}
+		}
+
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchOperator(operator string, value interface{}, argument interface{}) (bool, error) {
+	switch operator {
+	case "$eq":
+		return collate(value, argument) == 0, nil
+	case "$ne":
+		return collate(value, argument) != 0, nil
+	case "$gt":
+		return collate(value, argument) > 0, nil
+	case "$gte":
+		return collate(value, argument) >= 0, nil
+	case "$lt":
+		return collate(value, argument) < 0, nil
+	case "$lte":
+		return collate(value, argument) <= 0, nil
+	case "$in", "$nin":
+		candidates, isArray := argument.([]interface{})
+		if !isArray {
+			return false, fmt.Errorf("invalid operator %s: expected an array", operator)
+		}
+
+		found := false
+		for _, candidate := range candidates {
+			if containsValue(value, candidate) {
+				found = true
+				break
+			}
+		}
+
+		return found == (operator == "$in"), nil
+	case "$all":
+		required, isArray := argument.([]interface{})
+		if !isArray {
+			return false, fmt.Errorf("invalid operator $all: expected an array")
+		}
+		if _, isArray := value.([]interface{}); !isArray {
+			return false, nil
+		}
+
+		for _, candidate := range required {
+			if !containsValue(value, candidate) {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	case "$size":
+		size, isNumber := argument.(float64)
+		if !isNumber {
+			return false, fmt.Errorf("invalid operator $size: expected a number")
+		}
+
+		elements, isArray := value.([]interface{})
+		return isArray && float64(len(elements)) == size, nil
+	case "$elemMatch", "$allMatch":
+		elements, isArray := value.([]interface{})
+		if !isArray || len(elements) == 0 {
+			return false, nil
+		}
+
+		for _, element := range elements {
+			matches, err := matchCondition(element, true, argument)
+			if err != nil {
+				return false, err
+			}
+			if matches && operator == "$elemMatch" {
+				return true, nil
+			}
+			if !matches && operator == "$allMatch" {
+				return false, nil
+			}
+		}
+
+		return operator == "$allMatch", nil
+	case "$type":
+		return typeName(value) == argument, nil
+	case "$regex":
+		pattern, isString := argument.(string)
+		if !isString {
+			return false, fmt.Errorf("invalid operator $regex: expected a string")
+		}
+
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid operator $regex: %v", err)
+		}
+
+		text, isString := value.(string)
+		return isString && expression.MatchString(text), nil
+	case "$mod":
+		operands, isArray := argument.([]interface{})
+		if !isArray || len(operands) != 2 {
+			return false, fmt.Errorf("invalid operator $mod: expected [divisor, remainder]")
+		}
+
+		divisor, isNumber := operands[0].(float64)
+		remainder, isRemainder := operands[1].(float64)
+		if !isNumber || !isRemainder || divisor == 0 {
+			return false, fmt.Errorf("invalid operator $mod: expected [divisor, remainder]")
+		}
+
+		number, isNumber := value.(float64)
+		return isNumber && number == math.Trunc(number) && math.Mod(number, divisor) == remainder, nil
+	}
+
+	return false, fmt.Errorf("invalid operator %s", operator)
+}
+
+// containsValue reports whether value is candidate or, for arrays, has an
+// element equal to candidate.
+func containsValue(value interface{}, candidate interface{}) bool {
+	if elements, isArray := value.([]interface{}); isArray {
+		for _, element := range elements {
+			if collate(element, candidate) == 0 {
+				return true
+			}
+		}
+	}
+
+	return collate(value, candidate) == 0
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+
+	return "object"
+}
+
+// typeRank orders JSON types the way CouchDB collates them.
+func typeRank(value interface{}) int {
+	switch value := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		if value {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+
+	return 6
+}
+
+// collate compares two JSON values in CouchDB view collation order. Strings
+// are compared by code point rather than by ICU collation.
+func collate(a interface{}, b interface{}) int {
+	rankA, rankB := typeRank(a), typeRank(b)
+	if rankA != rankB {
+		return rankA - rankB
+	}
+
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if order := collate(a[i], b[i]); order != 0 {
+				return order
+			}
+		}
+		return len(a) - len(b)
+	case map[string]interface{}:
+		b := b.(map[string]interface{})
+		if reflect.DeepEqual(a, b) {
+			return 0
+		}
+
+		keysA, keysB := sortedKeys(a), sortedKeys(b)
+		for i := 0; i < len(keysA) && i < len(keysB); i++ {
+			if order := strings.Compare(keysA[i], keysB[i]); order != 0 {
+				return order
+			}
+			if order := collate(a[keysA[i]], b[keysB[i]]); order != 0 {
+				return order
+			}
+		}
+		return len(keysA) - len(keysB)
+	}
+
+	return 0
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
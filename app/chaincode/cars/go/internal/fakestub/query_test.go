@@ -0,0 +1,131 @@
+package fakestub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newCarStub(t *testing.T) *Stub {
+	stub := NewStub("cc")
+	stub.SetStateDatabase(CouchDB)
+
+	commit(t, stub, "tx1", map[string]string{
+		"c1": `{"DocType":"car","Brand":"Toyota","Year":2016,"Owner":"1","Price":{"Amount":500000,"Currency":"EUR"},"Malfunctions":[]}`,
+		"c2": `{"DocType":"car","Brand":"Opel","Year":2020,"Owner":"1","Price":{"Amount":350000,"Currency":"EUR"},"Malfunctions":[{"Description":"Brake"}]}`,
+		"c3": `{"DocType":"car","Brand":"Audi","Year":2010,"Owner":"2","Price":{"Amount":400000,"Currency":"USD"},"Malfunctions":[{"Description":"Lamp"},{"Description":"Door"}]}`,
+		"c4": `{"DocType":"car","Brand":"Audi","Year":2018,"Owner":"3","Status":"scrapped"}`,
+		"p1": `{"DocType":"person","Name":"Petar"}`,
+		"n1": `42`,
+	})
+
+	return stub
+}
+
+func TestRichQueryOnLevelDB(t *testing.T) {
+	stub := NewStub("cc")
+	stub.StartTx("tx1", start)
+
+	_, err := stub.GetQueryResult(`{"selector":{}}`)
+	require.EqualError(t, err, "ExecuteQuery not supported for leveldb")
+
+	_, _, err = stub.GetQueryResultWithPagination(`{"selector":{}}`, 10, "")
+	require.EqualError(t, err, "ExecuteQueryWithPagination not supported for leveldb")
+}
+
+func TestRichQuerySelectors(t *testing.T) {
+	stub := newCarStub(t)
+	stub.StartTx("tx2", start)
+
+	for _, test := range []struct {
+		selector	string
+		expected	[]string
+	}{
+		{ `{}`, []string{"c1", "c2", "c3", "c4", "p1"} },
+		{ `{"DocType":"car","Brand":"Audi"}`, []string{"c3", "c4"} },
+		{ `{"Brand":{"$eq":"Opel"}}`, []string{"c2"} },
+		{ `{"DocType":"car","Status":{"$ne":"scrapped"}}`, []string{} },
+		{ `{"DocType":"car","Status":{"$exists":false}}`, []string{"c1", "c2", "c3"} },
+		{ `{"Status":{"$nin":["scrapped"]}}`, []string{} },
+		{ `{"Year":{"$gte":2016,"$lt":2020}}`, []string{"c1", "c4"} },
+		{ `{"Price.Currency":"EUR","Price.Amount":{"$lte":400000}}`, []string{"c2"} },
+		{ `{"Price":{"Currency":"USD"}}`, []string{"c3"} },
+		{ `{"Owner":{"$in":["2","3"]}}`, []string{"c3", "c4"} },
+		{ `{"Malfunctions":{"$elemMatch":{"Description":{"$exists":true}}}}`, []string{"c2", "c3"} },
+		{ `{"DocType":"car","Malfunctions":{"$not":{"$elemMatch":{"Description":{"$exists":true}}}}}`, []string{"c1", "c4"} },
+		{ `{"Malfunctions":{"$size":2}}`, []string{"c3"} },
+		{ `{"$or":[{"Brand":"Opel"},{"Year":2010}]}`, []string{"c2", "c3"} },
+		{ `{"$and":[{"Brand":"Audi"},{"Year":{"$gt":2015}}]}`, []string{"c4"} },
+		{ `{"$nor":[{"Brand":"Audi"},{"DocType":"person"}]}`, []string{"c1", "c2"} },
+		{ `{"Brand":{"$regex":"^O"}}`, []string{"c2"} },
+		{ `{"Year":{"$type":"number","$mod":[4,0]}}`, []string{"c1", "c2"} },
+		{ `{"Brand":{"$gt":2000}}`, []string{"c1", "c2", "c3", "c4"} },
+		{ `{"_id":{"$gt":"c2"}}`, []string{"c3", "c4", "p1"} },
+	} {
+		iter, err := stub.GetQueryResult(`{"selector":` + test.selector + `}`)
+		require.NoError(t, err, test.selector)
+		require.Equal(t, test.expected, keys(t, iter), test.selector)
+	}
+}
+
+func TestRichQueryErrors(t *testing.T) {
+	stub := newCarStub(t)
+	stub.StartTx("tx2", start)
+
+	for _, query := range []string{
+		`not json`,
+		`{"fields":["Brand"]}`,
+		`{"selector":{"Year":{"$between":[1,2]}}}`,
+		`{"selector":{"$or":{"Brand":"Audi"}}}`,
+		`{"selector":{},"sort":[{"Year":"up"}]}`,
+	} {
+		_, err := stub.GetQueryResult(query)
+		require.Error(t, err, query)
+	}
+}
+
+func TestRichQuerySortLimitAndFields(t *testing.T) {
+	stub := newCarStub(t)
+	stub.StartTx("tx2", start)
+
+	iter, err := stub.GetQueryResult(`{"selector":{"DocType":"car"},"sort":[{"Year":"desc"}]}`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c2", "c4", "c1", "c3"}, keys(t, iter))
+
+	iter, err = stub.GetQueryResult(`{"selector":{"DocType":"car"},"sort":["Price.Amount"],"skip":1,"limit":1}`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c3"}, keys(t, iter))
+
+	iter, err = stub.GetQueryResult(`{"selector":{"Brand":"Opel"},"fields":["Brand","Price.Currency"]}`)
+	require.NoError(t, err)
+	record, err := iter.Next()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Brand":"Opel","Price":{"Currency":"EUR"}}`, string(record.Value))
+	require.Equal(t, "cc", record.Namespace)
+}
+
+func TestRichQueryPagination(t *testing.T) {
+	stub := newCarStub(t)
+	stub.StartTx("tx2", start)
+
+	query := `{"selector":{"DocType":"car"}}`
+
+	iter, metadata, err := stub.GetQueryResultWithPagination(query, 3, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c2", "c3"}, keys(t, iter))
+	require.Equal(t, int32(3), metadata.FetchedRecordsCount)
+	require.NotEmpty(t, metadata.Bookmark)
+
+	iter, metadata, err = stub.GetQueryResultWithPagination(query, 3, metadata.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4"}, keys(t, iter))
+	require.NotEmpty(t, metadata.Bookmark)
+
+	iter, metadata, err = stub.GetQueryResultWithPagination(query, 3, metadata.Bookmark)
+	require.NoError(t, err)
+	require.Empty(t, keys(t, iter))
+	require.Equal(t, "nil", metadata.Bookmark)
+
+	_, _, err = stub.GetQueryResultWithPagination(query, 3, "not a bookmark")
+	require.Error(t, err)
+}
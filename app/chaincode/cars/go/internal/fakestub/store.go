@@ -0,0 +1,65 @@
+package fakestub
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// store is a committed key-value namespace whose keys are kept sorted, like
+// the state database of a peer.
+type store struct {
+	values	map[string][]byte
+	keys	[]string
+}
+
+func newStore() *store {
+	return &store { values: make(map[string][]byte) }
+}
+
+func (st *store) get(key string) []byte {
+	return st.values[key]
+}
+
+// put stores value under key. An empty value deletes the key, as a write
+// without a value is a delete on the peer.
+func (st *store) put(key string, value []byte) {
+	index := sort.SearchStrings(st.keys, key)
+	_, exists := st.values[key]
+
+	if len(value) == 0 {
+		if exists {
+			delete(st.values, key)
+			st.keys = append(st.keys[:index], st.keys[index+1:]...)
+		}
+		return
+	}
+
+	st.values[key] = value
+	if !exists {
+		st.keys = append(st.keys, "")
+		copy(st.keys[index+1:], st.keys[index:])
+		st.keys[index] = key
+	}
+}
+
+// rangeQuery returns the records with startKey <= key < endKey in key order.
+// An empty endKey leaves the range open.
+func (st *store) rangeQuery(startKey string, endKey string) []*queryresult.KV {
+	records := make([]*queryresult.KV, 0)
+	for i := sort.SearchStrings(st.keys, startKey); i < len(st.keys); i++ {
+		key := st.keys[i]
+		if endKey != "" && key >= endKey {
+			break
+		}
+
+		records = append(records, &queryresult.KV { Key: key, Value: st.values[key] })
+	}
+
+	return records
+}
+
+// all returns every record in key order.
+func (st *store) all() []*queryresult.KV {
+	return st.rangeQuery("", "")
+}
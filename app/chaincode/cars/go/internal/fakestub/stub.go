@@ -0,0 +1,639 @@
+// Package fakestub provides an in-memory chaincode stub that behaves like a
+// peer, so chaincode can be tested as a state machine: sorted keys with range
+// and partial composite key queries, pagination bookmarks, key history,
+// private data collections, CouchDB rich queries, events and transaction ids.
+package fakestub
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// StateDatabase is the kind of state database the stub behaves like.
+type StateDatabase int
+
+const (
+	// LevelDB rejects rich queries, like a peer using the default state database.
+	LevelDB	StateDatabase = iota
+	// CouchDB runs rich queries against the JSON values in the state.
+	CouchDB
+)
+
+const (
+	// DefaultChannel is the channel of a new stub.
+	DefaultChannel	= "mychannel"
+
+	// The first key of a range when the start key is empty. Composite keys
+	// start with 0x00, so an open range never includes them.
+	emptyKeySubstitute	= "\x01"
+	maxUnicodeRune		= string(utf8.MaxRune)
+)
+
+var errNoTransaction = errors.New("no transaction in progress")
+
+// Stub is an in-memory shim.ChaincodeStubInterface. Writes are kept in the
+// write set of the running transaction and only become visible to reads,
+// range queries, history and events once the transaction is committed, as
+// on a peer. A Stub is not safe for concurrent use.
+type Stub struct {
+	name				string
+	channelID			string
+	stateDatabase		StateDatabase
+	creator				[]byte
+
+	state				*store
+	history				map[string][]*queryresult.KeyModification
+	validationParams	map[string][]byte
+	collections			map[string]*collection
+	events				[]*peer.ChaincodeEvent
+	chaincodes			map[string]*invocable
+
+	txCount				int
+	tx					*transaction
+}
+
+// transaction is the simulation of a single proposal.
+type transaction struct {
+	id					string
+	timestamp			*timestamp.Timestamp
+	args				[][]byte
+	transient			map[string][]byte
+	writes				map[string][]byte
+	validationParams	map[string][]byte
+	privateWrites		map[string]map[string][]byte
+	privateParams		map[string]map[string][]byte
+	event				*peer.ChaincodeEvent
+	invoked				[]*Stub
+}
+
+type invocable struct {
+	chaincode	shim.Chaincode
+	stub		*Stub
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
+
+// NewStub creates an empty ledger for the chaincode name on DefaultChannel.
+func NewStub(name string) *Stub {
+	return &Stub {
+		name: name,
+		channelID: DefaultChannel,
+		state: newStore(),
+		history: make(map[string][]*queryresult.KeyModification),
+		validationParams: make(map[string][]byte),
+		collections: make(map[string]*collection),
+		chaincodes: make(map[string]*invocable),
+	}
+}
+
+// SetChannelID changes the channel returned by GetChannelID.
+func (s *Stub) SetChannelID(channelID string) {
+	s.channelID = channelID
+}
+
+// SetStateDatabase makes the stub behave like a peer using db.
+func (s *Stub) SetStateDatabase(db StateDatabase) {
+	s.stateDatabase = db
+	for _, stub := range s.chaincodes {
+		stub.stub.SetStateDatabase(db)
+	}
+}
+
+// SetCreator sets the identity submitting the following transactions, with
+// certificate the PEM encoded certificate of the client.
+func (s *Stub) SetCreator(mspID string, certificate []byte) error {
+	creator, err := proto.Marshal(&msp.SerializedIdentity { Mspid: mspID, IdBytes: certificate })
+	if err != nil {
+		return err
+	}
+
+	s.creator = creator
+	return nil
+}
+
+// RegisterChaincode makes chaincode invocable under name through
+// InvokeChaincode. It runs against its own ledger and takes part in the
+// transactions of the invoking chaincode.
+func (s *Stub) RegisterChaincode(name string, chaincode shim.Chaincode) *Stub {
+	stub := NewStub(name)
+	stub.channelID = s.channelID
+	stub.stateDatabase = s.stateDatabase
+	s.chaincodes[name] = &invocable { chaincode: chaincode, stub: stub }
+
+	return stub
+}
+
+// StartTx begins the simulation of transaction txID submitted at timestamp.
+// It panics if another transaction is still in progress.
+func (s *Stub) StartTx(txID string, timestamp time.Time) {
+	if s.tx != nil {
+		panic(fmt.Sprintf("fakestub: transaction %s is still in progress", s.tx.id))
+	}
+
+	txTimestamp, err := ptypes.TimestampProto(timestamp)
+	if err != nil {
+		panic(fmt.Sprintf("fakestub: invalid timestamp %v: %v", timestamp, err))
+	}
+
+	s.txCount++
+	s.tx = &transaction {
+		id: txID,
+		timestamp: txTimestamp,
+		writes: make(map[string][]byte),
+		validationParams: make(map[string][]byte),
+		privateWrites: make(map[string]map[string][]byte),
+		privateParams: make(map[string]map[string][]byte),
+	}
+}
+
+// SetArgs sets the arguments of the running transaction.
+func (s *Stub) SetArgs(args ...[]byte) {
+	s.mustTx().args = args
+}
+
+// SetTransient sets the transient data of the running transaction.
+func (s *Stub) SetTransient(transient map[string][]byte) {
+	s.mustTx().transient = transient
+}
+
+// Commit applies the write set, history and event of the running
+// transaction, and of the chaincodes it invoked, to the ledger.
+func (s *Stub) Commit() {
+	tx := s.mustTx()
+	s.tx = nil
+
+	for key, value := range tx.writes {
+		s.state.put(key, value)
+		s.history[key] = append([]*queryresult.KeyModification{{
+			TxId: tx.id,
+			Value: value,
+			Timestamp: tx.timestamp,
+			IsDelete: len(value) == 0,
+		}}, s.history[key]...)
+
+		if len(value) == 0 {
+			delete(s.validationParams, key)
+		}
+	}
+	for key, ep := range tx.validationParams {
+		s.validationParams[key] = ep
+	}
+
+	for name, writes := range tx.privateWrites {
+		s.collection(name).commit(writes, nil)
+	}
+	for name, params := range tx.privateParams {
+		s.collection(name).commit(nil, params)
+	}
+
+	if tx.event != nil {
+		s.events = append(s.events, tx.event)
+	}
+
+	for _, invoked := range tx.invoked {
+		invoked.Commit()
+	}
+}
+
+// Rollback discards the running transaction, as when its proposal fails.
+func (s *Stub) Rollback() {
+	tx := s.mustTx()
+	s.tx = nil
+
+	for _, invoked := range tx.invoked {
+		invoked.Rollback()
+	}
+}
+
+// Invoke runs a transaction calling chaincode.Invoke with args. It is
+// committed when the response status is below shim.ERRORTHRESHOLD and rolled
+// back otherwise.
+func (s *Stub) Invoke(chaincode shim.Chaincode, args ...[]byte) peer.Response {
+	return s.run(chaincode.Invoke, args)
+}
+
+// Init runs a transaction calling chaincode.Init, like Invoke.
+func (s *Stub) Init(chaincode shim.Chaincode, args ...[]byte) peer.Response {
+	return s.run(chaincode.Init, args)
+}
+
+func (s *Stub) run(call func(shim.ChaincodeStubInterface) peer.Response, args [][]byte) peer.Response {
+	s.StartTx(fmt.Sprintf("tx%d", s.txCount+1), time.Now())
+	s.SetArgs(args...)
+
+	response := call(s)
+	if response.Status < shim.ERRORTHRESHOLD {
+		s.Commit()
+	} else {
+		s.Rollback()
+	}
+
+	return response
+}
+
+// Events returns the events of the committed transactions, oldest first.
+func (s *Stub) Events() []*peer.ChaincodeEvent {
+	return append([]*peer.ChaincodeEvent{}, s.events...)
+}
+
+// CommittedState returns the committed value of key, outside of any
+// transaction.
+func (s *Stub) CommittedState(key string) []byte {
+	return s.state.get(key)
+}
+
+func (s *Stub) mustTx() *transaction {
+	if s.tx == nil {
+		panic("fakestub: " + errNoTransaction.Error())
+	}
+
+	return s.tx
+}
+
+func (s *Stub) assertTx() error {
+	if s.tx == nil {
+		return errNoTransaction
+	}
+
+	return nil
+}
+
+// --------- Transaction functions ----------
+
+func (s *Stub) GetArgs() [][]byte {
+	if s.tx == nil {
+		return nil
+	}
+
+	return s.tx.args
+}
+
+func (s *Stub) GetStringArgs() []string {
+	args := make([]string, 0, len(s.GetArgs()))
+	for _, arg := range s.GetArgs() {
+		args = append(args, string(arg))
+	}
+
+	return args
+}
+
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+
+	return args[0], args[1:]
+}
+
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	slice := []byte{}
+	for _, arg := range s.GetArgs() {
+		slice = append(slice, arg...)
+	}
+
+	return slice, nil
+}
+
+func (s *Stub) GetTxID() string {
+	if s.tx == nil {
+		return ""
+	}
+
+	return s.tx.id
+}
+
+func (s *Stub) GetChannelID() string {
+	return s.channelID
+}
+
+func (s *Stub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	if err := s.assertTx(); err != nil {
+		return nil, err
+	}
+
+	return s.tx.timestamp, nil
+}
+
+func (s *Stub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	if err := s.assertTx(); err != nil {
+		return nil, err
+	}
+
+	return s.tx.transient, nil
+}
+
+func (s *Stub) GetBinding() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *Stub) GetDecorations() map[string][]byte {
+	return nil
+}
+
+func (s *Stub) GetSignedProposal() (*peer.SignedProposal, error) {
+	return nil, nil
+}
+
+// InvokeChaincode calls a chaincode added with RegisterChaincode within the
+// running transaction. The channel is ignored.
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	if err := s.assertTx(); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	callee, registered := s.chaincodes[chaincodeName]
+	if !registered {
+		return shim.Error(fmt.Sprintf("chaincode %s is not registered", chaincodeName))
+	}
+
+	if callee.stub.tx == nil {
+		callee.stub.creator = s.creator
+		callee.stub.txCount++
+		callee.stub.tx = &transaction {
+			id: s.tx.id,
+			timestamp: s.tx.timestamp,
+			transient: s.tx.transient,
+			writes: make(map[string][]byte),
+			validationParams: make(map[string][]byte),
+			privateWrites: make(map[string]map[string][]byte),
+			privateParams: make(map[string]map[string][]byte),
+		}
+		s.tx.invoked = append(s.tx.invoked, callee.stub)
+	}
+	callee.stub.tx.args = args
+
+	return callee.chaincode.Invoke(callee.stub)
+}
+
+// SetEvent sets the event of the running transaction, replacing any event
+// set before, as a transaction carries a single event.
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return errors.New("event name can not be empty string")
+	}
+	if err := s.assertTx(); err != nil {
+		return err
+	}
+
+	s.tx.event = &peer.ChaincodeEvent {
+		ChaincodeId: s.name,
+		TxId: s.tx.id,
+		EventName: name,
+		Payload: payload,
+	}
+	return nil
+}
+
+// --------- State functions ----------
+
+func (s *Stub) GetState(key string) ([]byte, error) {
+	if err := s.assertTx(); err != nil {
+		return nil, err
+	}
+
+	return s.state.get(key), nil
+}
+
+func (s *Stub) PutState(key string, value []byte) error {
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.assertTx(); err != nil {
+		return err
+	}
+
+	s.tx.writes[key] = value
+	return nil
+}
+
+func (s *Stub) DelState(key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.assertTx(); err != nil {
+		return err
+	}
+
+	s.tx.writes[key] = nil
+	return nil
+}
+
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	if err := s.assertTx(); err != nil {
+		return err
+	}
+
+	s.tx.validationParams[key] = ep
+	return nil
+}
+
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	if err := s.assertTx(); err != nil {
+		return nil, err
+	}
+
+	return s.validationParams[key], nil
+}
+
+// GetHistoryForKey returns the committed modifications of key, newest first
+// like Fabric 2.x.
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	if err := s.assertTx(); err != nil {
+		return nil, err
+	}
+
+	modifications := append([]*queryresult.KeyModification{}, s.history[key]...)
+	return &historyIterator { modifications: modifications }, nil
+}
+
+func (s *Stub) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iter, _, err := s.rangeQuery(s.state, startKey, endKey, true, 0, "")
+	return iter, err
+}
+
+func (s *Stub) GetStateByRangeWithPagination(startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("page size must be greater than zero")
+	}
+
+	return s.rangeQuery(s.state, startKey, endKey, true, pageSize, bookmark)
+}
+
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, _, err := s.rangeQuery(s.state, startKey, endKey, false, 0, "")
+	return iter, err
+}
+
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("page size must be greater than zero")
+	}
+
+	startKey, endKey, err := partialCompositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.rangeQuery(s.state, startKey, endKey, false, pageSize, bookmark)
+}
+
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	iter, _, err := s.richQuery(s.state, "ExecuteQuery", query, 0, "")
+	return iter, err
+}
+
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("page size must be greater than zero")
+	}
+
+	return s.richQuery(s.state, "ExecuteQueryWithPagination", query, pageSize, bookmark)
+}
+
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if len(compositeKey) == 0 || compositeKey[0] != 0x00 {
+		return "", nil, fmt.Errorf("key %s is not a composite key", compositeKey)
+	}
+
+	components := []string{}
+	start := 1
+	for i := 1; i < len(compositeKey); i++ {
+		if compositeKey[i] == 0x00 {
+			components = append(components, compositeKey[start:i])
+			start = i + 1
+		}
+	}
+	if len(components) == 0 {
+		return "", nil, fmt.Errorf("key %x is not a composite key", compositeKey)
+	}
+
+	return components[0], components[1:], nil
+}
+
+// rangeQuery serves range and partial composite key queries. Simple key
+// ranges substitute an empty start key like the shim and refuse keys in the
+// composite key namespace. With a page size, the bookmark is the key to
+// resume from and the returned bookmark is the first key left out, or empty
+// once the range is exhausted.
+func (s *Stub) rangeQuery(st *store, startKey string, endKey string, simpleKeys bool, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if err := s.assertTx(); err != nil {
+		return nil, nil, err
+	}
+
+	if simpleKeys {
+		if startKey == "" {
+			startKey = emptyKeySubstitute
+		}
+		for _, key := range []string{startKey, endKey} {
+			if len(key) > 0 && key[0] == 0x00 {
+				return nil, nil, fmt.Errorf("first character of the key [%s] contains a null character which is not allowed", key)
+			}
+		}
+	}
+
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	records := s.withNamespace(st.rangeQuery(startKey, endKey))
+	if pageSize == 0 {
+		return &stateIterator { records: records }, nil, nil
+	}
+
+	next := ""
+	if len(records) > int(pageSize) {
+		next = records[pageSize].Key
+		records = records[:pageSize]
+	}
+
+	return &stateIterator { records: records }, &peer.QueryResponseMetadata {
+		FetchedRecordsCount: int32(len(records)),
+		Bookmark: next,
+	}, nil
+}
+
+// richQuery serves CouchDB queries, or fails like LevelDB does. A page size
+// of zero returns every result.
+func (s *Stub) richQuery(st *store, operation string, query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if err := s.assertTx(); err != nil {
+		return nil, nil, err
+	}
+	if s.stateDatabase != CouchDB {
+		return nil, nil, fmt.Errorf("%s not supported for leveldb", operation)
+	}
+
+	parsed, err := parseRichQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, err := parsed.execute(s.withNamespace(st.all()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, next, err := parsed.page(results, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pageSize == 0 {
+		return &stateIterator { records: results }, nil, nil
+	}
+
+	return &stateIterator { records: results }, &peer.QueryResponseMetadata {
+		FetchedRecordsCount: int32(len(results)),
+		Bookmark: next,
+	}, nil
+}
+
+func (s *Stub) withNamespace(records []*queryresult.KV) []*queryresult.KV {
+	for i, record := range records {
+		records[i] = &queryresult.KV { Namespace: s.name, Key: record.Key, Value: record.Value }
+	}
+
+	return records
+}
+
+func partialCompositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	partialKey, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+
+	return partialKey, partialKey + maxUnicodeRune, nil
+}
+
+func validateKey(key string) error {
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("key [%x] is not a valid utf8 string", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,357 @@
+package fakestub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+func commit(t *testing.T, stub *Stub, txID string, writes map[string]string) {
+	stub.StartTx(txID, start)
+	for key, value := range writes {
+		require.NoError(t, stub.PutState(key, []byte(value)))
+	}
+	stub.Commit()
+}
+
+func keys(t *testing.T, iter shim.StateQueryIteratorInterface) []string {
+	defer iter.Close()
+
+	keys := []string{}
+	for iter.HasNext() {
+		record, err := iter.Next()
+		require.NoError(t, err)
+		keys = append(keys, record.Key)
+	}
+
+	return keys
+}
+
+func TestReadsSeeCommittedState(t *testing.T) {
+	stub := NewStub("cc")
+	commit(t, stub, "tx1", map[string]string{"a": "1"})
+
+	stub.StartTx("tx2", start)
+	require.NoError(t, stub.PutState("a", []byte("2")))
+	require.NoError(t, stub.PutState("b", []byte("3")))
+
+	value, err := stub.GetState("a")
+	require.NoError(t, err)
+	require.Equal(t, "1", string(value))
+
+	iter, err := stub.GetStateByRange("", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, keys(t, iter))
+
+	stub.Commit()
+	require.Equal(t, "2", string(stub.CommittedState("a")))
+	require.Equal(t, "3", string(stub.CommittedState("b")))
+}
+
+func TestRollback(t *testing.T) {
+	stub := NewStub("cc")
+	commit(t, stub, "tx1", map[string]string{"a": "1"})
+
+	stub.StartTx("tx2", start)
+	require.NoError(t, stub.DelState("a"))
+	require.NoError(t, stub.SetEvent("Deleted", nil))
+	stub.Rollback()
+
+	require.Equal(t, "1", string(stub.CommittedState("a")))
+	require.Empty(t, stub.Events())
+}
+
+func TestTransactionRequired(t *testing.T) {
+	stub := NewStub("cc")
+
+	_, err := stub.GetState("a")
+	require.EqualError(t, err, "no transaction in progress")
+	require.Panics(t, stub.Commit)
+
+	stub.StartTx("tx1", start)
+	require.Panics(t, func() { stub.StartTx("tx2", start) })
+}
+
+func TestPutStateValidation(t *testing.T) {
+	stub := NewStub("cc")
+	stub.StartTx("tx1", start)
+
+	require.EqualError(t, stub.PutState("", []byte("1")), "key must not be an empty string")
+	require.EqualError(t, stub.PutState("\xff", []byte("1")), "key [ff] is not a valid utf8 string")
+}
+
+func TestEmptyValueDeletes(t *testing.T) {
+	stub := NewStub("cc")
+	commit(t, stub, "tx1", map[string]string{"a": "1"})
+	commit(t, stub, "tx2", map[string]string{"a": ""})
+
+	require.Nil(t, stub.CommittedState("a"))
+}
+
+func TestGetStateByRange(t *testing.T) {
+	stub := NewStub("cc")
+	stub.StartTx("tx1", start)
+	for _, key := range []string{"c", "a", "b", "d"} {
+		require.NoError(t, stub.PutState(key, []byte(key)))
+	}
+	compositeKey, err := stub.CreateCompositeKey("color", []string{"blue", "a"})
+	require.NoError(t, err)
+	require.NoError(t, stub.PutState(compositeKey, []byte{0x00}))
+	stub.Commit()
+
+	stub.StartTx("tx2", start)
+
+	iter, err := stub.GetStateByRange("", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c", "d"}, keys(t, iter))
+
+	iter, err = stub.GetStateByRange("b", "d")
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c"}, keys(t, iter))
+
+	_, err = stub.GetStateByRange(compositeKey, "")
+	require.Error(t, err)
+}
+
+func TestGetStateByRangeWithPagination(t *testing.T) {
+	stub := NewStub("cc")
+	commit(t, stub, "tx1", map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"})
+
+	stub.StartTx("tx2", start)
+
+	var pages [][]string
+	bookmark := ""
+	for {
+		iter, metadata, err := stub.GetStateByRangeWithPagination("a", "e", 2, bookmark)
+		require.NoError(t, err)
+
+		page := keys(t, iter)
+		require.Equal(t, int32(len(page)), metadata.FetchedRecordsCount)
+		pages = append(pages, page)
+
+		if metadata.Bookmark == "" {
+			break
+		}
+		bookmark = metadata.Bookmark
+	}
+	require.Equal(t, [][]string{{"a", "b"}, {"c", "d"}}, pages)
+
+	_, _, err := stub.GetStateByRangeWithPagination("a", "e", 0, "")
+	require.Error(t, err)
+}
+
+func TestCompositeKeys(t *testing.T) {
+	stub := NewStub("cc")
+	stub.StartTx("tx1", start)
+
+	for _, attributes := range [][]string{{"blue", "2", "c3"}, {"blue", "1", "c1"}, {"red", "1", "c2"}, {"blue", "1", "c4"}} {
+		key, err := stub.CreateCompositeKey("color~owner~ID", attributes)
+		require.NoError(t, err)
+		require.NoError(t, stub.PutState(key, []byte{0x00}))
+	}
+	require.NoError(t, stub.PutState("plain", []byte("1")))
+	stub.Commit()
+
+	stub.StartTx("tx2", start)
+
+	iter, err := stub.GetStateByPartialCompositeKey("color~owner~ID", []string{"blue"})
+	require.NoError(t, err)
+
+	var ids []string
+	for _, key := range keys(t, iter) {
+		objectType, attributes, err := stub.SplitCompositeKey(key)
+		require.NoError(t, err)
+		require.Equal(t, "color~owner~ID", objectType)
+		ids = append(ids, attributes[2])
+	}
+	require.Equal(t, []string{"c1", "c4", "c3"}, ids)
+
+	iter, err = stub.GetStateByPartialCompositeKey("color~owner~ID", []string{"blue", "1"})
+	require.NoError(t, err)
+	require.Len(t, keys(t, iter), 2)
+
+	iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination("color~owner~ID", []string{}, 3, "")
+	require.NoError(t, err)
+	require.Len(t, keys(t, iter), 3)
+
+	iter, metadata, err = stub.GetStateByPartialCompositeKeyWithPagination("color~owner~ID", []string{}, 3, metadata.Bookmark)
+	require.NoError(t, err)
+	require.Len(t, keys(t, iter), 1)
+	require.Empty(t, metadata.Bookmark)
+
+	_, err = stub.CreateCompositeKey("color", []string{"bl\x00ue"})
+	require.Error(t, err)
+
+	_, _, err = stub.SplitCompositeKey("plain")
+	require.Error(t, err)
+}
+
+func TestGetHistoryForKey(t *testing.T) {
+	stub := NewStub("cc")
+
+	stub.StartTx("tx1", start)
+	require.NoError(t, stub.PutState("a", []byte("1")))
+	require.NoError(t, stub.PutState("a", []byte("2")))
+	stub.Commit()
+
+	stub.StartTx("tx2", start.Add(time.Minute))
+	require.NoError(t, stub.DelState("a"))
+	stub.Commit()
+
+	stub.StartTx("tx3", start.Add(2 * time.Minute))
+	require.NoError(t, stub.PutState("a", []byte("3")))
+
+	iter, err := stub.GetHistoryForKey("a")
+	require.NoError(t, err)
+	defer iter.Close()
+
+	var history []string
+	for iter.HasNext() {
+		modification, err := iter.Next()
+		require.NoError(t, err)
+
+		if modification.IsDelete {
+			history = append(history, modification.TxId + ":deleted")
+		} else {
+			history = append(history, modification.TxId + ":" + string(modification.Value))
+		}
+	}
+	require.Equal(t, []string{"tx2:deleted", "tx1:2"}, history)
+}
+
+func TestEvents(t *testing.T) {
+	stub := NewStub("cc")
+
+	stub.StartTx("tx1", start)
+	require.NoError(t, stub.SetEvent("First", []byte("1")))
+	require.NoError(t, stub.SetEvent("Second", []byte("2")))
+	require.EqualError(t, stub.SetEvent("", nil), "event name can not be empty string")
+	stub.Commit()
+
+	commit(t, stub, "tx2", nil)
+
+	require.Equal(t, []*peer.ChaincodeEvent{{ ChaincodeId: "cc", TxId: "tx1", EventName: "Second", Payload: []byte("2") }}, stub.Events())
+}
+
+func TestTransactionContext(t *testing.T) {
+	stub := NewStub("cc")
+	stub.SetChannelID("cars")
+	require.NoError(t, stub.SetCreator("Org1MSP", []byte("certificate")))
+
+	stub.StartTx("tx1", start)
+	stub.SetArgs([]byte("transfer"), []byte("a"), []byte("b"))
+	stub.SetTransient(map[string][]byte{"price": []byte("10")})
+
+	require.Equal(t, "tx1", stub.GetTxID())
+	require.Equal(t, "cars", stub.GetChannelID())
+
+	timestamp, err := stub.GetTxTimestamp()
+	require.NoError(t, err)
+	require.Equal(t, start.Unix(), timestamp.GetSeconds())
+
+	function, parameters := stub.GetFunctionAndParameters()
+	require.Equal(t, "transfer", function)
+	require.Equal(t, []string{"a", "b"}, parameters)
+
+	transient, err := stub.GetTransient()
+	require.NoError(t, err)
+	require.Equal(t, "10", string(transient["price"]))
+
+	creator, err := stub.GetCreator()
+	require.NoError(t, err)
+	var identity msp.SerializedIdentity
+	require.NoError(t, proto.Unmarshal(creator, &identity))
+	require.Equal(t, "Org1MSP", identity.Mspid)
+}
+
+func TestStateValidationParameter(t *testing.T) {
+	stub := NewStub("cc")
+
+	stub.StartTx("tx1", start)
+	require.NoError(t, stub.PutState("a", []byte("1")))
+	require.NoError(t, stub.SetStateValidationParameter("a", []byte("policy")))
+
+	ep, err := stub.GetStateValidationParameter("a")
+	require.NoError(t, err)
+	require.Nil(t, ep)
+	stub.Commit()
+
+	stub.StartTx("tx2", start)
+	ep, err = stub.GetStateValidationParameter("a")
+	require.NoError(t, err)
+	require.Equal(t, "policy", string(ep))
+}
+
+// counter is a chaincode incrementing the key given as its argument, and
+// failing when it is "fail".
+type counter struct{}
+
+func (c *counter) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return shim.Success(nil)
+}
+
+func (c *counter) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	function, _ := stub.GetFunctionAndParameters()
+
+	value, err := stub.GetState(function)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = stub.PutState(function, append(value, '+'))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if function == "fail" {
+		return shim.Error("failed")
+	}
+
+	return shim.Success(value)
+}
+
+func TestInvoke(t *testing.T) {
+	stub := NewStub("cc")
+
+	response := stub.Invoke(&counter{}, []byte("a"))
+	require.Equal(t, int32(shim.OK), response.Status)
+
+	response = stub.Invoke(&counter{}, []byte("a"))
+	require.Equal(t, "+", string(response.Payload))
+
+	response = stub.Invoke(&counter{}, []byte("fail"))
+	require.Equal(t, "failed", response.Message)
+
+	require.Equal(t, "++", string(stub.CommittedState("a")))
+	require.Nil(t, stub.CommittedState("fail"))
+}
+
+func TestInvokeChaincode(t *testing.T) {
+	stub := NewStub("cc")
+	other := stub.RegisterChaincode("counter", &counter{})
+
+	stub.StartTx("tx1", start)
+	response := stub.InvokeChaincode("counter", [][]byte{[]byte("a")}, "")
+	require.Equal(t, int32(shim.OK), response.Status)
+	require.Equal(t, "tx1", other.GetTxID())
+	stub.Commit()
+
+	require.Equal(t, "+", string(other.CommittedState("a")))
+	require.Nil(t, stub.CommittedState("a"))
+
+	stub.StartTx("tx2", start)
+	stub.InvokeChaincode("counter", [][]byte{[]byte("a")}, "")
+	stub.Rollback()
+	require.Equal(t, "+", string(other.CommittedState("a")))
+
+	stub.StartTx("tx3", start)
+	response = stub.InvokeChaincode("missing", nil, "")
+	require.Equal(t, "chaincode missing is not registered", response.Message)
+}
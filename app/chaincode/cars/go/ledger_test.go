@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/internal/fakestub"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdentity is the submitting client of a test transaction. roles is the
+// value of its role certificate attribute.
+type testIdentity struct {
+	mspId	string
+	id		string
+	roles	string
+}
+
+func (identity *testIdentity) GetID() (string, error) {
+	return identity.id, nil
+}
+
+func (identity *testIdentity) GetMSPID() (string, error) {
+	return identity.mspId, nil
+}
+
+func (identity *testIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	if attrName != roleAttribute || identity.roles == "" {
+		return "", false, nil
+	}
+
+	return identity.roles, true, nil
+}
+
+func (identity *testIdentity) AssertAttributeValue(attrName string, attrValue string) error {
+	value, found, _ := identity.GetAttributeValue(attrName)
+	if !found || value != attrValue {
+		return fmt.Errorf("attribute %s is not %s", attrName, attrValue)
+	}
+
+	return nil
+}
+
+func (identity *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, errors.New("test identities have no certificate")
+}
+
+var (
+	registryClient	= &testIdentity { mspId: "Org4MSP", id: "registry", roles: "registry" }
+	adminClient		= &testIdentity { mspId: "Org4MSP", id: "admin", roles: "admin" }
+	dealerClient	= &testIdentity { mspId: "Org4MSP", id: "dealer", roles: "dealer" }
+	mechanicClient	= &testIdentity { mspId: "Org4MSP", id: "mechanic", roles: "mechanic" }
+	petarClient		= &testIdentity { mspId: "Org4MSP", id: "petar" }
+	markoClient		= &testIdentity { mspId: "Org4MSP", id: "marko" }
+	stefanClient	= &testIdentity { mspId: "Org4MSP", id: "stefan" }
+	strangerClient	= &testIdentity { mspId: "Org1MSP", id: "stranger" }
+)
+
+// testLedger runs transactions of the cars contract one after another against
+// a fakestub ledger. Every transaction gets its own id and a timestamp one
+// second after the previous one, and is committed when the next one starts,
+// whether it failed or not.
+type testLedger struct {
+	t			*testing.T
+	contract	*SmartContract
+	stub		*fakestub.Stub
+	now			time.Time
+	txCount		int
+	pending		bool
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	return &testLedger {
+		t: t,
+		contract: new(SmartContract),
+		stub: fakestub.NewStub("carcc"),
+		now: time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// newSeededLedger returns a ledger after InitLedger, with persons 1, 2 and 3
+// linked to petarClient, markoClient and stefanClient and the mechanic
+// registered as person m1.
+func newSeededLedger(t *testing.T) *testLedger {
+	l := newTestLedger(t)
+
+	require.NoError(t, l.contract.InitLedger(l.tx(registryClient)))
+
+	for personId, client := range map[string]*testIdentity{ "1": petarClient, "2": markoClient, "3": stefanClient } {
+		err := l.contract.LinkPersonIdentity(l.tx(registryClient), personId, client.mspId, client.id)
+		require.NoError(t, err)
+	}
+
+	err := l.contract.CreatePerson(l.tx(mechanicClient), "m1", "Milan", "Milanovic", "milan@gmail.com")
+	require.NoError(t, err)
+
+	return l
+}
+
+// tx commits the running transaction and starts a new one submitted by
+// identity.
+func (l *testLedger) tx(identity *testIdentity) contractapi.TransactionContextInterface {
+	l.commit()
+
+	l.txCount++
+	l.now = l.now.Add(time.Second)
+
+	l.stub.StartTx(fmt.Sprintf("tx%d", l.txCount), l.now)
+	l.pending = true
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(l.stub)
+	ctx.SetClientIdentity(identity)
+
+	return ctx
+}
+
+func (l *testLedger) commit() {
+	if l.pending {
+		l.stub.Commit()
+		l.pending = false
+	}
+}
+
+func (l *testLedger) person(id string) *Person {
+	person, err := l.contract.GetPerson(l.tx(strangerClient), id)
+	require.NoError(l.t, err)
+
+	return person
+}
+
+func (l *testLedger) car(id string) *Car {
+	car, err := l.contract.GetCar(l.tx(strangerClient), id)
+	require.NoError(l.t, err)
+
+	return car
+}
+
+func (l *testLedger) balance(personId string) int64 {
+	return l.person(personId).Money.Amount
+}
+
+// requireEvent commits the running transaction, checks that it emitted the
+// named event and decodes its payload.
+func (l *testLedger) requireEvent(name string, payload interface{}) {
+	l.commit()
+
+	events := l.stub.Events()
+	require.NotEmpty(l.t, events, "no event was emitted")
+
+	event := events[len(events)-1]
+	require.Equal(l.t, fmt.Sprintf("tx%d", l.txCount), event.TxId, "last transaction emitted no event")
+	require.Equal(l.t, name, event.EventName)
+
+	if payload != nil {
+		require.NoError(l.t, json.Unmarshal(event.Payload, payload))
+	}
+}
+
+func carIds(cars []*Car) []string {
+	ids := make([]string, 0, len(cars))
+	for _, car := range cars {
+		ids = append(ids, car.ID)
+	}
+
+	return ids
+}
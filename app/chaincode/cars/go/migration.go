@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MigrateLedger moves persons and cars written under plain ID keys by earlier
+// versions of the chaincode into the person~ID and car~ID namespaces, and
+// rewrites float amounts as Money. Cars created before QueryCars and
+// GetCarsByOwner existed are added to their indexes. Running it again is a no-op.
+func (s *SmartContract) MigrateLedger(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := assertRole(ctx, "migrate the ledger", RoleRegistry)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated, err := migratePlainKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, keyType := range []string{personKeyType, carKeyType} {
+		count, err := migrateAmounts(ctx, keyType)
+		if err != nil {
+			return 0, err
+		}
+
+		migrated += count
+	}
+
+	count, err := migrateQueryIndexes(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return migrated + count, nil
+}
+
+// migratePlainKeys relies on range queries over simple keys never returning
+// composite keys, so records that were already moved are not visited again.
+func migratePlainKeys(ctx contractapi.TransactionContextInterface) (int, error) {
+	recordsIter, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer recordsIter.Close()
+
+	migrated := 0
+	for recordsIter.HasNext() {
+		record, err := recordsIter.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		var fields map[string]json.RawMessage
+		err = json.Unmarshal(record.Value, &fields)
+		if err != nil {
+			continue
+		}
+
+		if _, isCar := fields["Brand"]; isCar {
+			var car Car
+			err = json.Unmarshal(record.Value, &car)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to migrate car %s: %v", record.Key, err)
+			}
+
+			if car.Status == "" {
+				car.Status = CarStatusActive
+			}
+			if car.Malfunctions == nil {
+				car.Malfunctions = []Malfunction{}
+			}
+
+			err = putCar(ctx, &car)
+			if err == nil && car.Status != CarStatusScrapped {
+				err = putQueryIndexes(ctx, &car)
+				if err == nil {
+					err = putOwnerIndex(ctx, &car)
+				}
+			}
+		} else if _, isPerson := fields["Email"]; isPerson {
+			var person Person
+			err = json.Unmarshal(record.Value, &person)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to migrate person %s: %v", record.Key, err)
+			}
+
+			err = putPerson(ctx, &person)
+		} else {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		err = ctx.GetStub().DelState(record.Key)
+		if err != nil {
+			return 0, err
+		}
+
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// migrateAmounts rewrites the records of keyType that still hold amounts as
+// plain numbers.
+func migrateAmounts(ctx contractapi.TransactionContextInterface, keyType string) (int, error) {
+	recordsIter, err := ctx.GetStub().GetStateByPartialCompositeKey(keyType, []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer recordsIter.Close()
+
+	migrated := 0
+	for recordsIter.HasNext() {
+		record, err := recordsIter.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		if !hasLegacyAmounts(record.Value) {
+			continue
+		}
+
+		if keyType == personKeyType {
+			var person Person
+			err = json.Unmarshal(record.Value, &person)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to migrate person %s: %v", record.Key, err)
+			}
+
+			err = putPerson(ctx, &person)
+		} else {
+			var car Car
+			err = json.Unmarshal(record.Value, &car)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to migrate car %s: %v", record.Key, err)
+			}
+
+			err = putCar(ctx, &car)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// migrateQueryIndexes adds the cars that are missing from the QueryCars and
+// owner indexes. Scrapped cars are left out, as they are never returned.
+func migrateQueryIndexes(ctx contractapi.TransactionContextInterface) (int, error) {
+	carsIter, err := ctx.GetStub().GetStateByPartialCompositeKey(carKeyType, []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer carsIter.Close()
+
+	migrated := 0
+	for carsIter.HasNext() {
+		record, err := carsIter.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		var car Car
+		err = json.Unmarshal(record.Value, &car)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to migrate car %s: %v", record.Key, err)
+		}
+
+		if car.Status == CarStatusScrapped {
+			continue
+		}
+
+		keys, err := queryIndexKeys(ctx, &car)
+		if err != nil {
+			return 0, err
+		}
+
+		ownerKey, err := ownerIndexKey(ctx, &car)
+		if err != nil {
+			return 0, err
+		}
+
+		added := false
+		for _, key := range append(keys, ownerKey) {
+			indexed, err := ctx.GetStub().GetState(key)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to read from world state: %v", err)
+			}
+			if indexed != nil {
+				continue
+			}
+
+			err = ctx.GetStub().PutState(key, []byte{0x00})
+			if err != nil {
+				return 0, err
+			}
+
+			added = true
+		}
+
+		if added {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+func hasLegacyAmounts(value []byte) bool {
+	var record struct {
+		Money			json.RawMessage
+		Price			json.RawMessage
+		Malfunctions	[]struct {
+			Price	json.RawMessage
+		}
+	}
+
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return false
+	}
+
+	amounts := []json.RawMessage{record.Money, record.Price}
+	for _, malfunction := range record.Malfunctions {
+		amounts = append(amounts, malfunction.Price)
+	}
+
+	for _, amount := range amounts {
+		amount = bytes.TrimSpace(amount)
+		if len(amount) > 0 && amount[0] != '{' && !bytes.Equal(amount, []byte("null")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateLedger(t *testing.T) {
+	l := newSeededLedger(t)
+
+	l.tx(registryClient)
+	err := l.stub.PutState("c9", []byte(`{"ID":"c9","Brand":"Fiat","Model":"Punto","Year":2008,"Color":"yellow","Owner":"1","Malfunctions":[{"Description":"Zamena sijalice","Price":12.5}],"Price":1500.5}`))
+	require.NoError(t, err)
+	err = l.stub.PutState("p9", []byte(`{"ID":"p9","Name":"Jovan","Surname":"Jovanovic","Email":"jovan@gmail.com","Money":120.25}`))
+	require.NoError(t, err)
+
+	_, err = l.contract.MigrateLedger(l.tx(petarClient))
+	require.EqualError(t, err, "Submitting client is not allowed to migrate the ledger!")
+
+	migrated, err := l.contract.MigrateLedger(l.tx(registryClient))
+	require.NoError(t, err)
+	require.Equal(t, 2, migrated)
+
+	car := l.car("c9")
+	require.Equal(t, CarStatusActive, car.Status)
+	require.Equal(t, NewMoney(1500_50), car.Price)
+	require.Equal(t, []Malfunction{{ Description: "Zamena sijalice", Price: NewMoney(12_50) }}, car.Malfunctions)
+	require.Equal(t, int64(120_25), l.balance("p9"))
+
+	l.tx(strangerClient)
+	value, err := l.stub.GetState("c9")
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	cars, err := l.contract.GetCarsByOwner(l.tx(strangerClient), "1", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c2", "c9"}, carIds(cars))
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Fiat" }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c9"}, carIds(result.Cars))
+
+	migrated, err = l.contract.MigrateLedger(l.tx(registryClient))
+	require.NoError(t, err)
+	require.Equal(t, 0, migrated)
+}
+
+func TestMigrateLedgerAddsMissingIndexes(t *testing.T) {
+	l := newSeededLedger(t)
+
+	car := l.car("c4")
+	ctx := l.tx(registryClient)
+	err := deleteQueryIndexes(ctx, car)
+	require.NoError(t, err)
+	err = deleteOwnerIndex(ctx, car)
+	require.NoError(t, err)
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c5"}, carIds(result.Cars))
+
+	migrated, err := l.contract.MigrateLedger(l.tx(registryClient))
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4", "c5"}, carIds(result.Cars))
+
+	cars, err := l.contract.GetCarsByOwner(l.tx(strangerClient), "3", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4"}, carIds(cars))
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultCurrency	= "EUR"
+
+	minorUnitDigits	= 2
+	minorUnits		= 100
+)
+
+// Money is an amount in minor units (cents) of Currency. Amounts are never
+// held as floats so every endorser computes exactly the same balances.
+type Money struct {
+	Amount		int64
+	Currency	string
+}
+
+func NewMoney(amount int64) Money {
+	return Money { Amount: amount, Currency: DefaultCurrency }
+}
+
+// ParseMoney parses a decimal amount such as "32.30", optionally followed by
+// a currency code ("32.30 EUR"). At most two decimal places are accepted.
+func ParseMoney(value string) (Money, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Money{}, fmt.Errorf("Amount %q is not valid!", value)
+	}
+
+	currency := DefaultCurrency
+	if len(fields) == 2 {
+		currency = strings.ToUpper(fields[1])
+		if len(currency) != 3 {
+			return Money{}, fmt.Errorf("Currency %q is not valid!", fields[1])
+		}
+	}
+
+	amount, err := parseMinorUnits(fields[0])
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money { Amount: amount, Currency: currency }, nil
+}
+
+func parseMinorUnits(value string) (int64, error) {
+	negative := strings.HasPrefix(value, "-")
+	digits := strings.TrimPrefix(value, "-")
+
+	whole, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+
+	if whole == "" || len(fraction) > minorUnitDigits || strings.ContainsAny(whole+fraction, "+-eE") {
+		return 0, fmt.Errorf("Amount %q is not valid!", value)
+	}
+	fraction += strings.Repeat("0", minorUnitDigits-len(fraction))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Amount %q is not valid!", value)
+	}
+	cents, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Amount %q is not valid!", value)
+	}
+
+	if units > (math.MaxInt64-cents)/minorUnits {
+		return 0, fmt.Errorf("Amount %q is too large!", value)
+	}
+
+	amount := units*minorUnits + cents
+	if negative {
+		amount = -amount
+	}
+
+	return amount, nil
+}
+
+// UnmarshalJSON accepts both the current {"Amount","Currency"} form and the
+// plain decimal numbers written by earlier versions of the chaincode.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		var legacy json.Number
+		err := json.Unmarshal(data, &legacy)
+		if err != nil {
+			return err
+		}
+
+		amount, err := parseLegacyAmount(legacy.String())
+		if err != nil {
+			return err
+		}
+
+		*m = NewMoney(amount)
+		return nil
+	}
+
+	type money Money
+	var decoded money
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	*m = Money(decoded)
+	if m.Currency == "" {
+		m.Currency = DefaultCurrency
+	}
+
+	return nil
+}
+
+// parseLegacyAmount converts the float32 amounts stored by earlier versions,
+// rounding to the nearest cent.
+func parseLegacyAmount(value string) (int64, error) {
+	amount, err := parseMinorUnits(value)
+	if err == nil {
+		return amount, nil
+	}
+
+	float, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Amount %q is not valid!", value)
+	}
+
+	float = math.Round(float * minorUnits)
+	if float > math.MaxInt64 || float < math.MinInt64 {
+		return 0, fmt.Errorf("Amount %q is too large!", value)
+	}
+
+	return int64(float), nil
+}
+
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/minorUnits, amount%minorUnits, m.Currency)
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) LessThan(other Money) bool {
+	return m.Amount < other.Amount
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	err := m.assertSameCurrency(other)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, fmt.Errorf("Adding %s to %s overflows!", other, m)
+	}
+
+	return Money { Amount: m.Amount + other.Amount, Currency: m.Currency }, nil
+}
+
+// Sub subtracts other from m and fails instead of producing a negative amount.
+func (m Money) Sub(other Money) (Money, error) {
+	err := m.assertSameCurrency(other)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if other.Amount > m.Amount {
+		return Money{}, fmt.Errorf("Subtracting %s from %s would result in a negative amount!", other, m)
+	}
+
+	return Money { Amount: m.Amount - other.Amount, Currency: m.Currency }, nil
+}
+
+func (m Money) assertSameCurrency(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("Currency mismatch: %s and %s!", m.Currency, other.Currency)
+	}
+
+	return nil
+}
+
+func sumMalfunctions(malfunctions []Malfunction, currency string) (Money, error) {
+	total := Money { Currency: currency }
+	for _, malfunction := range malfunctions {
+		var err error
+		total, err = total.Add(malfunction.Price)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+
+	return total, nil
+}
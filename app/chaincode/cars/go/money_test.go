@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	for value, expected := range map[string]Money{
+		"32.30":		NewMoney(32_30),
+		"12.5":			NewMoney(12_50),
+		"7":			NewMoney(7_00),
+		"0.01":			NewMoney(1),
+		"-3.10":		NewMoney(-3_10),
+		"12.50 usd":	{ Amount: 12_50, Currency: "USD" },
+	} {
+		money, err := ParseMoney(value)
+		require.NoError(t, err, value)
+		require.Equal(t, expected, money, value)
+	}
+
+	for _, value := range []string{"", "abc", "1.005", ".5", "1e3", "12.50 dollars", "1 EUR extra", "92233720368547758.08"} {
+		_, err := ParseMoney(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestMoneyUnmarshalLegacyAmounts(t *testing.T) {
+	var car Car
+	err := json.Unmarshal([]byte(`{"Price":5200.5,"Malfunctions":[{"Description":"Popravak motora","Price":32.3}]}`), &car)
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(5200_50), car.Price)
+	require.Equal(t, NewMoney(32_30), car.Malfunctions[0].Price)
+
+	var money Money
+	err = json.Unmarshal([]byte(`{"Amount":1250}`), &money)
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(12_50), money)
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := NewMoney(10_00).Add(NewMoney(2_50))
+	require.NoError(t, err)
+	require.Equal(t, "12.50 EUR", sum.String())
+
+	difference, err := sum.Sub(NewMoney(12_50))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(0), difference)
+
+	_, err = sum.Sub(NewMoney(12_51))
+	require.EqualError(t, err, "Subtracting 12.51 EUR from 12.50 EUR would result in a negative amount!")
+
+	_, err = sum.Add(Money { Amount: 1, Currency: "USD" })
+	require.EqualError(t, err, "Currency mismatch: EUR and USD!")
+
+	_, err = NewMoney(1<<62).Add(NewMoney(1<<62))
+	require.Error(t, err)
+
+	require.Equal(t, "-0.05 EUR", NewMoney(-5).String())
+}
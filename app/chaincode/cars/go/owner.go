@@ -0,0 +1,152 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const ownerIndex = "owner~ID"
+
+// OwnerSummary is a person's portfolio. Amounts are in the currency of the
+// person's balance. Written-off cars are counted in CarCount and
+// WrittenOffCount, but are worth nothing and will not be repaired, so they add
+// neither to the market value nor to the outstanding repair cost.
+type OwnerSummary struct {
+	PersonID				string
+	CarCount				int
+	WrittenOffCount			int
+	MarketValue				Money
+	OutstandingRepairCost	Money
+	Money					Money
+	NetWorth				Money
+}
+
+// GetCarsByOwner returns every car of the person. Written-off cars are left
+// out unless includeWrittenOff is set.
+func (s *SmartContract) GetCarsByOwner(ctx contractapi.TransactionContextInterface, ownerId string, includeWrittenOff bool) ([]*Car, error) {
+	_, err := s.GetPerson(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	cars, err := s.ownedCars(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*Car, 0, len(cars))
+	for _, car := range cars {
+		if car.Status == CarStatusWrittenOff && !includeWrittenOff {
+			continue
+		}
+
+		filtered = append(filtered, car)
+	}
+
+	return filtered, nil
+}
+
+// GetOwnerSummary adds up the person's cars: their market value, the cost of
+// repairing their outstanding malfunctions and, together with the balance,
+// the person's net worth. Written-off cars are only counted.
+func (s *SmartContract) GetOwnerSummary(ctx contractapi.TransactionContextInterface, ownerId string) (*OwnerSummary, error) {
+	owner, err := s.GetPerson(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	cars, err := s.ownedCars(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := owner.Money.Currency
+	summary := OwnerSummary {
+		PersonID: owner.ID,
+		CarCount: len(cars),
+		MarketValue: Money { Currency: currency },
+		OutstandingRepairCost: Money { Currency: currency },
+		Money: owner.Money,
+	}
+
+	for _, car := range cars {
+		if car.Status == CarStatusWrittenOff {
+			summary.WrittenOffCount++
+			continue
+		}
+
+		summary.MarketValue, err = summary.MarketValue.Add(car.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		repairCost, err := sumMalfunctions(car.Malfunctions, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		summary.OutstandingRepairCost, err = summary.OutstandingRepairCost.Add(repairCost)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	summary.NetWorth, err = owner.Money.Add(summary.MarketValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+func (s *SmartContract) ownedCars(ctx contractapi.TransactionContextInterface, ownerId string) ([]*Car, error) {
+	carsIter, err := ctx.GetStub().GetStateByPartialCompositeKey(ownerIndex, []string{ownerId})
+	if err != nil {
+		return nil, err
+	}
+	defer carsIter.Close()
+
+	cars := make([]*Car, 0)
+	for carsIter.HasNext() {
+		responseRange, err := carsIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		car, err := s.GetCar(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		cars = append(cars, car)
+	}
+
+	return cars, nil
+}
+
+func ownerIndexKey(ctx contractapi.TransactionContextInterface, car *Car) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(ownerIndex, []string{car.Owner, car.ID})
+}
+
+func putOwnerIndex(ctx contractapi.TransactionContextInterface, car *Car) error {
+	key, err := ownerIndexKey(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	value := []byte{0x00}
+	return ctx.GetStub().PutState(key, value)
+}
+
+func deleteOwnerIndex(ctx contractapi.TransactionContextInterface, car *Car) error {
+	key, err := ownerIndexKey(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
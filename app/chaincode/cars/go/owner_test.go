@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCarsByOwner(t *testing.T) {
+	l := newSeededLedger(t)
+
+	cars, err := l.contract.GetCarsByOwner(l.tx(strangerClient), "2", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c3", "c5", "c6"}, carIds(cars))
+
+	cars, err = l.contract.GetCarsByOwner(l.tx(strangerClient), "m1", false)
+	require.NoError(t, err)
+	require.Empty(t, cars)
+
+	_, err = l.contract.GetCarsByOwner(l.tx(strangerClient), "42", false)
+	require.EqualError(t, err, "Person with id 42 does not exist!")
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(petarClient), "c3", "1", "no")
+	require.NoError(t, err)
+
+	err = l.contract.ScrapCar(l.tx(markoClient), "c6")
+	require.NoError(t, err)
+
+	cars, err = l.contract.GetCarsByOwner(l.tx(strangerClient), "1", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c2", "c3"}, carIds(cars))
+
+	cars, err = l.contract.GetCarsByOwner(l.tx(strangerClient), "2", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c5"}, carIds(cars))
+
+	err = l.contract.SetWriteOffThreshold(l.tx(adminClient), 1)
+	require.NoError(t, err)
+
+	err = l.contract.AddNewMalfunction(l.tx(markoClient), "c5", "Ogrebotina", "50")
+	require.NoError(t, err)
+
+	cars, err = l.contract.GetCarsByOwner(l.tx(strangerClient), "2", false)
+	require.NoError(t, err)
+	require.Empty(t, cars)
+
+	cars, err = l.contract.GetCarsByOwner(l.tx(strangerClient), "2", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c5"}, carIds(cars))
+}
+
+func TestGetOwnerSummary(t *testing.T) {
+	l := newSeededLedger(t)
+
+	summary, err := l.contract.GetOwnerSummary(l.tx(strangerClient), "2")
+	require.NoError(t, err)
+	require.Equal(t, &OwnerSummary {
+		PersonID: "2",
+		CarCount: 3,
+		MarketValue: NewMoney(13450_00),
+		OutstandingRepairCost: NewMoney(44_70),
+		Money: NewMoney(2850_00),
+		NetWorth: NewMoney(16300_00),
+	}, summary)
+
+	summary, err = l.contract.GetOwnerSummary(l.tx(strangerClient), "m1")
+	require.NoError(t, err)
+	require.Equal(t, 0, summary.CarCount)
+	require.Equal(t, NewMoney(0), summary.NetWorth)
+
+	_, err = l.contract.GetOwnerSummary(l.tx(strangerClient), "42")
+	require.EqualError(t, err, "Person with id 42 does not exist!")
+}
+
+func TestGetOwnerSummaryWithWrittenOffCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.SetWriteOffThreshold(l.tx(adminClient), 1)
+	require.NoError(t, err)
+
+	err = l.contract.AddNewMalfunction(l.tx(markoClient), "c5", "Ogrebotina", "50")
+	require.NoError(t, err)
+	require.Equal(t, CarStatusWrittenOff, l.car("c5").Status)
+
+	summary, err := l.contract.GetOwnerSummary(l.tx(strangerClient), "2")
+	require.NoError(t, err)
+	require.Equal(t, &OwnerSummary {
+		PersonID: "2",
+		CarCount: 3,
+		WrittenOffCount: 1,
+		MarketValue: NewMoney(9150_00),
+		OutstandingRepairCost: NewMoney(24_70),
+		Money: NewMoney(2850_00),
+		NetWorth: NewMoney(12000_00),
+	}, summary)
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	brandModelIndex	= "brand~model~ID"
+	yearIndex		= "year~ID"
+
+	maxCarsPageSize	= 100
+
+	// stateDatabaseEnv names the state database of the peer, couchdb or
+	// leveldb, for QueryCars.
+	stateDatabaseEnv	= "CARS_STATE_DATABASE"
+)
+
+// CarQuery selects the cars returned by QueryCars. Fields left empty (or zero)
+// match every car. Prices are amounts such as "2500" or "2500 USD" and only
+// match cars priced in the same currency. HasMalfunctions is "true", "false"
+// or empty. IncludeWrittenOff is required, as contract metadata needs every
+// struct to have at least one required field.
+type CarQuery struct {
+	Brand				string	`metadata:",optional"`
+	Model				string	`metadata:",optional"`
+	MinYear				int		`metadata:",optional"`
+	MaxYear				int		`metadata:",optional"`
+	MinPrice			string	`metadata:",optional"`
+	MaxPrice			string	`metadata:",optional"`
+	HasMalfunctions		string	`metadata:",optional"`
+	IncludeWrittenOff	bool
+}
+
+// PaginatedCarsResult is one page of QueryCars. Bookmark is passed to the next
+// call to continue after this page; it is empty when there are no more cars.
+type PaginatedCarsResult struct {
+	Cars				[]*Car
+	FetchedRecordsCount	int32
+	Bookmark			string
+}
+
+// carFilter is a validated CarQuery.
+type carFilter struct {
+	query			CarQuery
+	minPrice		*Money
+	maxPrice		*Money
+	malfunctions	*bool
+}
+
+// stateDatabase remembers whether the peer runs the chaincode on CouchDB.
+type stateDatabase struct {
+	once	sync.Once
+	couchDB	bool
+}
+
+// QueryCars returns a page of at most pageSize cars matching the query,
+// starting after bookmark. Scrapped cars are never returned. On CouchDB the
+// query runs as a selector backed by the indexes under META-INF; on LevelDB it
+// walks the brand~model~ID or year~ID index when the query allows it.
+func (s *SmartContract) QueryCars(ctx contractapi.TransactionContextInterface, query CarQuery, pageSize int32, bookmark string) (*PaginatedCarsResult, error) {
+	if pageSize <= 0 || pageSize > maxCarsPageSize {
+		return nil, fmt.Errorf("Page size must be between 1 and %d!", maxCarsPageSize)
+	}
+
+	filter, err := newCarFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.stateDatabase.usesCouchDB(ctx) {
+		return queryCarsWithSelector(ctx, filter, pageSize, bookmark)
+	}
+
+	return s.queryCarsWithIndexes(ctx, filter, pageSize, bookmark)
+}
+
+// usesCouchDB tells whether the peer runs rich queries, as configured in
+// CARS_STATE_DATABASE or else by running one that matches nothing. The state
+// database of a peer does not change, so it is only found out once.
+func (d *stateDatabase) usesCouchDB(ctx contractapi.TransactionContextInterface) bool {
+	d.once.Do(func() {
+		switch strings.ToLower(os.Getenv(stateDatabaseEnv)) {
+		case "couchdb":
+			d.couchDB = true
+			return
+		case "leveldb":
+			return
+		}
+
+		probeIter, err := ctx.GetStub().GetQueryResult(`{"selector":{"DocType":"stateDatabaseProbe"}}`)
+		if err == nil {
+			probeIter.Close()
+			d.couchDB = true
+		}
+	})
+
+	return d.couchDB
+}
+
+func newCarFilter(query CarQuery) (*carFilter, error) {
+	filter := carFilter { query: query }
+
+	if query.MinYear != 0 && query.MaxYear != 0 && query.MinYear > query.MaxYear {
+		return nil, fmt.Errorf("Minimum year must not be after maximum year!")
+	}
+
+	for _, bound := range []struct {
+		value	string
+		target	**Money
+	}{
+		{ query.MinPrice, &filter.minPrice },
+		{ query.MaxPrice, &filter.maxPrice },
+	} {
+		if strings.TrimSpace(bound.value) == "" {
+			continue
+		}
+
+		price, err := ParseMoney(bound.value)
+		if err != nil {
+			return nil, err
+		}
+		if price.Amount < 0 {
+			return nil, fmt.Errorf("Price %s must not be negative!", bound.value)
+		}
+
+		*bound.target = &price
+	}
+
+	if filter.minPrice != nil && filter.maxPrice != nil {
+		if filter.minPrice.Currency != filter.maxPrice.Currency {
+			return nil, fmt.Errorf("Price range must be in a single currency!")
+		}
+		if filter.maxPrice.LessThan(*filter.minPrice) {
+			return nil, fmt.Errorf("Minimum price must not be above maximum price!")
+		}
+	}
+
+	switch query.HasMalfunctions {
+	case "":
+	case "true", "false":
+		malfunctions := query.HasMalfunctions == "true"
+		filter.malfunctions = &malfunctions
+	default:
+		return nil, fmt.Errorf("HasMalfunctions must be \"true\", \"false\" or empty!")
+	}
+
+	return &filter, nil
+}
+
+func (f *carFilter) matches(car *Car) bool {
+	switch car.Status {
+	case CarStatusScrapped:
+		return false
+	case CarStatusWrittenOff:
+		if !f.query.IncludeWrittenOff {
+			return false
+		}
+	}
+
+	if f.query.Brand != "" && car.Brand != f.query.Brand {
+		return false
+	}
+	if f.query.Model != "" && car.Model != f.query.Model {
+		return false
+	}
+	if f.query.MinYear != 0 && car.Year < f.query.MinYear {
+		return false
+	}
+	if f.query.MaxYear != 0 && car.Year > f.query.MaxYear {
+		return false
+	}
+
+	for _, bound := range []*Money{f.minPrice, f.maxPrice} {
+		if bound != nil && car.Price.Currency != bound.Currency {
+			return false
+		}
+	}
+	if f.minPrice != nil && car.Price.LessThan(*f.minPrice) {
+		return false
+	}
+	if f.maxPrice != nil && f.maxPrice.LessThan(car.Price) {
+		return false
+	}
+
+	if f.malfunctions != nil && (len(car.Malfunctions) > 0) != *f.malfunctions {
+		return false
+	}
+
+	return true
+}
+
+// selector builds the CouchDB selector equivalent to matches.
+func (f *carFilter) selector() map[string]interface{} {
+	selector := map[string]interface{}{
+		"DocType": carDocType,
+	}
+
+	if f.query.IncludeWrittenOff {
+		selector["Status"] = map[string]interface{}{ "$ne": CarStatusScrapped }
+	} else {
+		selector["Status"] = map[string]interface{}{ "$nin": []string{CarStatusScrapped, CarStatusWrittenOff} }
+	}
+
+	if f.query.Brand != "" {
+		selector["Brand"] = f.query.Brand
+	}
+	if f.query.Model != "" {
+		selector["Model"] = f.query.Model
+	}
+
+	year := map[string]interface{}{}
+	if f.query.MinYear != 0 {
+		year["$gte"] = f.query.MinYear
+	}
+	if f.query.MaxYear != 0 {
+		year["$lte"] = f.query.MaxYear
+	}
+	if len(year) > 0 {
+		selector["Year"] = year
+	}
+
+	price := map[string]interface{}{}
+	if f.minPrice != nil {
+		selector["Price.Currency"] = f.minPrice.Currency
+		price["$gte"] = f.minPrice.Amount
+	}
+	if f.maxPrice != nil {
+		selector["Price.Currency"] = f.maxPrice.Currency
+		price["$lte"] = f.maxPrice.Amount
+	}
+	if len(price) > 0 {
+		selector["Price.Amount"] = price
+	}
+
+	if f.malfunctions != nil {
+		anyMalfunction := map[string]interface{}{
+			"$elemMatch": map[string]interface{}{ "Description": map[string]interface{}{ "$exists": true } },
+		}
+
+		if *f.malfunctions {
+			selector["Malfunctions"] = anyMalfunction
+		} else {
+			selector["Malfunctions"] = map[string]interface{}{ "$not": anyMalfunction }
+		}
+	}
+
+	return selector
+}
+
+func queryCarsWithSelector(ctx contractapi.TransactionContextInterface, filter *carFilter, pageSize int32, bookmark string) (*PaginatedCarsResult, error) {
+	queryJson, err := json.Marshal(map[string]interface{}{ "selector": filter.selector() })
+	if err != nil {
+		return nil, err
+	}
+
+	carsIter, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryJson), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer carsIter.Close()
+
+	cars := make([]*Car, 0)
+	for carsIter.HasNext() {
+		queryResponse, err := carsIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var car Car
+		err = json.Unmarshal(queryResponse.Value, &car)
+		if err != nil {
+			return nil, err
+		}
+
+		if car.Status == "" {
+			car.Status = CarStatusActive
+		}
+
+		cars = append(cars, &car)
+	}
+
+	return &PaginatedCarsResult {
+		Cars: cars,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark: metadata.Bookmark,
+	}, nil
+}
+
+// queryCarsWithIndexes filters the cars found through the most selective
+// composite index, which it reads a page at a time. The bookmark is the index
+// key of the first car of the next page.
+func (s *SmartContract) queryCarsWithIndexes(ctx contractapi.TransactionContextInterface, filter *carFilter, pageSize int32, bookmark string) (*PaginatedCarsResult, error) {
+	cars := make([]*Car, 0)
+	nextBookmark := ""
+
+	err := s.forEachIndexedCar(ctx, filter, pageSize, bookmark, func(key string, carId string) (bool, error) {
+		car, err := s.GetCar(ctx, carId)
+		if err != nil {
+			return false, err
+		}
+
+		if !filter.matches(car) {
+			return true, nil
+		}
+
+		if len(cars) == int(pageSize) {
+			nextBookmark = key
+			return false, nil
+		}
+
+		cars = append(cars, car)
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedCarsResult {
+		Cars: cars,
+		FetchedRecordsCount: int32(len(cars)),
+		Bookmark: nextBookmark,
+	}, nil
+}
+
+// forEachIndexedCar calls visit with the index key and car id of every car
+// the filter may match from the index key bookmark on, until visit returns
+// false.
+func (s *SmartContract) forEachIndexedCar(ctx contractapi.TransactionContextInterface, filter *carFilter, pageSize int32, bookmark string, visit func(string, string) (bool, error)) error {
+	query := filter.query
+
+	if query.Brand != "" {
+		attributes := []string{query.Brand}
+		if query.Model != "" {
+			attributes = append(attributes, query.Model)
+		}
+
+		_, err := visitIndex(ctx, brandModelIndex, attributes, pageSize, bookmark, visit)
+		return err
+	}
+
+	if query.MinYear != 0 || query.MaxYear != 0 {
+		txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+		if err != nil {
+			return err
+		}
+
+		from, to := firstCarYear, time.Unix(txTimestamp.GetSeconds(), 0).UTC().Year() + 1
+		if query.MinYear > from {
+			from = query.MinYear
+		}
+		if query.MaxYear != 0 && query.MaxYear < to {
+			to = query.MaxYear
+		}
+
+		// The years before the one of the bookmark were visited already.
+		bookmarkYear := 0
+		if bookmark != "" {
+			_, keyParts, err := ctx.GetStub().SplitCompositeKey(bookmark)
+			if err == nil && len(keyParts) == 2 {
+				bookmarkYear, err = strconv.Atoi(keyParts[0])
+			}
+			if err != nil || bookmarkYear < from || bookmarkYear > to {
+				return fmt.Errorf("Bookmark is not valid for the query!")
+			}
+
+			from = bookmarkYear
+		}
+
+		for year := from; year <= to; year++ {
+			yearBookmark := ""
+			if year == bookmarkYear {
+				yearBookmark = bookmark
+			}
+
+			more, err := visitIndex(ctx, yearIndex, []string{strconv.Itoa(year)}, pageSize, yearBookmark, visit)
+			if err != nil || !more {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	_, err := visitIndex(ctx, carKeyType, []string{}, pageSize, bookmark, visit)
+	return err
+}
+
+// visitIndex reads the keys of the index from bookmark on, pageSize keys at a
+// time, and reports whether visit asked for more keys. The car id is always
+// the last attribute of the key.
+func visitIndex(ctx contractapi.TransactionContextInterface, index string, attributes []string, pageSize int32, bookmark string, visit func(string, string) (bool, error)) (bool, error) {
+	if bookmark != "" {
+		prefix, err := ctx.GetStub().CreateCompositeKey(index, attributes)
+		if err != nil {
+			return false, err
+		}
+		if !strings.HasPrefix(bookmark, prefix) {
+			return false, fmt.Errorf("Bookmark is not valid for the query!")
+		}
+	}
+
+	for {
+		more, next, err := visitIndexPage(ctx, index, attributes, pageSize, bookmark, visit)
+		if err != nil || !more || next == "" {
+			return more, err
+		}
+
+		bookmark = next
+	}
+}
+
+// visitIndexPage visits a page of the index and returns the bookmark of the
+// next one, which is empty after the last page.
+func visitIndexPage(ctx contractapi.TransactionContextInterface, index string, attributes []string, pageSize int32, bookmark string, visit func(string, string) (bool, error)) (bool, string, error) {
+	keysIter, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(index, attributes, pageSize, bookmark)
+	if err != nil {
+		return false, "", err
+	}
+	defer keysIter.Close()
+
+	for keysIter.HasNext() {
+		responseRange, err := keysIter.Next()
+		if err != nil {
+			return false, "", err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return false, "", err
+		}
+
+		more, err := visit(responseRange.Key, keyParts[len(keyParts)-1])
+		if err != nil || !more {
+			return false, "", err
+		}
+	}
+
+	return true, metadata.Bookmark, nil
+}
+
+func queryIndexKeys(ctx contractapi.TransactionContextInterface, car *Car) ([]string, error) {
+	brandModelKey, err := ctx.GetStub().CreateCompositeKey(brandModelIndex, []string{car.Brand, car.Model, car.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Car years always have four digits, so year keys sort numerically.
+	yearKey, err := ctx.GetStub().CreateCompositeKey(yearIndex, []string{strconv.Itoa(car.Year), car.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{brandModelKey, yearKey}, nil
+}
+
+func putQueryIndexes(ctx contractapi.TransactionContextInterface, car *Car) error {
+	keys, err := queryIndexKeys(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	value := []byte{0x00}
+	for _, key := range keys {
+		err = ctx.GetStub().PutState(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteQueryIndexes(ctx contractapi.TransactionContextInterface, car *Car) error {
+	keys, err := queryIndexKeys(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
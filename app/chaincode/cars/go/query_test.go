@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/internal/fakestub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCars(t *testing.T) {
+	l := newSeededLedger(t)
+
+	for _, test := range []struct {
+		name		string
+		query		CarQuery
+		expected	[]string
+	}{
+		{ "all", CarQuery{}, []string{"c1", "c2", "c3", "c4", "c5", "c6"} },
+		{ "brand", CarQuery { Brand: "Audi" }, []string{"c4", "c5"} },
+		{ "brand and model", CarQuery { Brand: "Audi", Model: "A6" }, []string{"c4"} },
+		{ "model", CarQuery { Model: "RAV4" }, []string{"c3"} },
+		{ "year range", CarQuery { MinYear: 2015, MaxYear: 2018 }, []string{"c1", "c5", "c3"} },
+		{ "minimum year", CarQuery { MinYear: 2019 }, []string{"c2", "c6"} },
+		{ "brand and year", CarQuery { Brand: "Audi", MaxYear: 2012 }, []string{"c4"} },
+		{ "price range", CarQuery { MinPrice: "4000", MaxPrice: "5000" }, []string{"c3", "c5", "c6"} },
+		{ "other currency", CarQuery { MinPrice: "4000 USD" }, []string{} },
+		{ "without malfunctions", CarQuery { HasMalfunctions: "false" }, []string{"c3"} },
+		{ "with malfunctions", CarQuery { HasMalfunctions: "true", MaxPrice: "4000" }, []string{"c2", "c4"} },
+	} {
+		result, err := l.contract.QueryCars(l.tx(strangerClient), test.query, 10, "")
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.expected, carIds(result.Cars), test.name)
+		require.Equal(t, int32(len(test.expected)), result.FetchedRecordsCount, test.name)
+		require.Empty(t, result.Bookmark, test.name)
+	}
+}
+
+// TestQueryCarsOnCouchDB runs the selector built by QueryCars, which returns
+// cars in key order rather than in index order.
+func TestQueryCarsOnCouchDB(t *testing.T) {
+	l := newSeededLedger(t)
+	l.stub.SetStateDatabase(fakestub.CouchDB)
+
+	for _, test := range []struct {
+		name		string
+		query		CarQuery
+		expected	[]string
+	}{
+		{ "all", CarQuery{}, []string{"c1", "c2", "c3", "c4", "c5", "c6"} },
+		{ "brand", CarQuery { Brand: "Audi" }, []string{"c4", "c5"} },
+		{ "year range", CarQuery { MinYear: 2015, MaxYear: 2018 }, []string{"c1", "c3", "c5"} },
+		{ "price range", CarQuery { MinPrice: "4000", MaxPrice: "5000" }, []string{"c3", "c5", "c6"} },
+		{ "other currency", CarQuery { MinPrice: "4000 USD" }, []string{} },
+		{ "without malfunctions", CarQuery { HasMalfunctions: "false" }, []string{"c3"} },
+		{ "with malfunctions", CarQuery { HasMalfunctions: "true", MaxPrice: "4000" }, []string{"c2", "c4"} },
+	} {
+		result, err := l.contract.QueryCars(l.tx(strangerClient), test.query, 10, "")
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.expected, carIds(result.Cars), test.name)
+	}
+
+	err := l.contract.ScrapCar(l.tx(markoClient), "c5")
+	require.NoError(t, err)
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4"}, carIds(result.Cars))
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery{}, 4, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c2", "c3", "c4"}, carIds(result.Cars))
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery{}, 4, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c6"}, carIds(result.Cars))
+	require.Equal(t, int32(1), result.FetchedRecordsCount)
+}
+
+func TestQueryCarsPagination(t *testing.T) {
+	l := newSeededLedger(t)
+
+	var pages [][]string
+	bookmark := ""
+	for {
+		result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery{}, 4, bookmark)
+		require.NoError(t, err)
+
+		pages = append(pages, carIds(result.Cars))
+		if result.Bookmark == "" {
+			break
+		}
+		bookmark = result.Bookmark
+	}
+	require.Equal(t, [][]string{{"c1", "c2", "c3", "c4"}, {"c5", "c6"}}, pages)
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 1, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4"}, carIds(result.Cars))
+	require.NotEmpty(t, result.Bookmark)
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 1, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c5"}, carIds(result.Cars))
+	require.Empty(t, result.Bookmark)
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2015 }, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c5"}, carIds(result.Cars))
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2015 }, 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c3", "c2"}, carIds(result.Cars))
+	require.NotEmpty(t, result.Bookmark)
+}
+
+// TestQueryCarsStateDatabase checks that the state database is found out once,
+// or taken from CARS_STATE_DATABASE. Year queries tell the paths apart, as the
+// index returns cars by year and CouchDB by key.
+func TestQueryCarsStateDatabase(t *testing.T) {
+	l := newSeededLedger(t)
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2015, MaxYear: 2018 }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c5", "c3"}, carIds(result.Cars))
+
+	l.stub.SetStateDatabase(fakestub.CouchDB)
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2015, MaxYear: 2018 }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c5", "c3"}, carIds(result.Cars))
+
+	os.Setenv(stateDatabaseEnv, "couchdb")
+	defer os.Unsetenv(stateDatabaseEnv)
+
+	l = newSeededLedger(t)
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery{}, 10, "")
+	require.EqualError(t, err, "ExecuteQueryWithPagination not supported for leveldb")
+
+	os.Setenv(stateDatabaseEnv, "leveldb")
+
+	l = newSeededLedger(t)
+	l.stub.SetStateDatabase(fakestub.CouchDB)
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2015, MaxYear: 2018 }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c5", "c3"}, carIds(result.Cars))
+}
+
+func TestQueryCarsBookmarkOfAnotherQuery(t *testing.T) {
+	l := newSeededLedger(t)
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2015 }, 1, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Bookmark)
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 1, result.Bookmark)
+	require.EqualError(t, err, "Bookmark is not valid for the query!")
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2019 }, 1, result.Bookmark)
+	require.EqualError(t, err, "Bookmark is not valid for the query!")
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2015 }, 1, "c1")
+	require.EqualError(t, err, "Bookmark is not valid for the query!")
+}
+
+// TestQueryCarsReadsIndexPages fills a page from several pages of the index.
+func TestQueryCarsReadsIndexPages(t *testing.T) {
+	l := newSeededLedger(t)
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { HasMalfunctions: "false" }, 1, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c3"}, carIds(result.Cars))
+	require.Empty(t, result.Bookmark)
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { HasMalfunctions: "true", MinYear: 2010 }, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4", "c1"}, carIds(result.Cars))
+	require.NotEmpty(t, result.Bookmark)
+}
+
+func TestQueryCarsFollowsUpdates(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.UpdateCar(l.tx(petarClient), "c1", "Audi", "Q5", 2012, "5200")
+	require.NoError(t, err)
+
+	err = l.contract.ScrapCar(l.tx(markoClient), "c5")
+	require.NoError(t, err)
+
+	result, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4", "c1"}, carIds(result.Cars))
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Jeep" }, 10, "")
+	require.NoError(t, err)
+	require.Empty(t, result.Cars)
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2012, MaxYear: 2012 }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, carIds(result.Cars))
+
+	err = l.contract.SetWriteOffThreshold(l.tx(adminClient), 1)
+	require.NoError(t, err)
+
+	err = l.contract.AddNewMalfunction(l.tx(stefanClient), "c4", "Ogrebotina", "10")
+	require.NoError(t, err)
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi" }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, carIds(result.Cars))
+
+	result, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { Brand: "Audi", IncludeWrittenOff: true }, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c4", "c1"}, carIds(result.Cars))
+}
+
+func TestQueryCarsValidation(t *testing.T) {
+	l := newSeededLedger(t)
+
+	_, err := l.contract.QueryCars(l.tx(strangerClient), CarQuery{}, 0, "")
+	require.EqualError(t, err, "Page size must be between 1 and 100!")
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery{}, 101, "")
+	require.EqualError(t, err, "Page size must be between 1 and 100!")
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinYear: 2020, MaxYear: 2010 }, 10, "")
+	require.EqualError(t, err, "Minimum year must not be after maximum year!")
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinPrice: "10 USD", MaxPrice: "20" }, 10, "")
+	require.EqualError(t, err, "Price range must be in a single currency!")
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { MinPrice: "20", MaxPrice: "10" }, 10, "")
+	require.EqualError(t, err, "Minimum price must not be above maximum price!")
+
+	_, err = l.contract.QueryCars(l.tx(strangerClient), CarQuery { HasMalfunctions: "maybe" }, 10, "")
+	require.EqualError(t, err, "HasMalfunctions must be \"true\", \"false\" or empty!")
+}
+
+func TestCarFilterSelector(t *testing.T) {
+	filter, err := newCarFilter(CarQuery {
+		Brand: "Audi",
+		MinYear: 2010,
+		MaxYear: 2015,
+		MinPrice: "2500",
+		HasMalfunctions: "true",
+	})
+	require.NoError(t, err)
+
+	selector, err := json.Marshal(filter.selector())
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"DocType": "car",
+		"Status": {"$nin": ["scrapped", "written-off"]},
+		"Brand": "Audi",
+		"Year": {"$gte": 2010, "$lte": 2015},
+		"Price.Currency": "EUR",
+		"Price.Amount": {"$gte": 250000},
+		"Malfunctions": {"$elemMatch": {"Description": {"$exists": true}}}
+	}`, string(selector))
+}
+
+// TestQueryCarsArgumentMetadata invokes QueryCars through the contract API,
+// which checks the query against the contract metadata. Every CarQuery field
+// but IncludeWrittenOff may be left out.
+func TestQueryCarsArgumentMetadata(t *testing.T) {
+	l := newSeededLedger(t)
+	l.commit()
+
+	chaincode, err := contractapi.NewChaincode(new(SmartContract))
+	require.NoError(t, err)
+
+	response := l.stub.Invoke(chaincode, []byte("QueryCars"), []byte(`{"Brand":"Audi","IncludeWrittenOff":false}`), []byte("10"), []byte(""))
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+
+	var result PaginatedCarsResult
+	require.NoError(t, json.Unmarshal(response.Payload, &result))
+	require.Equal(t, []string{"c4", "c5"}, carIds(result.Cars))
+
+	response = l.stub.Invoke(chaincode, []byte("QueryCars"), []byte(`{"Brand":"Audi"}`), []byte("10"), []byte(""))
+	require.Equal(t, int32(shim.ERROR), response.Status)
+	require.Contains(t, response.Message, "Value did not match schema")
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	RepairReported		= "reported"
+	RepairQuoted		= "quoted"
+	RepairApproved		= "approved"
+	RepairInProgress	= "in-progress"
+	RepairCompleted		= "completed"
+	RepairCancelled		= "cancelled"
+
+	repairDocType		= "repair"
+	repairKeyType		= "repair~ID"
+	carRepairIndex		= "car~repair"
+)
+
+// RepairOrder covers the malfunctions a car had when the order was opened.
+// The quoted price is taken from the owner when the quote is approved and
+// paid to the mechanic when the work is completed.
+type RepairOrder struct {
+	DocType			string
+	ID				string
+	CarID			string
+	Owner			string
+	Mechanic		string
+	Malfunctions	[]Malfunction
+	Quote			Money
+	Status			string
+	UpdatedAt		time.Time
+}
+
+// OpenRepairOrder reports the car's current malfunctions for repair.
+func (s *SmartContract) OpenRepairOrder(ctx contractapi.TransactionContextInterface, orderId string, carId string) error {
+	if strings.TrimSpace(orderId) == "" {
+		return fmt.Errorf("Repair order id must not be empty!")
+	}
+
+	car, err := s.GetCar(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = assertCarActive(car)
+	if err != nil {
+		return err
+	}
+
+	err = s.assertCarOwnerOrRole(ctx, car, "open repair orders for the car")
+	if err != nil {
+		return err
+	}
+
+	if len(car.Malfunctions) == 0 {
+		return fmt.Errorf("Car with id %s has no malfunctions to repair!", carId)
+	}
+
+	open, err := s.openRepairOrder(ctx, carId)
+	if err != nil {
+		return err
+	}
+	if open != nil {
+		return fmt.Errorf("Car with id %s already has open repair order %s!", carId, open.ID)
+	}
+
+	key, err := repairKey(ctx, orderId)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("Repair order with id %s already exists!", orderId)
+	}
+
+	order := RepairOrder {
+		ID: orderId,
+		CarID: carId,
+		Owner: car.Owner,
+		Malfunctions: car.Malfunctions,
+		Quote: Money { Currency: car.Price.Currency },
+		Status: RepairReported,
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(carRepairIndex, []string{carId, orderId})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return err
+	}
+
+	return updateRepairOrder(ctx, &order)
+}
+
+// QuoteRepairOrder sets the price of the work. The submitting client must be a
+// mechanic linked to a person, who is paid once the repair is completed. A
+// quoted order may be quoted again until the owner approves it.
+func (s *SmartContract) QuoteRepairOrder(ctx contractapi.TransactionContextInterface, orderId string, price string) error {
+	order, err := s.GetRepairOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+
+	err = assertRepairStatus(order, RepairReported, RepairQuoted)
+	if err != nil {
+		return err
+	}
+
+	mechanic, err := s.callerMechanic(ctx)
+	if err != nil {
+		return err
+	}
+
+	if mechanic.ID == order.Owner {
+		return fmt.Errorf("Owner cannot quote the repair of their own car!")
+	}
+
+	quote, err := parsePositiveAmount(price)
+	if err != nil {
+		return err
+	}
+
+	order.Mechanic = mechanic.ID
+	order.Quote = quote
+	order.Status = RepairQuoted
+
+	return updateRepairOrder(ctx, order)
+}
+
+// ApproveRepairOrder accepts the quote and takes the quoted price from the
+// owner, to be held until the repair is completed or cancelled.
+func (s *SmartContract) ApproveRepairOrder(ctx contractapi.TransactionContextInterface, orderId string) error {
+	order, err := s.GetRepairOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+
+	err = assertRepairStatus(order, RepairQuoted)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.GetPerson(ctx, order.Owner)
+	if err != nil {
+		return err
+	}
+
+	err = assertPersonOrRole(ctx, owner, "approve the repair order")
+	if err != nil {
+		return err
+	}
+
+	if owner.Money.LessThan(order.Quote) {
+		return fmt.Errorf("Owner does not have enough money to pay!")
+	}
+
+	owner.Money, err = owner.Money.Sub(order.Quote)
+	if err != nil {
+		return err
+	}
+
+	err = putPerson(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	order.Status = RepairApproved
+
+	return updateRepairOrder(ctx, order)
+}
+
+func (s *SmartContract) StartRepairOrder(ctx contractapi.TransactionContextInterface, orderId string) error {
+	order, err := s.GetRepairOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+
+	err = assertRepairStatus(order, RepairApproved)
+	if err != nil {
+		return err
+	}
+
+	err = s.assertOrderMechanic(ctx, order, "start the repair")
+	if err != nil {
+		return err
+	}
+
+	order.Status = RepairInProgress
+
+	return updateRepairOrder(ctx, order)
+}
+
+// CompleteRepairOrder removes the repaired malfunctions from the car and pays
+// the quoted price to the mechanic.
+func (s *SmartContract) CompleteRepairOrder(ctx contractapi.TransactionContextInterface, orderId string) error {
+	order, err := s.GetRepairOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+
+	err = assertRepairStatus(order, RepairInProgress)
+	if err != nil {
+		return err
+	}
+
+	err = s.assertOrderMechanic(ctx, order, "complete the repair")
+	if err != nil {
+		return err
+	}
+
+	car, err := s.GetCar(ctx, order.CarID)
+	if err != nil {
+		return err
+	}
+
+	mechanic, err := s.GetPerson(ctx, order.Mechanic)
+	if err != nil {
+		return err
+	}
+
+	mechanic.Money, err = mechanic.Money.Add(order.Quote)
+	if err != nil {
+		return err
+	}
+
+	var repaired bool
+	car.Malfunctions, repaired = removeMalfunctions(car.Malfunctions, order.Malfunctions)
+	if !repaired {
+		return fmt.Errorf("Malfunctions of repair order %s are no longer on car %s!", order.ID, car.ID)
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = putPerson(ctx, mechanic)
+	if err != nil {
+		return err
+	}
+
+	order.Status = RepairCompleted
+
+	err = putRepairOrder(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCarRepaired, CarRepairedEvent {
+		CarID: car.ID,
+		Owner: order.Owner,
+		Mechanic: mechanic.ID,
+		Cost: order.Quote,
+		Malfunctions: order.Malfunctions,
+	})
+}
+
+// CancelRepairOrder lets the owner call off a repair that has not started yet.
+// A price already taken on approval is returned to the owner.
+func (s *SmartContract) CancelRepairOrder(ctx contractapi.TransactionContextInterface, orderId string) error {
+	order, err := s.GetRepairOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+
+	err = assertRepairStatus(order, RepairReported, RepairQuoted, RepairApproved)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.GetPerson(ctx, order.Owner)
+	if err != nil {
+		return err
+	}
+
+	err = assertPersonOrRole(ctx, owner, "cancel the repair order")
+	if err != nil {
+		return err
+	}
+
+	err = cancelRepairOrder(ctx, order, owner)
+	if err != nil {
+		return err
+	}
+
+	return emitRepairOrderUpdated(ctx, order)
+}
+
+func (s *SmartContract) GetRepairOrder(ctx contractapi.TransactionContextInterface, orderId string) (*RepairOrder, error) {
+	key, err := repairKey(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	orderJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load repair order from world state: %v", err)
+	}
+	if orderJson == nil {
+		return nil, fmt.Errorf("Repair order with id %s does not exist!", orderId)
+	}
+
+	var order RepairOrder
+	err = json.Unmarshal(orderJson, &order)
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func (s *SmartContract) GetRepairOrdersForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*RepairOrder, error) {
+	ordersIter, err := ctx.GetStub().GetStateByPartialCompositeKey(carRepairIndex, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer ordersIter.Close()
+
+	orders := make([]*RepairOrder, 0)
+	for ordersIter.HasNext() {
+		responseRange, err := ordersIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		order, err := s.GetRepairOrder(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// openRepairOrder returns the order of the car that is neither completed nor
+// cancelled, or nil when there is none.
+func (s *SmartContract) openRepairOrder(ctx contractapi.TransactionContextInterface, carId string) (*RepairOrder, error) {
+	orders, err := s.GetRepairOrdersForCar(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, order := range orders {
+		if order.Status != RepairCompleted && order.Status != RepairCancelled {
+			return order, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// callerMechanic returns the person linked to the submitting client, which
+// must hold the mechanic role.
+func (s *SmartContract) callerMechanic(ctx contractapi.TransactionContextInterface) (*Person, error) {
+	err := assertRole(ctx, "repair cars", RoleMechanic)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCallerPerson(ctx)
+}
+
+func (s *SmartContract) assertOrderMechanic(ctx contractapi.TransactionContextInterface, order *RepairOrder, action string) error {
+	mechanic, err := s.callerMechanic(ctx)
+	if err != nil {
+		return err
+	}
+
+	if mechanic.ID != order.Mechanic {
+		return fmt.Errorf("Submitting client is not allowed to %s!", action)
+	}
+
+	return nil
+}
+
+// cancelOpenRepairOrders is used when a car leaves circulation or changes
+// owner, so money held for approved repairs goes back to whoever paid it.
+// Reads do not see writes of the same transaction, so each owner is loaded
+// once and refunds accumulate on that copy. loaded are the persons the
+// transaction already changed, whose copies must be used instead.
+func (s *SmartContract) cancelOpenRepairOrders(ctx contractapi.TransactionContextInterface, carId string, loaded ...*Person) error {
+	orders, err := s.GetRepairOrdersForCar(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	owners := make(map[string]*Person)
+	for _, person := range loaded {
+		owners[person.ID] = person
+	}
+	for _, order := range orders {
+		if order.Status == RepairCompleted || order.Status == RepairCancelled {
+			continue
+		}
+
+		owner, loaded := owners[order.Owner]
+		if !loaded {
+			owner, err = s.GetPerson(ctx, order.Owner)
+			if err != nil {
+				return err
+			}
+
+			owners[order.Owner] = owner
+		}
+
+		err = cancelRepairOrder(ctx, order, owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cancelRepairOrder(ctx contractapi.TransactionContextInterface, order *RepairOrder, owner *Person) error {
+	if order.Status == RepairApproved || order.Status == RepairInProgress {
+		var err error
+		owner.Money, err = owner.Money.Add(order.Quote)
+		if err != nil {
+			return err
+		}
+
+		err = putPerson(ctx, owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	order.Status = RepairCancelled
+
+	return putRepairOrder(ctx, order)
+}
+
+func assertRepairStatus(order *RepairOrder, allowed ...string) error {
+	for _, status := range allowed {
+		if order.Status == status {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Repair order %s is %s, expected %s!", order.ID, order.Status, strings.Join(allowed, " or "))
+}
+
+// removeMalfunctions removes every repaired malfunction from the car once, so
+// malfunctions reported after the order was opened stay on the car. It tells
+// whether all of them were still on the car.
+func removeMalfunctions(malfunctions []Malfunction, repaired []Malfunction) ([]Malfunction, bool) {
+	toRemove := make(map[Malfunction]int)
+	for _, malfunction := range repaired {
+		toRemove[malfunction]++
+	}
+
+	remaining := make([]Malfunction, 0)
+	for _, malfunction := range malfunctions {
+		if toRemove[malfunction] > 0 {
+			toRemove[malfunction]--
+			continue
+		}
+
+		remaining = append(remaining, malfunction)
+	}
+
+	return remaining, len(remaining) + len(repaired) == len(malfunctions)
+}
+
+// updateRepairOrder stores the order and announces its new status.
+func updateRepairOrder(ctx contractapi.TransactionContextInterface, order *RepairOrder) error {
+	err := putRepairOrder(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	return emitRepairOrderUpdated(ctx, order)
+}
+
+func emitRepairOrderUpdated(ctx contractapi.TransactionContextInterface, order *RepairOrder) error {
+	return emitEvent(ctx, EventRepairOrderUpdated, RepairOrderUpdatedEvent {
+		OrderID: order.ID,
+		CarID: order.CarID,
+		Owner: order.Owner,
+		Status: order.Status,
+		Mechanic: order.Mechanic,
+		Quote: order.Quote,
+	})
+}
+
+func repairKey(ctx contractapi.TransactionContextInterface, orderId string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(repairKeyType, []string{orderId})
+}
+
+func putRepairOrder(ctx contractapi.TransactionContextInterface, order *RepairOrder) error {
+	key, err := repairKey(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	order.DocType = repairDocType
+	order.UpdatedAt, err = txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	orderJson, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, orderJson)
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state! %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairOrder(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	var event RepairOrderUpdatedEvent
+	l.requireEvent(EventRepairOrderUpdated, &event)
+	require.Equal(t, RepairReported, event.Status)
+	require.Equal(t, "1", event.Owner)
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "50")
+	require.NoError(t, err)
+
+	order, err := l.contract.GetRepairOrder(l.tx(strangerClient), "r1")
+	require.NoError(t, err)
+	require.Equal(t, RepairQuoted, order.Status)
+	require.Equal(t, "m1", order.Mechanic)
+	require.Equal(t, NewMoney(50_00), order.Quote)
+	require.Len(t, order.Malfunctions, 2)
+
+	err = l.contract.ApproveRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+	require.Equal(t, int64(7650_00), l.balance("1"))
+
+	err = l.contract.StartRepairOrder(l.tx(mechanicClient), "r1")
+	require.NoError(t, err)
+
+	err = l.contract.AddNewMalfunction(l.tx(petarClient), "c1", "Zamena brisaca", "15")
+	require.NoError(t, err)
+
+	err = l.contract.CompleteRepairOrder(l.tx(mechanicClient), "r1")
+	require.NoError(t, err)
+
+	var repaired CarRepairedEvent
+	l.requireEvent(EventCarRepaired, &repaired)
+	require.Equal(t, NewMoney(50_00), repaired.Cost)
+
+	require.Equal(t, []Malfunction{{ Description: "Zamena brisaca", Price: NewMoney(15_00) }}, l.car("c1").Malfunctions)
+	require.Equal(t, int64(50_00), l.balance("m1"))
+	require.Equal(t, int64(7650_00), l.balance("1"))
+
+	orders, err := l.contract.GetRepairOrdersForCar(l.tx(strangerClient), "c1")
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Equal(t, RepairCompleted, orders[0].Status)
+}
+
+func TestOpenRepairOrder(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(markoClient), "r1", "c3")
+	require.EqualError(t, err, "Car with id c3 has no malfunctions to repair!")
+
+	err = l.contract.OpenRepairOrder(l.tx(markoClient), "r1", "c1")
+	require.EqualError(t, err, "Submitting client is not allowed to open repair orders for the car!")
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "", "c1")
+	require.EqualError(t, err, "Repair order id must not be empty!")
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c2")
+	require.EqualError(t, err, "Repair order with id r1 already exists!")
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r2", "c1")
+	require.EqualError(t, err, "Car with id c1 already has open repair order r1!")
+
+	err = l.contract.CancelRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r2", "c1")
+	require.NoError(t, err)
+}
+
+func TestCompleteRepairOrderOfRemovedMalfunctions(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "50")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+
+	err = l.contract.StartRepairOrder(l.tx(mechanicClient), "r1")
+	require.NoError(t, err)
+
+	// Orders opened before a car was limited to one of them could repair
+	// the same malfunctions.
+	car := l.car("c1")
+	car.Malfunctions = car.Malfunctions[1:]
+	err = putCar(l.tx(registryClient), car)
+	require.NoError(t, err)
+
+	err = l.contract.CompleteRepairOrder(l.tx(mechanicClient), "r1")
+	require.EqualError(t, err, "Malfunctions of repair order r1 are no longer on car c1!")
+	require.Len(t, l.car("c1").Malfunctions, 1)
+	require.Equal(t, int64(0), l.balance("m1"))
+}
+
+func TestQuoteRepairOrder(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(markoClient), "r1", "50")
+	require.EqualError(t, err, "Submitting client is not allowed to repair cars!")
+
+	ownerMechanic := &testIdentity { mspId: "Org4MSP", id: "petar-mechanic", roles: "mechanic" }
+	err = l.contract.LinkPersonIdentity(l.tx(registryClient), "1", ownerMechanic.mspId, ownerMechanic.id)
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(ownerMechanic), "r1", "50")
+	require.EqualError(t, err, "Owner cannot quote the repair of their own car!")
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "0")
+	require.EqualError(t, err, "Amount must be greater than zero!")
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r42", "50")
+	require.EqualError(t, err, "Repair order with id r42 does not exist!")
+}
+
+func TestApproveRepairOrder(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(stefanClient), "r1", "c4")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(stefanClient), "r1")
+	require.EqualError(t, err, "Repair order r1 is reported, expected quoted!")
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "5100.01")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(markoClient), "r1")
+	require.EqualError(t, err, "Submitting client is not allowed to approve the repair order!")
+
+	err = l.contract.ApproveRepairOrder(l.tx(stefanClient), "r1")
+	require.EqualError(t, err, "Owner does not have enough money to pay!")
+}
+
+func TestStartRepairOrderByOtherMechanic(t *testing.T) {
+	l := newSeededLedger(t)
+
+	otherMechanic := &testIdentity { mspId: "Org4MSP", id: "mechanic2", roles: "mechanic" }
+	err := l.contract.CreatePerson(l.tx(otherMechanic), "m2", "Mika", "Mikic", "mika@gmail.com")
+	require.NoError(t, err)
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "50")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+
+	err = l.contract.StartRepairOrder(l.tx(otherMechanic), "r1")
+	require.EqualError(t, err, "Submitting client is not allowed to start the repair!")
+
+	err = l.contract.CompleteRepairOrder(l.tx(mechanicClient), "r1")
+	require.EqualError(t, err, "Repair order r1 is approved, expected in-progress!")
+}
+
+func TestCancelRepairOrder(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "50")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+	require.Equal(t, int64(7650_00), l.balance("1"))
+
+	err = l.contract.CancelRepairOrder(l.tx(mechanicClient), "r1")
+	require.EqualError(t, err, "Submitting client is not allowed to cancel the repair order!")
+
+	err = l.contract.CancelRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+	require.Equal(t, int64(7700_00), l.balance("1"))
+
+	order, err := l.contract.GetRepairOrder(l.tx(strangerClient), "r1")
+	require.NoError(t, err)
+	require.Equal(t, RepairCancelled, order.Status)
+
+	err = l.contract.CancelRepairOrder(l.tx(petarClient), "r1")
+	require.EqualError(t, err, "Repair order r1 is cancelled, expected reported or quoted or approved!")
+}
+
+func TestScrapCarCancelsRepairOrders(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.CancelRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r2", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r2", "50")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(petarClient), "r2")
+	require.NoError(t, err)
+
+	err = l.contract.StartRepairOrder(l.tx(mechanicClient), "r2")
+	require.NoError(t, err)
+
+	err = l.contract.ScrapCar(l.tx(petarClient), "c1")
+	require.NoError(t, err)
+
+	orders, err := l.contract.GetRepairOrdersForCar(l.tx(strangerClient), "c1")
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	for _, order := range orders {
+		require.Equal(t, RepairCancelled, order.Status)
+	}
+
+	require.Equal(t, int64(7700_00), l.balance("1"))
+	require.Equal(t, int64(0), l.balance("m1"))
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	listingDocType	= "listing"
+	offerDocType	= "offer"
+
+	listingKeyType	= "listing~carID"
+	offerKeyType	= "offer~carID~buyerID"
+)
+
+// SaleListing is the seller's consent to sell a car for AskingPrice. A zero
+// ExpiresAt means the listing stays open until it is withdrawn.
+type SaleListing struct {
+	DocType			string
+	CarID			string
+	Seller			string
+	SellerIdentity	string
+	AskingPrice		Money
+	ExpiresAt		time.Time
+}
+
+// PurchaseOffer is the buyer's consent to buy a listed car for Price. It is
+// settled only when the owner of the car accepts it.
+type PurchaseOffer struct {
+	DocType				string
+	CarID				string
+	Buyer				string
+	BuyerIdentity		string
+	Price				Money
+	AcceptMalfunctions	bool
+	ExpiresAt			time.Time
+}
+
+// ListCarForSale opens the car for offers. validFor is a duration such as
+// "72h"; an empty value keeps the listing open until it is withdrawn.
+// Listing the car again replaces the previous asking price and expiry.
+func (s *SmartContract) ListCarForSale(ctx contractapi.TransactionContextInterface, carId string, askingPrice string, validFor string) error {
+	car, err := s.GetCar(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = assertCarActive(car)
+	if err != nil {
+		return err
+	}
+
+	err = s.assertCarOwnerOrRole(ctx, car, "list the car for sale")
+	if err != nil {
+		return err
+	}
+
+	price, err := parsePositiveAmount(askingPrice)
+	if err != nil {
+		return err
+	}
+
+	expiresAt, err := expiryFromNow(ctx, validFor)
+	if err != nil {
+		return err
+	}
+
+	sellerIdentity, err := submittingIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	listing := SaleListing {
+		DocType: listingDocType,
+		CarID: carId,
+		Seller: car.Owner,
+		SellerIdentity: sellerIdentity,
+		AskingPrice: price,
+		ExpiresAt: expiresAt,
+	}
+
+	return putSaleRecord(ctx, listingKeyType, []string{carId}, listing)
+}
+
+// WithdrawListing closes the sale. Only the identity that listed the car may
+// withdraw it, unless the listing has already expired.
+func (s *SmartContract) WithdrawListing(ctx contractapi.TransactionContextInterface, carId string) error {
+	listing, err := s.GetSaleListing(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	expired, err := isExpired(ctx, listing.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	if !expired {
+		err = assertSubmitter(ctx, listing.SellerIdentity, "withdraw the listing")
+		if err != nil {
+			return err
+		}
+	}
+
+	return deleteSaleRecords(ctx, carId)
+}
+
+func (s *SmartContract) GetSaleListing(ctx contractapi.TransactionContextInterface, carId string) (*SaleListing, error) {
+	var listing SaleListing
+	found, err := getSaleRecord(ctx, listingKeyType, []string{carId}, &listing)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Car with id %s is not listed for sale!", carId)
+	}
+
+	return &listing, nil
+}
+
+// MakeOffer records the buyer's offer for a listed car. answer must be "yes"
+// when the car has malfunctions, acknowledging that it is bought as is.
+func (s *SmartContract) MakeOffer(ctx contractapi.TransactionContextInterface, carId string, buyerId string, price string, answer string, validFor string) error {
+	car, listing, err := s.getOpenListing(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	buyer, err := s.GetPerson(ctx, buyerId)
+	if err != nil {
+		return err
+	}
+
+	err = assertPersonOrRole(ctx, buyer, "make offers for the buyer")
+	if err != nil {
+		return err
+	}
+
+	if car.Owner == buyer.ID {
+		return fmt.Errorf("Buyer is already owner of the car!")
+	}
+
+	offerPrice, err := parsePositiveAmount(price)
+	if err != nil {
+		return err
+	}
+
+	if offerPrice.Currency != listing.AskingPrice.Currency {
+		return fmt.Errorf("Offer must be made in %s!", listing.AskingPrice.Currency)
+	}
+
+	acceptMalfunctions := answer == "yes"
+	if len(car.Malfunctions) > 0 && !acceptMalfunctions {
+		return fmt.Errorf("Buyer does not want to buy the car.")
+	}
+
+	if buyer.Money.LessThan(offerPrice) {
+		return fmt.Errorf("Buyer does not have enough money!")
+	}
+
+	expiresAt, err := expiryFromNow(ctx, validFor)
+	if err != nil {
+		return err
+	}
+
+	buyerIdentity, err := submittingIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	offer := PurchaseOffer {
+		DocType: offerDocType,
+		CarID: carId,
+		Buyer: buyerId,
+		BuyerIdentity: buyerIdentity,
+		Price: offerPrice,
+		AcceptMalfunctions: acceptMalfunctions,
+		ExpiresAt: expiresAt,
+	}
+
+	return putSaleRecord(ctx, offerKeyType, []string{carId, buyerId}, offer)
+}
+
+// WithdrawOffer removes the buyer's offer. Only the identity that made the
+// offer may withdraw it, unless the offer has already expired.
+func (s *SmartContract) WithdrawOffer(ctx contractapi.TransactionContextInterface, carId string, buyerId string) error {
+	offer, err := s.getOffer(ctx, carId, buyerId)
+	if err != nil {
+		return err
+	}
+
+	expired, err := isExpired(ctx, offer.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	if !expired {
+		err = assertSubmitter(ctx, offer.BuyerIdentity, "withdraw the offer")
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(offerKeyType, []string{carId, buyerId})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+func (s *SmartContract) GetOffers(ctx contractapi.TransactionContextInterface, carId string) ([]*PurchaseOffer, error) {
+	offersIter, err := ctx.GetStub().GetStateByPartialCompositeKey(offerKeyType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer offersIter.Close()
+
+	offers := make([]*PurchaseOffer, 0)
+	for offersIter.HasNext() {
+		response, err := offersIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var offer PurchaseOffer
+		err = json.Unmarshal(response.Value, &offer)
+		if err != nil {
+			return nil, err
+		}
+
+		offers = append(offers, &offer)
+	}
+
+	return offers, nil
+}
+
+// AcceptOffer settles the sale at the offered price. It must be submitted by
+// the identity linked to the owner when the offer is accepted, so both parties
+// have agreed on the ledger.
+func (s *SmartContract) AcceptOffer(ctx contractapi.TransactionContextInterface, carId string, buyerId string) (bool, error) {
+	car, _, err := s.getOpenListing(ctx, carId)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.assertCarOwnerOrRole(ctx, car, "accept offers for the car")
+	if err != nil {
+		return false, err
+	}
+
+	offer, err := s.getOffer(ctx, carId, buyerId)
+	if err != nil {
+		return false, err
+	}
+
+	expired, err := isExpired(ctx, offer.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	if expired {
+		return false, fmt.Errorf("Offer of buyer %s for car %s has expired!", buyerId, carId)
+	}
+
+	if len(car.Malfunctions) > 0 && !offer.AcceptMalfunctions {
+		return false, fmt.Errorf("Buyer does not want to buy the car.")
+	}
+
+	err = s.settleSale(ctx, car, buyerId, offer.Price)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getOpenListing returns the car together with its listing, failing when the
+// listing expired or no longer belongs to the car's current owner.
+func (s *SmartContract) getOpenListing(ctx contractapi.TransactionContextInterface, carId string) (*Car, *SaleListing, error) {
+	car, err := s.GetCar(ctx, carId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = assertCarActive(car)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listing, err := s.GetSaleListing(ctx, carId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if listing.Seller != car.Owner {
+		return nil, nil, fmt.Errorf("Listing for car %s was not made by its current owner!", carId)
+	}
+
+	expired, err := isExpired(ctx, listing.ExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if expired {
+		return nil, nil, fmt.Errorf("Listing for car %s has expired!", carId)
+	}
+
+	return car, listing, nil
+}
+
+func (s *SmartContract) getOffer(ctx contractapi.TransactionContextInterface, carId string, buyerId string) (*PurchaseOffer, error) {
+	var offer PurchaseOffer
+	found, err := getSaleRecord(ctx, offerKeyType, []string{carId, buyerId}, &offer)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Buyer %s has no offer for car %s!", buyerId, carId)
+	}
+
+	return &offer, nil
+}
+
+// settleSale moves the car to the buyer and the price to the seller, then
+// closes the listing together with every remaining offer. Open repair orders
+// of the seller are cancelled, and a price they already paid is returned.
+func (s *SmartContract) settleSale(ctx contractapi.TransactionContextInterface, car *Car, buyerId string, price Money) error {
+	buyer, err := s.GetPerson(ctx, buyerId)
+	if err != nil {
+		return err
+	}
+
+	seller, err := s.GetPerson(ctx, car.Owner)
+	if err != nil {
+		return err
+	}
+
+	if seller.ID == buyer.ID {
+		return fmt.Errorf("Buyer is already owner of the car!")
+	}
+
+	if buyer.Money.LessThan(price) {
+		return fmt.Errorf("Buyer does not have enough money!")
+	}
+
+	buyer.Money, err = buyer.Money.Sub(price)
+	if err != nil {
+		return err
+	}
+
+	seller.Money, err = seller.Money.Add(price)
+	if err != nil {
+		return err
+	}
+
+	err = s.cancelOpenRepairOrders(ctx, car.ID, seller, buyer)
+	if err != nil {
+		return err
+	}
+
+	err = deleteColorIndex(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = deleteOwnerIndex(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	car.Owner = buyer.ID
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = putColorIndex(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = putOwnerIndex(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = putPerson(ctx, buyer)
+	if err != nil {
+		return err
+	}
+
+	err = putPerson(ctx, seller)
+	if err != nil {
+		return err
+	}
+
+	err = deleteSaleRecords(ctx, car.ID)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCarSold, CarSoldEvent {
+		CarID: car.ID,
+		Seller: seller.ID,
+		Buyer: buyer.ID,
+		Price: price,
+	})
+}
+
+func putSaleRecord(ctx contractapi.TransactionContextInterface, keyType string, attributes []string, record interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(keyType, attributes)
+	if err != nil {
+		return err
+	}
+
+	recordJson, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, recordJson)
+}
+
+func getSaleRecord(ctx contractapi.TransactionContextInterface, keyType string, attributes []string, record interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(keyType, attributes)
+	if err != nil {
+		return false, err
+	}
+
+	recordJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from world state: %v", err)
+	}
+	if recordJson == nil {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(recordJson, record)
+}
+
+// deleteSaleRecords removes the listing of the car and all offers made for it.
+func deleteSaleRecords(ctx contractapi.TransactionContextInterface, carId string) error {
+	listingKey, err := ctx.GetStub().CreateCompositeKey(listingKeyType, []string{carId})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(listingKey)
+	if err != nil {
+		return err
+	}
+
+	offersIter, err := ctx.GetStub().GetStateByPartialCompositeKey(offerKeyType, []string{carId})
+	if err != nil {
+		return err
+	}
+	defer offersIter.Close()
+
+	for offersIter.HasNext() {
+		response, err := offersIter.Next()
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().DelState(response.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// submittingIdentity identifies the client that submitted the transaction by
+// its MSP and X.509 subject and issuer.
+func submittingIdentity(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspId, clientId, err := clientIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return mspId + "::" + clientId, nil
+}
+
+func assertSubmitter(ctx contractapi.TransactionContextInterface, expected string, action string) error {
+	identity, err := submittingIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if identity != expected {
+		return fmt.Errorf("Submitting client is not allowed to %s!", action)
+	}
+
+	return nil
+}
+
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(txTimestamp.GetSeconds(), int64(txTimestamp.GetNanos())).UTC(), nil
+}
+
+func expiryFromNow(ctx contractapi.TransactionContextInterface, validFor string) (time.Time, error) {
+	if validFor == "" {
+		return time.Time{}, nil
+	}
+
+	duration, err := time.ParseDuration(validFor)
+	if err != nil || duration <= 0 {
+		return time.Time{}, fmt.Errorf("Validity %q is not a positive duration!", validFor)
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return now.Add(duration), nil
+}
+
+func isExpired(ctx contractapi.TransactionContextInterface, expiresAt time.Time) (bool, error) {
+	if expiresAt.IsZero() {
+		return false, nil
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return !now.Before(expiresAt), nil
+}
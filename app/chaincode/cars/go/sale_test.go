@@ -0,0 +1,249 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCarForSale(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "72h")
+	require.NoError(t, err)
+
+	listing, err := l.contract.GetSaleListing(l.tx(strangerClient), "c3")
+	require.NoError(t, err)
+	require.Equal(t, "2", listing.Seller)
+	require.Equal(t, "Org4MSP::marko", listing.SellerIdentity)
+	require.Equal(t, NewMoney(4000_00), listing.AskingPrice)
+	require.False(t, listing.ExpiresAt.IsZero())
+
+	err = l.contract.ListCarForSale(l.tx(petarClient), "c3", "1", "")
+	require.EqualError(t, err, "Submitting client is not allowed to list the car for sale!")
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "-1h")
+	require.EqualError(t, err, "Validity \"-1h\" is not a positive duration!")
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c3", "3900", "")
+	require.NoError(t, err)
+
+	listing, err = l.contract.GetSaleListing(l.tx(strangerClient), "c3")
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(3900_00), listing.AskingPrice)
+	require.True(t, listing.ExpiresAt.IsZero())
+}
+
+func TestWithdrawListing(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "1h")
+	require.NoError(t, err)
+
+	err = l.contract.WithdrawListing(l.tx(petarClient), "c3")
+	require.EqualError(t, err, "Submitting client is not allowed to withdraw the listing!")
+
+	err = l.contract.WithdrawListing(l.tx(markoClient), "c3")
+	require.NoError(t, err)
+
+	_, err = l.contract.GetSaleListing(l.tx(strangerClient), "c3")
+	require.EqualError(t, err, "Car with id c3 is not listed for sale!")
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "1h")
+	require.NoError(t, err)
+
+	l.now = l.now.Add(2 * time.Hour)
+
+	err = l.contract.WithdrawListing(l.tx(petarClient), "c3")
+	require.NoError(t, err)
+}
+
+func TestMakeOffer(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "3800", "no", "")
+	require.EqualError(t, err, "Car with id c3 is not listed for sale!")
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "3800", "no", "24h")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(stefanClient), "c3", "3", "3900", "no", "")
+	require.NoError(t, err)
+
+	offers, err := l.contract.GetOffers(l.tx(strangerClient), "c3")
+	require.NoError(t, err)
+	require.Len(t, offers, 2)
+	require.Equal(t, "1", offers[0].Buyer)
+	require.Equal(t, NewMoney(3800_00), offers[0].Price)
+	require.Equal(t, "3", offers[1].Buyer)
+
+	err = l.contract.MakeOffer(l.tx(stefanClient), "c3", "1", "3800", "no", "")
+	require.EqualError(t, err, "Submitting client is not allowed to make offers for the buyer!")
+
+	err = l.contract.MakeOffer(l.tx(markoClient), "c3", "2", "3800", "no", "")
+	require.EqualError(t, err, "Buyer is already owner of the car!")
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "3800 USD", "no", "")
+	require.EqualError(t, err, "Offer must be made in EUR!")
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "7700.01", "no", "")
+	require.EqualError(t, err, "Buyer does not have enough money!")
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c5", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c5", "1", "3000", "maybe", "")
+	require.EqualError(t, err, "Buyer does not want to buy the car.")
+}
+
+func TestWithdrawOffer(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "3800", "no", "")
+	require.NoError(t, err)
+
+	err = l.contract.WithdrawOffer(l.tx(markoClient), "c3", "1")
+	require.EqualError(t, err, "Submitting client is not allowed to withdraw the offer!")
+
+	err = l.contract.WithdrawOffer(l.tx(petarClient), "c3", "1")
+	require.NoError(t, err)
+
+	offers, err := l.contract.GetOffers(l.tx(strangerClient), "c3")
+	require.NoError(t, err)
+	require.Empty(t, offers)
+
+	err = l.contract.WithdrawOffer(l.tx(petarClient), "c3", "1")
+	require.EqualError(t, err, "Buyer 1 has no offer for car c3!")
+}
+
+func TestAcceptOffer(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "3800", "no", "")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(stefanClient), "c3", "3", "3700", "no", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.AcceptOffer(l.tx(petarClient), "c3", "1")
+	require.EqualError(t, err, "Submitting client is not allowed to accept offers for the car!")
+
+	accepted, err := l.contract.AcceptOffer(l.tx(markoClient), "c3", "1")
+	require.NoError(t, err)
+	require.True(t, accepted)
+
+	var event CarSoldEvent
+	l.requireEvent(EventCarSold, &event)
+	require.Equal(t, NewMoney(3800_00), event.Price)
+
+	require.Equal(t, "1", l.car("c3").Owner)
+	require.Equal(t, int64(3900_00), l.balance("1"))
+	require.Equal(t, int64(6650_00), l.balance("2"))
+
+	offers, err := l.contract.GetOffers(l.tx(strangerClient), "c3")
+	require.NoError(t, err)
+	require.Empty(t, offers)
+}
+
+func TestAcceptOfferOwnerIdentity(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "3800", "no", "")
+	require.NoError(t, err)
+
+	relinked := &testIdentity { mspId: "Org4MSP", id: "marko-new" }
+	err = l.contract.LinkPersonIdentity(l.tx(registryClient), "2", relinked.mspId, relinked.id)
+	require.NoError(t, err)
+
+	_, err = l.contract.AcceptOffer(l.tx(markoClient), "c3", "1")
+	require.EqualError(t, err, "Submitting client is not allowed to accept offers for the car!")
+
+	_, err = l.contract.BuyCar(l.tx(markoClient), "c3", "1", "no")
+	require.EqualError(t, err, "Submitting client is not allowed to buy cars for the buyer!")
+
+	accepted, err := l.contract.AcceptOffer(l.tx(relinked), "c3", "1")
+	require.NoError(t, err)
+	require.True(t, accepted)
+	require.Equal(t, "1", l.car("c3").Owner)
+}
+
+func TestAcceptOfferExpired(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.MakeOffer(l.tx(petarClient), "c3", "1", "3800", "no", "1h")
+	require.NoError(t, err)
+
+	l.now = l.now.Add(2 * time.Hour)
+
+	_, err = l.contract.AcceptOffer(l.tx(markoClient), "c3", "1")
+	require.EqualError(t, err, "Offer of buyer 1 for car c3 has expired!")
+
+	err = l.contract.WithdrawOffer(l.tx(stefanClient), "c3", "1")
+	require.NoError(t, err)
+}
+
+func TestListingOfPreviousOwner(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(petarClient), "c3", "1", "no")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(stefanClient), "c3", "3", "no")
+	require.EqualError(t, err, "Car with id c3 is not listed for sale!")
+}
+
+func TestSaleCancelsRepairOrders(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.NoError(t, err)
+
+	err = l.contract.QuoteRepairOrder(l.tx(mechanicClient), "r1", "50")
+	require.NoError(t, err)
+
+	err = l.contract.ApproveRepairOrder(l.tx(petarClient), "r1")
+	require.NoError(t, err)
+	require.Equal(t, int64(7650_00), l.balance("1"))
+
+	err = l.contract.ListCarForSale(l.tx(petarClient), "c1", "2000", "")
+	require.NoError(t, err)
+
+	_, err = l.contract.BuyCar(l.tx(markoClient), "c1", "2", "yes")
+	require.NoError(t, err)
+
+	var event CarSoldEvent
+	l.requireEvent(EventCarSold, &event)
+	require.Equal(t, "1", event.Seller)
+
+	order, err := l.contract.GetRepairOrder(l.tx(strangerClient), "r1")
+	require.NoError(t, err)
+	require.Equal(t, RepairCancelled, order.Status)
+	require.Equal(t, int64(9700_00), l.balance("1"))
+	require.Equal(t, int64(850_00), l.balance("2"))
+
+	err = l.contract.StartRepairOrder(l.tx(mechanicClient), "r1")
+	require.Error(t, err)
+
+	err = l.contract.OpenRepairOrder(l.tx(markoClient), "r2", "c1")
+	require.NoError(t, err)
+	require.Len(t, l.car("c1").Malfunctions, 2)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	configDocType				= "config"
+	configKeyType				= "config~name"
+	writeOffConfigName			= "writeOff"
+
+	// defaultWriteOffThreshold writes a car off once repairing it costs more
+	// than the car is worth.
+	defaultWriteOffThreshold	= 100
+)
+
+// WriteOffConfig is the ledger wide write-off rule. A car is written off when
+// the cost of its outstanding malfunctions exceeds ThresholdPercent of its price.
+type WriteOffConfig struct {
+	DocType				string
+	ThresholdPercent	int
+}
+
+// SetWriteOffThreshold changes the write-off threshold, given as a percentage
+// of the car price. It applies to malfunctions reported from now on; cars that
+// are already over the new threshold are written off on their next report.
+func (s *SmartContract) SetWriteOffThreshold(ctx contractapi.TransactionContextInterface, thresholdPercent int) error {
+	err := assertRole(ctx, "change the write-off threshold", RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if thresholdPercent <= 0 {
+		return fmt.Errorf("Write-off threshold must be greater than zero!")
+	}
+
+	key, err := configKey(ctx, writeOffConfigName)
+	if err != nil {
+		return err
+	}
+
+	config := WriteOffConfig {
+		DocType: configDocType,
+		ThresholdPercent: thresholdPercent,
+	}
+
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, configJson)
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state! %v", err)
+	}
+
+	return nil
+}
+
+// GetWriteOffConfig returns the write-off rule in force, falling back to the
+// default threshold when an admin has never set one.
+func (s *SmartContract) GetWriteOffConfig(ctx contractapi.TransactionContextInterface) (*WriteOffConfig, error) {
+	key, err := configKey(ctx, writeOffConfigName)
+	if err != nil {
+		return nil, err
+	}
+
+	configJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state: %v", err)
+	}
+	if configJson == nil {
+		return &WriteOffConfig { DocType: configDocType, ThresholdPercent: defaultWriteOffThreshold }, nil
+	}
+
+	var config WriteOffConfig
+	err = json.Unmarshal(configJson, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// exceedsThreshold reports whether repairCost is more than thresholdPercent
+// of price. Both amounts must be in the same currency.
+func exceedsThreshold(repairCost Money, price Money, thresholdPercent int) bool {
+	cost := new(big.Int).Mul(big.NewInt(repairCost.Amount), big.NewInt(100))
+	limit := new(big.Int).Mul(big.NewInt(price.Amount), big.NewInt(int64(thresholdPercent)))
+
+	return cost.Cmp(limit) > 0
+}
+
+// writeOffCar marks the car as a total loss. Unlike a scrapped car it stays in
+// the color index, so queries can still return it when asked to, but it can no
+// longer be sold or repaired and its open sale and repair orders are closed.
+func (s *SmartContract) writeOffCar(ctx contractapi.TransactionContextInterface, car *Car, repairCost Money, thresholdPercent int) error {
+	car.Status = CarStatusWrittenOff
+
+	err := putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = s.cancelOpenRepairOrders(ctx, car.ID)
+	if err != nil {
+		return err
+	}
+
+	err = deleteSaleRecords(ctx, car.ID)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventWrittenOff, WrittenOffEvent {
+		CarID: car.ID,
+		Owner: car.Owner,
+		Price: car.Price,
+		RepairCost: repairCost,
+		ThresholdPercent: thresholdPercent,
+	})
+}
+
+func configKey(ctx contractapi.TransactionContextInterface, name string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configKeyType, []string{name})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetWriteOffThreshold(t *testing.T) {
+	l := newSeededLedger(t)
+
+	config, err := l.contract.GetWriteOffConfig(l.tx(strangerClient))
+	require.NoError(t, err)
+	require.Equal(t, 100, config.ThresholdPercent)
+
+	err = l.contract.SetWriteOffThreshold(l.tx(registryClient), 50)
+	require.EqualError(t, err, "Submitting client is not allowed to change the write-off threshold!")
+
+	err = l.contract.SetWriteOffThreshold(l.tx(adminClient), 0)
+	require.EqualError(t, err, "Write-off threshold must be greater than zero!")
+
+	err = l.contract.SetWriteOffThreshold(l.tx(adminClient), 50)
+	require.NoError(t, err)
+
+	config, err = l.contract.GetWriteOffConfig(l.tx(strangerClient))
+	require.NoError(t, err)
+	require.Equal(t, 50, config.ThresholdPercent)
+}
+
+func TestWriteOffAtDefaultThreshold(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.AddNewMalfunction(l.tx(stefanClient), "c4", "Zamena menjaca", "2659")
+	require.NoError(t, err)
+	l.requireEvent(EventMalfunctionReported, nil)
+	require.Equal(t, CarStatusActive, l.car("c4").Status)
+
+	err = l.contract.AddNewMalfunction(l.tx(stefanClient), "c4", "Ogrebotina", "0.01")
+	require.NoError(t, err)
+
+	var event WrittenOffEvent
+	l.requireEvent(EventWrittenOff, &event)
+	require.Equal(t, WrittenOffEvent {
+		CarID: "c4",
+		Owner: "3",
+		Price: NewMoney(2700_00),
+		RepairCost: NewMoney(2700_01),
+		ThresholdPercent: 100,
+	}, event)
+
+	car := l.car("c4")
+	require.Equal(t, CarStatusWrittenOff, car.Status)
+	require.Len(t, car.Malfunctions, 5)
+}
+
+func TestWriteOffAtConfiguredThreshold(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.SetWriteOffThreshold(l.tx(adminClient), 50)
+	require.NoError(t, err)
+
+	err = l.contract.ListCarForSale(l.tx(markoClient), "c3", "4000", "")
+	require.NoError(t, err)
+
+	err = l.contract.AddNewMalfunction(l.tx(markoClient), "c3", "Zamena motora", "2000")
+	require.NoError(t, err)
+	require.Equal(t, CarStatusActive, l.car("c3").Status)
+
+	err = l.contract.AddNewMalfunction(l.tx(markoClient), "c3", "Zamena guma", "100")
+	require.NoError(t, err)
+	l.requireEvent(EventWrittenOff, nil)
+	require.Equal(t, CarStatusWrittenOff, l.car("c3").Status)
+
+	_, err = l.contract.GetSaleListing(l.tx(strangerClient), "c3")
+	require.EqualError(t, err, "Car with id c3 is not listed for sale!")
+
+	cars, err := l.contract.GetCarsByColor(l.tx(strangerClient), "black", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, carIds(cars))
+
+	cars, err = l.contract.GetCarsByColor(l.tx(strangerClient), "black", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c3"}, carIds(cars))
+
+	cars, err = l.contract.GetCarsByOwnerAndColor(l.tx(strangerClient), "2", "black", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c3"}, carIds(cars))
+}
+
+func TestWrittenOffCar(t *testing.T) {
+	l := newSeededLedger(t)
+
+	err := l.contract.SetWriteOffThreshold(l.tx(adminClient), 1)
+	require.NoError(t, err)
+
+	err = l.contract.AddNewMalfunction(l.tx(petarClient), "c1", "Ogrebotina", "10")
+	require.NoError(t, err)
+	require.Equal(t, CarStatusWrittenOff, l.car("c1").Status)
+
+	_, err = l.contract.ChangeColor(l.tx(petarClient), "c1", "red")
+	require.EqualError(t, err, "Car with id c1 is written off!")
+
+	err = l.contract.OpenRepairOrder(l.tx(petarClient), "r1", "c1")
+	require.EqualError(t, err, "Car with id c1 is written off!")
+
+	err = l.contract.ListCarForSale(l.tx(petarClient), "c1", "100", "")
+	require.EqualError(t, err, "Car with id c1 is written off!")
+
+	err = l.contract.ScrapCar(l.tx(petarClient), "c1")
+	require.NoError(t, err)
+	require.Equal(t, CarStatusScrapped, l.car("c1").Status)
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const usage = `Usage: fabcar [flags] <command> [arguments] [command flags]
+
+Commands:
+  ledger init                                     fill the ledger with the sample persons and cars
+  persons get <id>                                show a person
+  persons cars <id>                               list the cars of a person
+  persons summary <id>                            show what the cars of a person are worth
+  cars get <id>                                   show a car
+  cars list                                       list the cars matching the filter flags
+  cars colored <color>                            list the cars of a color
+  cars color <id> <color>                         change the color of a car
+  cars malfunction <id> --description --price     report a malfunction of a car
+  cars buy <id> --buyer                           buy a car at its asking price
+  cars sell <id> --price                          list a car for sale
+  cars offer <id> --buyer --price                 offer a price for a listed car
+  cars accept-offer <id> --buyer                  sell a car for the offer of a buyer
+  cars repairs <id>                               list the repair orders of a car
+  repairs get <id>                                show a repair order
+  repairs open <id> --car                         report the malfunctions of a car for repair
+  repairs quote <id> --price                      quote the price of a repair
+  repairs approve <id>                            approve the quote of a repair and pay it
+  repairs start <id>                              start an approved repair
+  repairs complete <id>                           complete a repair and pay the mechanic
+  repairs cancel <id>                             cancel a repair that has not started
+  events                                          print chaincode events until Ctrl+C
+  shell                                           run the interactive menu
+
+Run a command with -h for its flags. Results are printed in the -output
+format. A wrong command exits with 2, and a failed transaction with 3 when
+the chaincode rejected it, 4 when it kept conflicting with concurrent
+transactions, 5 when it timed out and 1 otherwise.
+
+Flags:
+`
+
+// command is a subcommand with the arguments it takes and the flags it
+// requires. action describes it in error messages, e.g. "buy car". setup
+// defines the flags of the command and returns what it runs once they are
+// parsed.
+type command struct {
+	action		string
+	args		[]string
+	required	[]string
+	setup		func(flags *flag.FlagSet) run
+}
+
+// run runs a command on s and returns the JSON to print, if any.
+type run func(s *session, args []string) ([]byte, error)
+
+var commands = map[string]command {
+	"events": { "listen for events", nil, nil, func(flags *flag.FlagSet) run {
+		return func(s *session, args []string) ([]byte, error) {
+			return nil, listenForEvents(s.contract, s.cfg.EventForwardURL)
+		}
+	} },
+	"shell": { "run shell", nil, nil, func(flags *flag.FlagSet) run {
+		return func(s *session, args []string) ([]byte, error) {
+			return nil, shell(s)
+		}
+	} },
+}
+
+var groups = map[string]map[string]command {
+	"ledger": {
+		"init": { "initialize ledger", nil, nil, func(flags *flag.FlagSet) run {
+			return func(s *session, args []string) ([]byte, error) {
+				_, err := submitTransaction(s.contract, "InitLedger")
+				return nil, err
+			}
+		} },
+	},
+	"persons": {
+		"get": { "get person", []string{ "id" }, nil, evaluation("GetPerson") },
+		"cars": { "get cars by owner", []string{ "id" }, nil, func(flags *flag.FlagSet) run {
+			color := flags.String("color", "", "only cars of this color")
+			includeWrittenOff := flags.Bool("include-written-off", false, "also list written-off cars")
+
+			return func(s *session, args []string) ([]byte, error) {
+				if *color != "" {
+					return s.contract.EvaluateTransaction("GetCarsByOwnerAndColor", args[0], *color, strconv.FormatBool(*includeWrittenOff))
+				}
+				return s.contract.EvaluateTransaction("GetCarsByOwner", args[0], strconv.FormatBool(*includeWrittenOff))
+			}
+		} },
+		"summary": { "get owner summary", []string{ "id" }, nil, evaluation("GetOwnerSummary") },
+	},
+	"cars": {
+		"get": { "get car", []string{ "id" }, nil, evaluation("GetCar") },
+		"list": { "query cars", nil, nil, func(flags *flag.FlagSet) run {
+			query := carQuery{}
+			flags.StringVar(&query.Brand, "brand", "", "only cars of this brand")
+			flags.StringVar(&query.Model, "model", "", "only cars of this model")
+			flags.IntVar(&query.MinYear, "min-year", 0, "only cars made in or after this year")
+			flags.IntVar(&query.MaxYear, "max-year", 0, "only cars made in or before this year")
+			flags.StringVar(&query.MinPrice, "min-price", "", "only cars worth at least this amount, e.g. 2500.00")
+			flags.StringVar(&query.MaxPrice, "max-price", "", "only cars worth at most this amount, e.g. 5000.00")
+			flags.BoolVar(&query.IncludeWrittenOff, "include-written-off", false, "also list written-off cars")
+			var hasMalfunctions optionalBool
+			flags.Var(&hasMalfunctions, "has-malfunctions", "only cars with malfunctions, or without them when false")
+			pageSize := flags.Int("page-size", carQueryMaxPageSize, "cars fetched per transaction")
+
+			return func(s *session, args []string) ([]byte, error) {
+				if hasMalfunctions.set {
+					query.HasMalfunctions = strconv.FormatBool(hasMalfunctions.value)
+				}
+				return queryAllCars(s.contract, query, *pageSize)
+			}
+		} },
+		"colored": { "get cars by color", []string{ "color" }, nil, func(flags *flag.FlagSet) run {
+			includeWrittenOff := flags.Bool("include-written-off", false, "also list written-off cars")
+
+			return func(s *session, args []string) ([]byte, error) {
+				return s.contract.EvaluateTransaction("GetCarsByColor", args[0], strconv.FormatBool(*includeWrittenOff))
+			}
+		} },
+		"color": { "change car color", []string{ "id", "color" }, nil, func(flags *flag.FlagSet) run {
+			return carSubmission("ChangeColor")
+		} },
+		"malfunction": { "add malfunction", []string{ "id" }, []string{ "description", "price" }, func(flags *flag.FlagSet) run {
+			description := flags.String("description", "", "what is broken")
+			price := flags.String("price", "", "cost of the repair, e.g. 32.30")
+
+			return func(s *session, args []string) ([]byte, error) {
+				return carSubmission("AddNewMalfunction")(s, []string{ args[0], *description, *price })
+			}
+		} },
+		"buy": { "buy car", []string{ "id" }, []string{ "buyer" }, func(flags *flag.FlagSet) run {
+			buyer := flags.String("buyer", "", "id of the buying person")
+			acceptMalfunctions := flags.Bool("accept-malfunctions", false, "buy the car despite its malfunctions")
+
+			return func(s *session, args []string) ([]byte, error) {
+				return carSubmission("BuyCar")(s, []string{ args[0], *buyer, yesNo(*acceptMalfunctions) })
+			}
+		} },
+		"sell": { "list car for sale", []string{ "id" }, []string{ "price" }, func(flags *flag.FlagSet) run {
+			price := flags.String("price", "", "asking price, e.g. 4500.00")
+			validFor := flags.String("valid-for", "", "how long the listing is open, e.g. 72h (default until withdrawn)")
+
+			return func(s *session, args []string) ([]byte, error) {
+				_, err := submitTransaction(s.contract, "ListCarForSale", args[0], *price, *validFor)
+				if err != nil {
+					return nil, err
+				}
+				return s.contract.EvaluateTransaction("GetSaleListing", args[0])
+			}
+		} },
+		"offer": { "make offer", []string{ "id" }, []string{ "buyer", "price" }, func(flags *flag.FlagSet) run {
+			buyer := flags.String("buyer", "", "id of the buying person")
+			price := flags.String("price", "", "offered price, e.g. 4200.00")
+			acceptMalfunctions := flags.Bool("accept-malfunctions", false, "buy the car despite its malfunctions")
+			validFor := flags.String("valid-for", "", "how long the offer is open, e.g. 24h (default until withdrawn)")
+
+			return func(s *session, args []string) ([]byte, error) {
+				_, err := submitTransaction(s.contract, "MakeOffer", args[0], *buyer, *price, yesNo(*acceptMalfunctions), *validFor)
+				if err != nil {
+					return nil, err
+				}
+				return s.contract.EvaluateTransaction("GetOffers", args[0])
+			}
+		} },
+		"accept-offer": { "accept offer", []string{ "id" }, []string{ "buyer" }, func(flags *flag.FlagSet) run {
+			buyer := flags.String("buyer", "", "id of the buyer whose offer is accepted")
+
+			return func(s *session, args []string) ([]byte, error) {
+				return carSubmission("AcceptOffer")(s, []string{ args[0], *buyer })
+			}
+		} },
+		"repairs": { "get repair orders", []string{ "id" }, nil, evaluation("GetRepairOrdersForCar") },
+	},
+	"repairs": {
+		"get": { "get repair order", []string{ "id" }, nil, evaluation("GetRepairOrder") },
+		"open": { "open repair order", []string{ "id" }, []string{ "car" }, func(flags *flag.FlagSet) run {
+			car := flags.String("car", "", "id of the car to repair")
+
+			return func(s *session, args []string) ([]byte, error) {
+				return repairSubmission("OpenRepairOrder")(s, []string{ args[0], *car })
+			}
+		} },
+		"quote": { "quote repair order", []string{ "id" }, []string{ "price" }, func(flags *flag.FlagSet) run {
+			price := flags.String("price", "", "price of the repair, e.g. 120.00")
+
+			return func(s *session, args []string) ([]byte, error) {
+				return repairSubmission("QuoteRepairOrder")(s, []string{ args[0], *price })
+			}
+		} },
+		"approve": { "approve repair order", []string{ "id" }, nil, func(flags *flag.FlagSet) run {
+			return repairSubmission("ApproveRepairOrder")
+		} },
+		"start": { "start repair order", []string{ "id" }, nil, func(flags *flag.FlagSet) run {
+			return repairSubmission("StartRepairOrder")
+		} },
+		"complete": { "complete repair order", []string{ "id" }, nil, func(flags *flag.FlagSet) run {
+			return repairSubmission("CompleteRepairOrder")
+		} },
+		"cancel": { "cancel repair order", []string{ "id" }, nil, func(flags *flag.FlagSet) run {
+			return repairSubmission("CancelRepairOrder")
+		} },
+	},
+}
+
+// evaluation runs the transaction name with the arguments of the command.
+func evaluation(name string) func(flags *flag.FlagSet) run {
+	return func(flags *flag.FlagSet) run {
+		return func(s *session, args []string) ([]byte, error) {
+			return s.contract.EvaluateTransaction(name, args...)
+		}
+	}
+}
+
+// carSubmission submits the transaction name, whose first argument is a car
+// id, and returns the car as stored after the transaction.
+func carSubmission(name string) run {
+	return func(s *session, args []string) ([]byte, error) {
+		_, err := submitTransaction(s.contract, name, args...)
+		if err != nil {
+			return nil, err
+		}
+		return s.contract.EvaluateTransaction("GetCar", args[0])
+	}
+}
+
+// repairSubmission submits the transaction name, whose first argument is a
+// repair order id, and returns the order as stored after the transaction.
+func repairSubmission(name string) run {
+	return func(s *session, args []string) ([]byte, error) {
+		_, err := submitTransaction(s.contract, name, args...)
+		if err != nil {
+			return nil, err
+		}
+		return s.contract.EvaluateTransaction("GetRepairOrder", args[0])
+	}
+}
+
+// parseCommand finds the command args name, e.g. cars buy c1 --buyer 2, and
+// parses its arguments and flags. Flags may come before, between or after the
+// arguments. The returned function runs the command and prints its result.
+func parseCommand(global *flag.FlagSet, args []string, output string) (func(s *session) error, error) {
+	if len(args) == 0 {
+		global.Usage()
+		return nil, fmt.Errorf("Missing command!")
+	}
+
+	name, rest := args[0], args[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		group, isGroup := groups[name]
+		if !isGroup {
+			global.Usage()
+			return nil, fmt.Errorf("Command %s does not exist!", name)
+		}
+		if len(rest) == 0 {
+			global.Usage()
+			return nil, fmt.Errorf("Missing %s command!", name)
+		}
+
+		cmd, ok = group[rest[0]]
+		if !ok {
+			global.Usage()
+			return nil, fmt.Errorf("Command %s %s does not exist!", name, rest[0])
+		}
+		name, rest = name + " " + rest[0], rest[1:]
+	}
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		line := "Usage: fabcar " + name
+		for _, arg := range cmd.args {
+			line += " <" + arg + ">"
+		}
+		fmt.Fprintf(flags.Output(), "%s [flags]\n\nFlags:\n", line)
+		flags.PrintDefaults()
+	}
+	format := flags.String("output", output, "output format of results: json, table or yaml")
+	runner := cmd.setup(flags)
+
+	var positional []string
+	for {
+		flags.Parse(rest)
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		rest = flags.Args()[1:]
+	}
+
+	if len(positional) != len(cmd.args) {
+		flags.Usage()
+		return nil, fmt.Errorf("Command %s takes %d arguments!", name, len(cmd.args))
+	}
+
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, required := range cmd.required {
+		if !given[required] {
+			flags.Usage()
+			return nil, fmt.Errorf("Command %s needs --%s!", name, required)
+		}
+	}
+
+	if !isFormat(*format) {
+		return nil, fmt.Errorf("Output format %s does not exist!", *format)
+	}
+
+	return func(s *session) error {
+		result, err := runner(s, positional)
+		if err != nil {
+			return failedTransaction(cmd.action, err)
+		}
+
+		return write(os.Stdout, *format, result)
+	}, nil
+}
+
+// optionalBool is a boolean flag that tells whether it was given.
+type optionalBool struct {
+	set		bool
+	value	bool
+}
+
+func (b *optionalBool) Set(value string) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+
+	b.set, b.value = true, parsed
+	return nil
+}
+
+func (b *optionalBool) String() string {
+	if b == nil || !b.set {
+		return ""
+	}
+	return strconv.FormatBool(b.value)
+}
+
+func (b *optionalBool) IsBoolFlag() bool {
+	return true
+}
+
+// yesNo returns the answer the chaincode takes for accepting malfunctions.
+func yesNo(accept bool) string {
+	if accept {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// carEventFilter matches every event emitted by the cars chaincode.
+const carEventFilter = ".*"
+
+type carEvent struct {
+	Name		string
+	TxID		string
+	BlockNumber	uint64
+	Payload		json.RawMessage
+}
+
+// listenForEvents prints chaincode events as they are committed until the user
+// presses Ctrl+C. When forwardURL is set every event is also POSTed to it.
+func listenForEvents(contract *gateway.Contract, forwardURL string) error {
+	registration, events, err := contract.RegisterEvent(carEventFilter)
+	if err != nil {
+		return fmt.Errorf("failed to register for chaincode events: %w", err)
+	}
+	defer contract.Unregister(registration)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	defer signal.Stop(stop)
+
+	fmt.Println("Listening for car events, press Ctrl+C to stop.")
+
+	client := &http.Client{ Timeout: 10 * time.Second }
+	for {
+		select {
+		case <-stop:
+			return nil
+		case ccEvent, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			event := toCarEvent(ccEvent)
+			fmt.Printf("%s (block %d, tx %s): %s\n", event.Name, event.BlockNumber, event.TxID, formatJson(event.Payload))
+
+			if forwardURL != "" {
+				err := forwardEvent(client, forwardURL, event)
+				if err != nil {
+					fmt.Printf("Failed to forward %s event: %s\n", event.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func toCarEvent(ccEvent *fab.CCEvent) carEvent {
+	payload := json.RawMessage(ccEvent.Payload)
+	if !json.Valid(payload) {
+		payload, _ = json.Marshal(string(ccEvent.Payload))
+	}
+
+	return carEvent{
+		Name: ccEvent.EventName,
+		TxID: ccEvent.TxID,
+		BlockNumber: ccEvent.BlockNumber,
+		Payload: payload,
+	}
+}
+
+func forwardEvent(client *http.Client, url string, event carEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", response.Status)
+	}
+
+	return nil
+}
@@ -2,4 +2,16 @@ module fabcar
 
 go 1.14
 
-require github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+require (
+	github.com/hyperledger/fabric-samples/client-config v0.0.0
+	github.com/hyperledger/fabric-samples/client-submit v0.0.0
+	github.com/hyperledger/fabric-samples/client-wallet v0.0.0
+	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+	gopkg.in/yaml.v2 v2.3.0
+)
+
+replace (
+	github.com/hyperledger/fabric-samples/client-config => ../../client-config
+	github.com/hyperledger/fabric-samples/client-submit => ../../client-submit
+	github.com/hyperledger/fabric-samples/client-wallet => ../../client-wallet
+)
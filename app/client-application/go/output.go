@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats of command results.
+const (
+	formatJSON	= "json"
+	formatTable	= "table"
+	formatYAML	= "yaml"
+)
+
+// hiddenColumns are record fields tables leave out: the document type and
+// the client identities persons, listings and offers are bound to.
+var hiddenColumns = map[string]bool {
+	"DocType": true,
+	"MSPID": true,
+	"ClientID": true,
+	"SellerIdentity": true,
+	"BuyerIdentity": true,
+}
+
+func isFormat(format string) bool {
+	return format == formatJSON || format == formatTable || format == formatYAML
+}
+
+// write prints result, the JSON a transaction returned, in format. JSON and
+// YAML keep every field in the order of the chaincode. A table has a row per
+// record, shows amounts as e.g. 1500.00 EUR and lists by their length.
+func write(w io.Writer, format string, result []byte) error {
+	if len(bytes.TrimSpace(result)) == 0 {
+		return nil
+	}
+
+	if format == formatJSON {
+		var indented bytes.Buffer
+		err := json.Indent(&indented, result, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		indented.WriteString("\n")
+		_, err = indented.WriteTo(w)
+		return err
+	}
+
+	value, err := decodeOrdered(result)
+	if err != nil {
+		return err
+	}
+
+	if format == formatYAML {
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(data)
+		return err
+	}
+
+	switch v := value.(type) {
+	case []yaml.MapSlice:
+		return writeTable(w, v)
+	case yaml.MapSlice:
+		return writeTable(w, []yaml.MapSlice{ v })
+	default:
+		_, err := fmt.Fprintln(w, cell(v))
+		return err
+	}
+}
+
+// decodeOrdered decodes a JSON array of objects, an object or a value, with
+// the fields of objects in their order.
+func decodeOrdered(data []byte) (interface{}, error) {
+	var records []yaml.MapSlice
+	if yaml.Unmarshal(data, &records) == nil {
+		return records, nil
+	}
+
+	var record yaml.MapSlice
+	if yaml.Unmarshal(data, &record) == nil {
+		return record, nil
+	}
+
+	var value interface{}
+	err := yaml.Unmarshal(data, &value)
+	return value, err
+}
+
+func writeTable(w io.Writer, records []yaml.MapSlice) error {
+	columns := []string{}
+	seen := map[string]bool{}
+	for _, record := range records {
+		for _, item := range record {
+			column := fmt.Sprint(item.Key)
+			if !seen[column] && !hiddenColumns[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	out := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, record := range records {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = "-"
+			for _, item := range record {
+				if fmt.Sprint(item.Key) == column {
+					cells[i] = cell(item.Value)
+				}
+			}
+		}
+
+		fmt.Fprintln(out, strings.Join(cells, "\t"))
+	}
+
+	return out.Flush()
+}
+
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	case []interface{}:
+		return strconv.Itoa(len(v))
+	case yaml.MapSlice:
+		if amount, ok := money(v); ok {
+			return amount
+		}
+
+		fields := []string{}
+		for _, item := range v {
+			fields = append(fields, fmt.Sprintf("%v=%s", item.Key, cell(item.Value)))
+		}
+		return strings.Join(fields, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// money formats an amount of the chaincode, whose Amount is in minor units.
+func money(record yaml.MapSlice) (string, bool) {
+	if len(record) != 2 || record[0].Key != "Amount" || record[1].Key != "Currency" {
+		return "", false
+	}
+
+	amount, ok := record[0].Value.(int)
+	if !ok {
+		return "", false
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d %v", sign, amount / 100, amount % 100, record[1].Value), true
+}
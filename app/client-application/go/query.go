@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// carQueryPageSize is the number of cars printed before asking for the next page.
+const carQueryPageSize = 5
+
+// carQueryMaxPageSize is the largest page QueryCars returns.
+const carQueryMaxPageSize = 100
+
+// carQuery mirrors the CarQuery argument of the QueryCars transaction.
+type carQuery struct {
+	Brand				string	`json:",omitempty"`
+	Model				string	`json:",omitempty"`
+	MinYear				int		`json:",omitempty"`
+	MaxYear				int		`json:",omitempty"`
+	MinPrice			string	`json:",omitempty"`
+	MaxPrice			string	`json:",omitempty"`
+	HasMalfunctions		string	`json:",omitempty"`
+	IncludeWrittenOff	bool
+}
+
+type carsPage struct {
+	Cars				[]json.RawMessage
+	FetchedRecordsCount	int32
+	Bookmark			string
+}
+
+// queryCars prints the cars matching the query page by page, for as long as
+// the user asks for more.
+func queryCars(contract *gateway.Contract, query carQuery) error {
+	queryJson, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+
+	bookmark := ""
+	for {
+		result, err := contract.EvaluateTransaction("QueryCars", string(queryJson), strconv.Itoa(carQueryPageSize), bookmark)
+		if err != nil {
+			return err
+		}
+
+		var page carsPage
+		err = json.Unmarshal(result, &page)
+		if err != nil {
+			return err
+		}
+
+		if page.FetchedRecordsCount == 0 && bookmark == "" {
+			fmt.Println("There are no cars matching the query.")
+			return nil
+		}
+
+		for _, car := range page.Cars {
+			fmt.Printf("%s\n", formatJson(car))
+		}
+
+		if page.Bookmark == "" || page.FetchedRecordsCount < carQueryPageSize {
+			return nil
+		}
+
+		fmt.Printf("Show next page? (yes/no)")
+		var answer string
+		fmt.Scanf("%s", &answer)
+		if answer != "yes" {
+			return nil
+		}
+
+		bookmark = page.Bookmark
+	}
+}
+
+func scanYear() int {
+	var value string
+	fmt.Scanf("%s", &value)
+
+	year, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+
+	return year
+}
+
+// queryAllCars returns every car matching the query as one JSON array,
+// fetching pageSize cars per transaction.
+func queryAllCars(contract *gateway.Contract, query carQuery, pageSize int) ([]byte, error) {
+	queryJson, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	cars := []json.RawMessage{}
+	bookmark := ""
+	for {
+		result, err := contract.EvaluateTransaction("QueryCars", string(queryJson), strconv.Itoa(pageSize), bookmark)
+		if err != nil {
+			return nil, err
+		}
+
+		var page carsPage
+		err = json.Unmarshal(result, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		cars = append(cars, page.Cars...)
+		if page.Bookmark == "" || int(page.FetchedRecordsCount) < pageSize {
+			return json.Marshal(cars)
+		}
+
+		bookmark = page.Bookmark
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// repairSteps are the transactions that advance a repair order, by the step
+// the shell asks for.
+var repairSteps = map[string]string {
+	"open": "OpenRepairOrder",
+	"quote": "QuoteRepairOrder",
+	"approve": "ApproveRepairOrder",
+	"start": "StartRepairOrder",
+	"complete": "CompleteRepairOrder",
+	"cancel": "CancelRepairOrder",
+}
+
+// shell runs the interactive menu of the client application on s until the
+// user exits or the input ends.
+func shell(s *session) error {
+	contract := s.contract
+	var option int
+
+	for {
+
+		fmt.Println("Choose an option:")
+		fmt.Println("0 - Initialize ledger")
+		fmt.Println("1 - Get person by id")
+		fmt.Println("2 - Get car by id")
+		fmt.Println("3 - Get cars by color")
+		fmt.Println("4 - Get cars by color and owner")
+		fmt.Println("5 - Change car color")
+		fmt.Println("6 - Advance repair order")
+		fmt.Println("7 - Add car malfunction")
+		fmt.Println("8 - Buy car")
+		fmt.Println("9 - Exit")
+		fmt.Println("10 - Listen for car events")
+		fmt.Println("11 - List car for sale")
+		fmt.Println("12 - Make offer for car")
+		fmt.Println("13 - Accept offer for car")
+		fmt.Println("14 - Query cars")
+		fmt.Println("15 - Get cars by owner")
+		fmt.Println("16 - Get owner summary")
+		fmt.Println("17 - Switch identity")
+
+		_, err := fmt.Scanf("%d", &option)
+		if err == io.EOF {
+			return nil
+		}
+
+		switch option {
+		case 0:
+
+			_, err := submitTransaction(contract, "InitLedger")
+			if err != nil {
+				fmt.Println(failedTransaction("init ledger", err))
+			}
+
+		case 1:
+
+			fmt.Printf("Enter person id: ")
+			var id string
+			fmt.Scanf("%s", &id)
+
+			result, err := contract.EvaluateTransaction("GetPerson", id)
+			if err != nil {
+				fmt.Printf("Failed to get person by id!")
+			}
+
+			fmt.Println(string(result))
+
+		case 2:
+
+			fmt.Printf("Enter car id: ")
+			var id string
+			fmt.Scanf("%s", &id)
+
+			result, err := contract.EvaluateTransaction("GetCar", id)
+			if err != nil {
+				fmt.Printf("Failed to get car by id!")
+			}
+
+			fmt.Println(string(result))
+
+		case 3:
+
+			fmt.Printf("Enter color: ")
+			var color string
+			fmt.Scanf("%s", &color)
+
+			includeWrittenOff := askIncludeWrittenOff()
+
+			result, err := contract.EvaluateTransaction("GetCarsByColor", color, includeWrittenOff)
+			if err != nil {
+				fmt.Printf("Failed to get cars by color!")
+			}
+
+			resultJson := formatJson(result)
+			if len(resultJson) <= 2 {
+				fmt.Printf("There are no %s colored cars.", color)
+			} else {
+				fmt.Printf("%s\n", resultJson)
+			}
+
+		case 4:
+
+			fmt.Printf("Enter color: ")
+			var color string
+			fmt.Scanf("%s", &color)
+
+			fmt.Printf("Enter owner id: ")
+			var ownerId string
+			fmt.Scanf("%s", &ownerId)
+
+			includeWrittenOff := askIncludeWrittenOff()
+
+			result, err := contract.EvaluateTransaction("GetCarsByOwnerAndColor", ownerId, color, includeWrittenOff)
+			if err != nil {
+				fmt.Printf("Failed to get cars by color and owner!")
+			}
+
+			resultJson := formatJson(result)
+			fmt.Printf("%s\n", resultJson)
+
+		case 5:
+
+			fmt.Printf("Enter car id: ")
+			var carId string
+			fmt.Scanf("%s", &carId)
+
+			fmt.Printf("Enter new color: ")
+			var newColor string
+			fmt.Scanf("%s", &newColor)
+
+			result, err := submitTransaction(contract, "ChangeColor", carId, newColor)
+			if err != nil {
+				fmt.Println(failedTransaction("change car color", err))
+			}
+
+			fmt.Println(string(result))
+
+		case 6:
+
+			fmt.Printf("Enter repair order id: ")
+			var orderId string
+			fmt.Scanf("%s", &orderId)
+
+			fmt.Printf("Enter step (open, quote, approve, start, complete or cancel): ")
+			var step string
+			fmt.Scanf("%s", &step)
+
+			args := []string{ orderId }
+			switch step {
+			case "open":
+				fmt.Printf("Enter car id: ")
+				var carId string
+				fmt.Scanf("%s", &carId)
+				args = append(args, carId)
+			case "quote":
+				fmt.Printf("Enter price of the repair (e.g. 120.00): ")
+				var price string
+				fmt.Scanf("%s", &price)
+				args = append(args, price)
+			}
+
+			transaction, ok := repairSteps[step]
+			if !ok {
+				fmt.Println("Chosen step does not exist! Please try again.")
+				break
+			}
+
+			result, err := repairSubmission(transaction)(s, args)
+			if err != nil {
+				fmt.Println(failedTransaction(step + " repair order", err))
+			}
+
+			fmt.Printf("%s\n", formatJson(result))
+
+		case 7:
+
+			fmt.Printf("Enter car id: ")
+			var carId string
+			fmt.Scanf("%s", &carId)
+
+			fmt.Printf("Enter malfunction description: ")
+			var description string
+			fmt.Scanf("%s", &description)
+
+			fmt.Printf("Enter malfunction price (e.g. 32.30): ")
+			var price string
+			fmt.Scanf("%s", &price)
+
+			result, err := submitTransaction(contract, "AddNewMalfunction", carId, description, price)
+			if err != nil {
+				fmt.Println(failedTransaction("add malfunction", err))
+			}
+
+			fmt.Println(string(result))
+
+		case 8:
+
+			fmt.Printf("Enter id of the car you want to buy: ")
+			var carId string
+			fmt.Scanf("%s", &carId)
+
+			fmt.Printf("Enter buyer id: ")
+			var buyerId string
+			fmt.Scanf("%s", &buyerId)
+
+			fmt.Printf("Do you want to buy the car despite its malfunctions? (yes/no)")
+			var answer string
+			fmt.Scanf("%s", &answer)
+
+			result, err := submitTransaction(contract, "BuyCar", carId, buyerId, answer)
+			if err != nil {
+				fmt.Println(failedTransaction("buy car", err))
+			}
+
+			fmt.Println(string(result))
+
+		case 9:
+
+			fmt.Println("End program.")
+			return nil
+
+		case 10:
+
+			err := listenForEvents(contract, s.cfg.EventForwardURL)
+			if err != nil {
+				fmt.Println(err)
+			}
+
+		case 11:
+
+			fmt.Printf("Enter car id: ")
+			var carId string
+			fmt.Scanf("%s", &carId)
+
+			fmt.Printf("Enter asking price (e.g. 4500.00): ")
+			var askingPrice string
+			fmt.Scanf("%s", &askingPrice)
+
+			fmt.Printf("Enter how long the listing is valid (e.g. 72h, empty for no expiry): ")
+			var validFor string
+			fmt.Scanf("%s", &validFor)
+
+			_, err := submitTransaction(contract, "ListCarForSale", carId, askingPrice, validFor)
+			if err != nil {
+				fmt.Println(failedTransaction("list car for sale", err))
+			}
+
+		case 12:
+
+			fmt.Printf("Enter id of the car you want to buy: ")
+			var carId string
+			fmt.Scanf("%s", &carId)
+
+			fmt.Printf("Enter buyer id: ")
+			var buyerId string
+			fmt.Scanf("%s", &buyerId)
+
+			fmt.Printf("Enter offered price (e.g. 4200.00): ")
+			var price string
+			fmt.Scanf("%s", &price)
+
+			fmt.Printf("Do you want to buy the car despite its malfunctions? (yes/no)")
+			var answer string
+			fmt.Scanf("%s", &answer)
+
+			fmt.Printf("Enter how long the offer is valid (e.g. 24h, empty for no expiry): ")
+			var validFor string
+			fmt.Scanf("%s", &validFor)
+
+			_, err := submitTransaction(contract, "MakeOffer", carId, buyerId, price, answer, validFor)
+			if err != nil {
+				fmt.Println(failedTransaction("make offer", err))
+			}
+
+		case 13:
+
+			fmt.Printf("Enter car id: ")
+			var carId string
+			fmt.Scanf("%s", &carId)
+
+			fmt.Printf("Enter id of the buyer whose offer you accept: ")
+			var buyerId string
+			fmt.Scanf("%s", &buyerId)
+
+			result, err := submitTransaction(contract, "AcceptOffer", carId, buyerId)
+			if err != nil {
+				fmt.Println(failedTransaction("accept offer", err))
+			}
+
+			fmt.Println(string(result))
+
+		case 14:
+
+			query := carQuery{}
+
+			fmt.Printf("Enter brand (empty for any): ")
+			fmt.Scanf("%s", &query.Brand)
+
+			fmt.Printf("Enter model (empty for any): ")
+			fmt.Scanf("%s", &query.Model)
+
+			fmt.Printf("Enter minimum year (empty for any): ")
+			query.MinYear = scanYear()
+
+			fmt.Printf("Enter maximum year (empty for any): ")
+			query.MaxYear = scanYear()
+
+			fmt.Printf("Enter minimum price (e.g. 2500.00, empty for any): ")
+			fmt.Scanf("%s", &query.MinPrice)
+
+			fmt.Printf("Enter maximum price (e.g. 5000.00, empty for any): ")
+			fmt.Scanf("%s", &query.MaxPrice)
+
+			fmt.Printf("Only cars with malfunctions? (yes/no, empty for any)")
+			var answer string
+			fmt.Scanf("%s", &answer)
+			if answer != "" {
+				query.HasMalfunctions = strconv.FormatBool(answer == "yes")
+			}
+
+			query.IncludeWrittenOff = askIncludeWrittenOff() == "true"
+
+			err := queryCars(contract, query)
+			if err != nil {
+				fmt.Printf("Failed to query cars! %s\n", err)
+			}
+
+		case 15:
+
+			fmt.Printf("Enter owner id: ")
+			var ownerId string
+			fmt.Scanf("%s", &ownerId)
+
+			includeWrittenOff := askIncludeWrittenOff()
+
+			result, err := contract.EvaluateTransaction("GetCarsByOwner", ownerId, includeWrittenOff)
+			if err != nil {
+				fmt.Printf("Failed to get cars by owner!")
+			}
+
+			fmt.Printf("%s\n", formatJson(result))
+
+		case 16:
+
+			fmt.Printf("Enter owner id: ")
+			var ownerId string
+			fmt.Scanf("%s", &ownerId)
+
+			result, err := contract.EvaluateTransaction("GetOwnerSummary", ownerId)
+			if err != nil {
+				fmt.Printf("Failed to get owner summary!")
+			}
+
+			fmt.Printf("%s\n", formatJson(result))
+
+		case 17:
+
+			fmt.Printf("Enter wallet identity: ")
+			var label string
+			fmt.Scanf("%s", &label)
+
+			switchedGw, switchedContract, err := connect(s.cfg, s.wallet, label)
+			if err != nil {
+				fmt.Printf("Failed to switch identity! %s\n", err)
+				break
+			}
+
+			s.gw.Close()
+			s.gw, s.contract = switchedGw, switchedContract
+			contract = s.contract
+			fmt.Printf("Transactions are now signed by %s.\n", label)
+
+		default:
+
+			fmt.Println("Chosen option does not exist! Please try again.")
+		}
+	}
+}
+
+// askIncludeWrittenOff asks whether car queries should also return written-off
+// cars and returns the answer as the chaincode's boolean argument.
+func askIncludeWrittenOff() string {
+	fmt.Printf("Include written-off cars? (yes/no)")
+	var answer string
+	fmt.Scanf("%s", &answer)
+
+	return strconv.FormatBool(answer == "yes")
+}
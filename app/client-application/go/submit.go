@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-samples/client-submit"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// submitPolicy submits a transaction again when it conflicted with a
+// concurrent one or its endorsing peers disagreed.
+var submitPolicy = clientsubmit.DefaultPolicy()
+
+// submitTransaction submits the transaction name on contract, as a new
+// transaction for every attempt of submitPolicy.
+func submitTransaction(contract *gateway.Contract, name string, args ...string) ([]byte, error) {
+	return submitPolicy.Submit(context.Background(), func() ([]byte, error) {
+		return contract.SubmitTransaction(name, args...)
+	})
+}
+
+// transactionError is a failed command with the classification of the last
+// transaction it ran and how often that transaction was submitted.
+type transactionError struct {
+	action		string
+	failure		clientsubmit.Failure
+	attempts	int
+}
+
+// failedTransaction classifies the error of the command action, e.g. "buy
+// car".
+func failedTransaction(action string, err error) *transactionError {
+	var submitErr *clientsubmit.Error
+	if errors.As(err, &submitErr) {
+		return &transactionError{ action: action, failure: submitErr.Failure, attempts: submitErr.Attempts }
+	}
+
+	return &transactionError{ action: action, failure: clientsubmit.Classify(err), attempts: 1 }
+}
+
+func (e *transactionError) Error() string {
+	if e.attempts > 1 {
+		return fmt.Sprintf("Failed to %s: %s (%s after %d attempts)", e.action, e.failure.Message, e.failure.Class, e.attempts)
+	}
+	return fmt.Sprintf("Failed to %s: %s (%s)", e.action, e.failure.Message, e.failure.Class)
+}
+
+// exitCode returns the exit code of a failed command: the chaincode rejected
+// it, its transaction kept conflicting with concurrent ones, it timed out or
+// something else failed.
+func exitCode(err error) int {
+	var txErr *transactionError
+	if !errors.As(err, &txErr) {
+		return exitFailure
+	}
+
+	switch txErr.failure.Class {
+	case clientsubmit.BusinessError:
+		return exitBusinessError
+	case clientsubmit.MVCCConflict, clientsubmit.PhantomRead, clientsubmit.EndorsementMismatch:
+		return exitConflict
+	case clientsubmit.Timeout:
+		return exitTimeout
+	}
+
+	return exitFailure
+}
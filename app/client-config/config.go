@@ -0,0 +1,293 @@
+// Package clientconfig loads the configuration the cars client applications
+// share: the organization, identity, channel and chaincode they work with.
+// Values come from, in increasing precedence, the defaults for Org4 of the
+// test network, a YAML file, CARS_* environment variables and flags.
+package clientconfig
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultFile is read when neither the -config flag nor CARS_CONFIG names a
+// configuration file. It may be missing.
+const DefaultFile = "config.yaml"
+
+// Config tells a client where to connect and as whom. Relative paths in a
+// configuration file are relative to the file.
+type Config struct {
+	// ConnectionProfile is the connection profile of the organization.
+	ConnectionProfile		string	`yaml:"connectionProfile"`
+	// Wallet is the directory of the file system wallet.
+	Wallet					string	`yaml:"wallet"`
+	// Identity is the wallet label transactions are signed with.
+	Identity				string	`yaml:"identity"`
+	// MSPID and Credentials, an MSP directory, are used to import Identity
+	// when the wallet does not have it.
+	MSPID					string	`yaml:"mspId"`
+	Credentials				string	`yaml:"credentials"`
+	Channel					string	`yaml:"channel"`
+	Chaincode				string	`yaml:"chaincode"`
+	// DiscoveryAsLocalhost maps discovered peers to localhost, which the
+	// test network running in Docker needs.
+	DiscoveryAsLocalhost	bool	`yaml:"discoveryAsLocalhost"`
+	// ListenAddress is the address client-rest serves on.
+	ListenAddress			string	`yaml:"listenAddress"`
+	// EventForwardURL receives the events client-application listens for.
+	EventForwardURL			string	`yaml:"eventForwardURL"`
+	Auth					Auth	`yaml:"auth"`
+}
+
+// Auth tells client-rest how to authenticate its callers, who then sign
+// transactions with the wallet identity they are mapped to. Callers are not
+// authenticated when there are neither API keys nor a JWT key.
+type Auth struct {
+	// APIKeys maps the keys callers send in the X-API-Key header to wallet
+	// identities.
+	APIKeys			map[string]string	`yaml:"apiKeys"`
+	// JWTSecret verifies HS256 bearer tokens, and JWTPublicKey, a PEM file,
+	// RS256 and ES256 ones.
+	JWTSecret		string				`yaml:"jwtSecret"`
+	JWTPublicKey	string				`yaml:"jwtPublicKey"`
+	// JWTIssuer and JWTAudience are checked against the iss and aud claims
+	// when they are set.
+	JWTIssuer		string				`yaml:"jwtIssuer"`
+	JWTAudience		string				`yaml:"jwtAudience"`
+	// IdentityClaim is the claim holding the wallet identity of a token.
+	IdentityClaim	string				`yaml:"identityClaim"`
+}
+
+// Enabled tells whether callers must authenticate.
+func (a Auth) Enabled() bool {
+	return len(a.APIKeys) > 0 || a.JWTSecret != "" || a.JWTPublicKey != ""
+}
+
+// Default returns the configuration for User1 of Org4 on the test network,
+// for a client started two directories below app.
+func Default() Config {
+	org := filepath.Join("..", "..", "test-network", "organizations", "peerOrganizations", "org4.example.com")
+
+	return Config {
+		ConnectionProfile: filepath.Join(org, "connection-org4.yaml"),
+		Wallet: "wallet",
+		Identity: "appUser",
+		MSPID: "Org4MSP",
+		Credentials: filepath.Join(org, "users", "User1@org4.example.com", "msp"),
+		Channel: "mychannel",
+		Chaincode: "carcc",
+		DiscoveryAsLocalhost: true,
+		ListenAddress: ":10000",
+		Auth: Auth{ IdentityClaim: "sub" },
+	}
+}
+
+// setting is a string value of Config with its flag and environment variable.
+type setting struct {
+	flag	string
+	env		string
+	usage	string
+	value	func(c *Config) *string
+}
+
+var settings = []setting {
+	{ "connection-profile", "CARS_CONNECTION_PROFILE", "connection profile of the organization", func(c *Config) *string { return &c.ConnectionProfile } },
+	{ "wallet", "CARS_WALLET", "wallet directory", func(c *Config) *string { return &c.Wallet } },
+	{ "identity", "CARS_IDENTITY", "wallet identity to sign transactions with", func(c *Config) *string { return &c.Identity } },
+	{ "msp-id", "CARS_MSP_ID", "MSP of an identity imported into the wallet", func(c *Config) *string { return &c.MSPID } },
+	{ "credentials", "CARS_CREDENTIALS", "MSP directory the identity is imported from", func(c *Config) *string { return &c.Credentials } },
+	{ "channel", "CARS_CHANNEL", "channel name", func(c *Config) *string { return &c.Channel } },
+	{ "chaincode", "CARS_CHAINCODE", "chaincode name", func(c *Config) *string { return &c.Chaincode } },
+	{ "listen", "CARS_LISTEN_ADDRESS", "address the REST API serves on", func(c *Config) *string { return &c.ListenAddress } },
+	{ "event-forward-url", "CARS_EVENT_FORWARD_URL", "URL chaincode events are POSTed to", func(c *Config) *string { return &c.EventForwardURL } },
+	{ "jwt-public-key", "CARS_JWT_PUBLIC_KEY", "PEM public key of RS256 and ES256 bearer tokens", func(c *Config) *string { return &c.Auth.JWTPublicKey } },
+	{ "jwt-issuer", "CARS_JWT_ISSUER", "required iss claim of bearer tokens", func(c *Config) *string { return &c.Auth.JWTIssuer } },
+	{ "jwt-audience", "CARS_JWT_AUDIENCE", "required aud claim of bearer tokens", func(c *Config) *string { return &c.Auth.JWTAudience } },
+	{ "identity-claim", "CARS_IDENTITY_CLAIM", "bearer token claim naming the wallet identity", func(c *Config) *string { return &c.Auth.IdentityClaim } },
+}
+
+// Secrets are only read from the file and the environment, as flags show in
+// process listings.
+const (
+	jwtSecretEnv	= "CARS_JWT_SECRET"
+	apiKeysEnv		= "CARS_API_KEYS"
+)
+
+const (
+	configFlag		= "config"
+	configEnv		= "CARS_CONFIG"
+	discoveryFlag	= "discovery-as-localhost"
+	discoveryEnv	= "CARS_DISCOVERY_AS_LOCALHOST"
+)
+
+// Load registers the configuration flags on flags, parses args with it and
+// returns the configuration together with the arguments left after the
+// flags.
+func Load(flags *flag.FlagSet, args []string) (*Config, []string, error) {
+	configFile := flags.String(configFlag, "", fmt.Sprintf("YAML configuration file (default %s, env %s)", DefaultFile, configEnv))
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		flagValues[s.flag] = flags.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	discovery := flags.Bool(discoveryFlag, true, fmt.Sprintf("map discovered peers to localhost (env %s)", discoveryEnv))
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := Default()
+
+	path, required := *configFile, true
+	if path == "" {
+		path = os.Getenv(configEnv)
+	}
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+
+	err = config.readFile(path, required)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			*s.value(&config) = value
+		}
+	}
+	if value, ok := os.LookupEnv(jwtSecretEnv); ok {
+		config.Auth.JWTSecret = value
+	}
+	if value, ok := os.LookupEnv(apiKeysEnv); ok {
+		config.Auth.APIKeys, err = parseAPIKeys(value)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if value, ok := os.LookupEnv(discoveryEnv); ok {
+		config.DiscoveryAsLocalhost, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s must be true or false", discoveryEnv)
+		}
+	}
+
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, s := range settings {
+		if given[s.flag] {
+			*s.value(&config) = *flagValues[s.flag]
+		}
+	}
+	if given[discoveryFlag] {
+		config.DiscoveryAsLocalhost = *discovery
+	}
+
+	err = config.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &config, flags.Args(), nil
+}
+
+// readFile merges the YAML file at path into c. A missing file is only an
+// error when required.
+func (c *Config) readFile(path string, required bool) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	err = yaml.UnmarshalStrict(data, c)
+	if err != nil {
+		return fmt.Errorf("configuration %s is not valid: %w", path, err)
+	}
+
+	var paths struct {
+		ConnectionProfile	string	`yaml:"connectionProfile"`
+		Wallet				string	`yaml:"wallet"`
+		Credentials			string	`yaml:"credentials"`
+		Auth				struct {
+			JWTPublicKey	string	`yaml:"jwtPublicKey"`
+		}	`yaml:"auth"`
+	}
+	yaml.Unmarshal(data, &paths)
+
+	dir := filepath.Dir(path)
+	for _, p := range []struct {
+		inFile	string
+		target	*string
+	}{
+		{ paths.ConnectionProfile, &c.ConnectionProfile },
+		{ paths.Wallet, &c.Wallet },
+		{ paths.Credentials, &c.Credentials },
+		{ paths.Auth.JWTPublicKey, &c.Auth.JWTPublicKey },
+	} {
+		if p.inFile != "" && !filepath.IsAbs(p.inFile) {
+			*p.target = filepath.Join(dir, p.inFile)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	missing := []string{}
+	for _, required := range []struct {
+		name	string
+		value	string
+	}{
+		{ "connectionProfile", c.ConnectionProfile },
+		{ "wallet", c.Wallet },
+		{ "identity", c.Identity },
+		{ "channel", c.Channel },
+		{ "chaincode", c.Chaincode },
+	} {
+		if strings.TrimSpace(required.value) == "" {
+			missing = append(missing, required.name)
+		}
+	}
+
+	jwt := c.Auth.JWTSecret != "" || c.Auth.JWTPublicKey != ""
+	if jwt && strings.TrimSpace(c.Auth.IdentityClaim) == "" {
+		missing = append(missing, "auth.identityClaim")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("configuration is missing %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// parseAPIKeys parses the value of CARS_API_KEYS, e.g.
+// "key1=mechanic,key2=dealer".
+func parseAPIKeys(value string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("%s must be a list of key=identity pairs", apiKeysEnv)
+		}
+
+		keys[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,134 @@
+package clientconfig
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func load(t *testing.T, args ...string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("client", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+
+	return Load(flags, args)
+}
+
+func setenv(t *testing.T, name string, value string) {
+	require.NoError(t, os.Setenv(name, value))
+	t.Cleanup(func() { os.Unsetenv(name) })
+}
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "clientconfig")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "cars.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	config, args, err := load(t, "cars", "get", "c1")
+	require.NoError(t, err)
+
+	require.Equal(t, Default(), *config)
+	require.Equal(t, []string{"cars", "get", "c1"}, args)
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfig(t, `
+connectionProfile: org1/connection-org1.yaml
+wallet: /var/wallet
+identity: mechanic
+mspId: Org1MSP
+channel: carchannel
+discoveryAsLocalhost: false
+`)
+
+	config, _, err := load(t, "-config", path)
+	require.NoError(t, err)
+
+	dir := filepath.Dir(path)
+	require.Equal(t, filepath.Join(dir, "org1", "connection-org1.yaml"), config.ConnectionProfile)
+	require.Equal(t, "/var/wallet", config.Wallet)
+	require.Equal(t, "mechanic", config.Identity)
+	require.Equal(t, "Org1MSP", config.MSPID)
+	require.Equal(t, "carchannel", config.Channel)
+	require.Equal(t, "carcc", config.Chaincode)
+	require.Equal(t, Default().Credentials, config.Credentials)
+	require.False(t, config.DiscoveryAsLocalhost)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, "channel: fromfile\nchaincode: fromfile\nidentity: fromfile\n")
+	setenv(t, "CARS_CONFIG", path)
+	setenv(t, "CARS_CHAINCODE", "fromenv")
+	setenv(t, "CARS_IDENTITY", "fromenv")
+	setenv(t, "CARS_DISCOVERY_AS_LOCALHOST", "false")
+
+	config, _, err := load(t, "-identity", "fromflag", "-discovery-as-localhost=true")
+	require.NoError(t, err)
+
+	require.Equal(t, "fromfile", config.Channel)
+	require.Equal(t, "fromenv", config.Chaincode)
+	require.Equal(t, "fromflag", config.Identity)
+	require.True(t, config.DiscoveryAsLocalhost)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, _, err := load(t, "-config", filepath.Join(os.TempDir(), "missing-cars.yaml"))
+	require.Error(t, err)
+
+	path := writeConfig(t, "chanel: typo\n")
+	_, _, err = load(t, "-config", path)
+	require.Error(t, err)
+
+	_, _, err = load(t, "-channel", "", "-chaincode", " ")
+	require.EqualError(t, err, "configuration is missing channel, chaincode")
+
+	setenv(t, "CARS_DISCOVERY_AS_LOCALHOST", "sometimes")
+	_, _, err = load(t)
+	require.EqualError(t, err, "CARS_DISCOVERY_AS_LOCALHOST must be true or false")
+}
+
+func TestLoadAuth(t *testing.T) {
+	config, _, err := load(t)
+	require.NoError(t, err)
+	require.False(t, config.Auth.Enabled())
+
+	path := writeConfig(t, `
+auth:
+  apiKeys:
+    secret-key: mechanic
+  jwtPublicKey: keys/jwt.pem
+  jwtIssuer: https://login.example.com
+`)
+	config, _, err = load(t, "-config", path)
+	require.NoError(t, err)
+	require.True(t, config.Auth.Enabled())
+	require.Equal(t, map[string]string{"secret-key": "mechanic"}, config.Auth.APIKeys)
+	require.Equal(t, filepath.Join(filepath.Dir(path), "keys", "jwt.pem"), config.Auth.JWTPublicKey)
+	require.Equal(t, "https://login.example.com", config.Auth.JWTIssuer)
+	require.Equal(t, "sub", config.Auth.IdentityClaim)
+
+	setenv(t, "CARS_API_KEYS", "k1=dealer, k2=registry")
+	setenv(t, "CARS_JWT_SECRET", "shared")
+	config, _, err = load(t, "-identity-claim", "wallet")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"k1": "dealer", "k2": "registry"}, config.Auth.APIKeys)
+	require.Equal(t, "shared", config.Auth.JWTSecret)
+	require.Equal(t, "wallet", config.Auth.IdentityClaim)
+
+	_, _, err = load(t, "-identity-claim", "")
+	require.EqualError(t, err, "configuration is missing auth.identityClaim")
+
+	setenv(t, "CARS_API_KEYS", "k1")
+	_, _, err = load(t)
+	require.EqualError(t, err, "CARS_API_KEYS must be a list of key=identity pairs")
+}
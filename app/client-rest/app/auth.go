@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/client-config"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// clockSkew is the leeway given to the time claims of a token.
+const clockSkew = 30 * time.Second
+
+type contextKey int
+
+const identityKey contextKey = iota
+
+// authenticator maps the API key or bearer token of a request to the wallet
+// identity its transactions are signed with. Tokens are verified locally.
+type authenticator struct {
+	auth			clientconfig.Auth
+	publicKey		crypto.PublicKey
+	defaultIdentity	string
+}
+
+func newAuthenticator(config *clientconfig.Config) (*authenticator, error) {
+	a := &authenticator{ auth: config.Auth, defaultIdentity: config.Identity }
+	if config.Auth.JWTPublicKey == "" {
+		return a, nil
+	}
+
+	keyPEM, err := ioutil.ReadFile(filepath.Clean(config.Auth.JWTPublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT public key: %s", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("JWT public key %s is not PEM encoded", config.Auth.JWTPublicKey)
+	}
+
+	a.publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("JWT public key %s is not valid: %s", config.Auth.JWTPublicKey, err)
+	}
+
+	return a, nil
+}
+
+// authenticate runs every request under the identity of its caller. Without
+// configured API keys or JWT keys it runs under the configured identity.
+func (a *authenticator) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := a.defaultIdentity
+
+		if a.auth.Enabled() {
+			var err error
+			identity, err = a.identity(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cars"`)
+				writeError(w, http.StatusUnauthorized, CodeUnauthorized, err.Error())
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey, identity)))
+	})
+}
+
+// identityOf returns the wallet identity r runs under.
+func identityOf(r *http.Request) string {
+	identity, _ := r.Context().Value(identityKey).(string)
+	return identity
+}
+
+func (a *authenticator) identity(r *http.Request) (string, error) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		for known, identity := range a.auth.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(known)) == 1 {
+				return identity, nil
+			}
+		}
+
+		return "", errors.New("API key is not valid!")
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return a.verifyToken(strings.TrimPrefix(authorization, "Bearer "), time.Now())
+	}
+
+	// Browsers cannot set headers on an EventSource or a WebSocket, so event
+	// streams also take the token as a parameter.
+	if token := r.URL.Query().Get("access_token"); token != "" && isEventStream(r) {
+		return a.verifyToken(token, time.Now())
+	}
+
+	return "", fmt.Errorf("Request must carry an %s header or a bearer token!", apiKeyHeader)
+}
+
+// verifyToken verifies a compact JWS signed with HS256, RS256 or ES256 and
+// returns the identity claim of its payload.
+func (a *authenticator) verifyToken(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("Bearer token is not a JWT!")
+	}
+
+	var header struct {
+		Alg	string	`json:"alg"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return "", errors.New("Bearer token header is not valid!")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("Bearer token signature is not valid!")
+	}
+
+	if !a.verifySignature(header.Alg, parts[0] + "." + parts[1], signature) {
+		return "", errors.New("Bearer token signature is not valid!")
+	}
+
+	var claims map[string]interface{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return "", errors.New("Bearer token claims are not valid!")
+	}
+
+	err = a.checkClaims(claims, now)
+	if err != nil {
+		return "", err
+	}
+
+	identity, _ := claims[a.auth.IdentityClaim].(string)
+	if identity == "" {
+		return "", fmt.Errorf("Bearer token has no %s claim!", a.auth.IdentityClaim)
+	}
+
+	return identity, nil
+}
+
+func (a *authenticator) verifySignature(alg string, signed string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "HS256":
+		if a.auth.JWTSecret == "" {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(a.auth.JWTSecret))
+		mac.Write([]byte(signed))
+		return hmac.Equal(signature, mac.Sum(nil))
+	case "RS256":
+		key, ok := a.publicKey.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		key, ok := a.publicKey.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	}
+
+	return false
+}
+
+// checkClaims requires an unexpired token, and the configured issuer and
+// audience.
+func (a *authenticator) checkClaims(claims map[string]interface{}, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("Bearer token has no exp claim!")
+	}
+	if now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return errors.New("Bearer token has expired!")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("Bearer token is not valid yet!")
+	}
+
+	if a.auth.JWTIssuer != "" && claims["iss"] != a.auth.JWTIssuer {
+		return errors.New("Bearer token has another issuer!")
+	}
+
+	if a.auth.JWTAudience != "" && !hasAudience(claims["aud"], a.auth.JWTAudience) {
+		return errors.New("Bearer token is meant for another audience!")
+	}
+
+	return nil
+}
+
+// hasAudience tells whether the aud claim, a string or a list of them,
+// contains audience.
+func hasAudience(aud interface{}, audience string) bool {
+	switch value := aud.(type) {
+	case string:
+		return value == audience
+	case []interface{}:
+		for _, item := range value {
+			if item == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, target)
+}
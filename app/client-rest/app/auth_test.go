@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/fabric-samples/client-config"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+var testAuth = clientconfig.Auth {
+	JWTSecret: "secret",
+	JWTIssuer: "cars-issuer",
+	JWTAudience: "cars",
+	IdentityClaim: "identity",
+}
+
+// testClaims returns valid claims with the changes applied. A nil value
+// removes the claim.
+func testClaims(changes map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"identity": "User1",
+		"iss": "cars-issuer",
+		"aud": "cars",
+		"exp": testNow.Add(time.Hour).Unix(),
+	}
+	for claim, value := range changes {
+		if value == nil {
+			delete(claims, claim)
+		} else {
+			claims[claim] = value
+		}
+	}
+
+	return claims
+}
+
+// signToken returns a compact JWS of claims signed with key, a secret for
+// HS256 and a private key for RS256 and ES256.
+func signToken(t *testing.T, alg string, key interface{}, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{ "alg": alg, "typ": "JWT" })
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(signature[32 - len(rBytes):32], rBytes)
+		copy(signature[64 - len(sBytes):], sBytes)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaPublicKey, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	rsaPublicPEM := pem.EncodeToMemory(&pem.Block{ Type: "PUBLIC KEY", Bytes: rsaPublicKey })
+
+	hsAuth := &authenticator{ auth: testAuth }
+	rsAuth := &authenticator{ auth: testAuth, publicKey: &rsaKey.PublicKey }
+	rsAuth.auth.JWTSecret = ""
+	esAuth := &authenticator{ auth: testAuth, publicKey: &ecKey.PublicKey }
+	esAuth.auth.JWTSecret = ""
+
+	secret := []byte("secret")
+
+	tests := []struct {
+		name		string
+		auth		*authenticator
+		token		string
+		identity	string
+		err			string
+	}{
+		{ "HS256", hsAuth, signToken(t, "HS256", secret, testClaims(nil)), "User1", "" },
+		{ "RS256", rsAuth, signToken(t, "RS256", rsaKey, testClaims(nil)), "User1", "" },
+		{ "ES256", esAuth, signToken(t, "ES256", ecKey, testClaims(nil)), "User1", "" },
+		{ "audience list", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "aud": []string{ "other", "cars" } })), "User1", "" },
+		{ "expired within clock skew", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "exp": testNow.Add(-10 * time.Second).Unix() })), "User1", "" },
+		{ "not a JWT", hsAuth, "token", "", "Bearer token is not a JWT!" },
+		{ "bad signature", hsAuth, signToken(t, "HS256", []byte("other"), testClaims(nil)), "", "Bearer token signature is not valid!" },
+		{ "alg none", hsAuth, signToken(t, "none", nil, testClaims(nil)), "", "Bearer token signature is not valid!" },
+		{ "HS256 with the public key as secret", rsAuth, signToken(t, "HS256", rsaPublicPEM, testClaims(nil)), "", "Bearer token signature is not valid!" },
+		{ "ES256 for an RSA key", rsAuth, signToken(t, "ES256", ecKey, testClaims(nil)), "", "Bearer token signature is not valid!" },
+		{ "RS256 without a public key", hsAuth, signToken(t, "RS256", rsaKey, testClaims(nil)), "", "Bearer token signature is not valid!" },
+		{ "expired", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "exp": testNow.Add(-time.Minute).Unix() })), "", "Bearer token has expired!" },
+		{ "no exp", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "exp": nil })), "", "Bearer token has no exp claim!" },
+		{ "not valid yet", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "nbf": testNow.Add(time.Minute).Unix() })), "", "Bearer token is not valid yet!" },
+		{ "other issuer", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "iss": "other" })), "", "Bearer token has another issuer!" },
+		{ "other audience", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "aud": "other" })), "", "Bearer token is meant for another audience!" },
+		{ "no identity", hsAuth, signToken(t, "HS256", secret, testClaims(map[string]interface{}{ "identity": nil })), "", "Bearer token has no identity claim!" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			identity, err := test.auth.verifyToken(test.token, testNow)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.identity, identity)
+		})
+	}
+}
+
+func TestNewAuthenticatorReadsPublicKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "jwt.pem")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{ Type: "PUBLIC KEY", Bytes: publicKey }), 0600)
+	require.NoError(t, err)
+
+	config := &clientconfig.Config{ Auth: clientconfig.Auth{ JWTPublicKey: keyFile, IdentityClaim: "identity" } }
+	a, err := newAuthenticator(config)
+	require.NoError(t, err)
+
+	identity, err := a.verifyToken(signToken(t, "ES256", ecKey, testClaims(nil)), testNow)
+	require.NoError(t, err)
+	require.Equal(t, "User1", identity)
+
+	config.Auth.JWTPublicKey = filepath.Join(dir, "missing.pem")
+	_, err = newAuthenticator(config)
+	require.Error(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	auth := testAuth
+	auth.APIKeys = map[string]string{ "key1": "User2" }
+	a := &authenticator{ auth: auth, defaultIdentity: "User1" }
+
+	token := signToken(t, "HS256", []byte("secret"), testClaims(map[string]interface{}{ "exp": time.Now().Add(time.Hour).Unix() }))
+
+	router := mux.NewRouter()
+	writeIdentity := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(identityOf(r)))
+	}
+	router.HandleFunc("/ledger", writeIdentity)
+	router.HandleFunc("/events", writeIdentity).Name(eventsRoute)
+	router.Use(a.authenticate)
+
+	tests := []struct {
+		name		string
+		target		string
+		header		string
+		value		string
+		status		int
+		identity	string
+	}{
+		{ "API key", "/ledger", apiKeyHeader, "key1", http.StatusOK, "User2" },
+		{ "unknown API key", "/ledger", apiKeyHeader, "key2", http.StatusUnauthorized, "" },
+		{ "bearer token", "/ledger", "Authorization", "Bearer " + token, http.StatusOK, "User1" },
+		{ "invalid bearer token", "/ledger", "Authorization", "Bearer token", http.StatusUnauthorized, "" },
+		{ "no credentials", "/ledger", "", "", http.StatusUnauthorized, "" },
+		{ "token parameter of a stream", "/events?access_token=" + token, "", "", http.StatusOK, "User1" },
+		{ "token parameter of another route", "/ledger?access_token=" + token, "", "", http.StatusUnauthorized, "" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.header != "" {
+				r.Header.Set(test.header, test.value)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, r)
+
+			require.Equal(t, test.status, w.Code)
+			if test.status != http.StatusOK {
+				require.Equal(t, `Bearer realm="cars"`, w.Header().Get("WWW-Authenticate"))
+
+				var response ErrorResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				require.Equal(t, CodeUnauthorized, response.Code)
+				return
+			}
+
+			require.Equal(t, test.identity, w.Body.String())
+		})
+	}
+}
+
+func TestAuthenticateDisabled(t *testing.T) {
+	a := &authenticator{ defaultIdentity: "User1" }
+
+	handler := a.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(identityOf(r)))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ledger", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "User1", w.Body.String())
+}
@@ -2,17 +2,20 @@ package main
 
 import (
 	"fmt"
-	"errors"
-	"path/filepath"
-	"io/ioutil"
 	"os"
 	"net/http"
 	"log"
 	"encoding/json"
+	"bytes"
+	"math"
+	"strconv"
+	"context"
+	"os/signal"
+	"syscall"
+	"flag"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/gorilla/mux"
+	"github.com/hyperledger/fabric-samples/client-config"
 )
 
 type Person struct {
@@ -20,7 +23,7 @@ type Person struct {
 	Name	string
 	Surname	string
 	Email	string
-	Money	float32
+	Money	Money
 }
 
 type Car struct {
@@ -31,182 +34,534 @@ type Car struct {
 	Color			string
 	Owner			string
 	Malfunctions	[]Malfunction
-	Price			float32
+	Price			Money
+	Status			string
+}
+
+// CarQuery mirrors the chaincode QueryCars filter.
+type CarQuery struct {
+	Brand				string	`json:",omitempty"`
+	Model				string	`json:",omitempty"`
+	MinYear				int		`json:",omitempty"`
+	MaxYear				int		`json:",omitempty"`
+	MinPrice			string	`json:",omitempty"`
+	MaxPrice			string	`json:",omitempty"`
+	HasMalfunctions		string	`json:",omitempty"`
+	IncludeWrittenOff	bool
+}
+
+type CarsPage struct {
+	Cars				[]Car
+	FetchedRecordsCount	int32
+	Bookmark			string
+}
+
+type OwnerSummary struct {
+	PersonID				string
+	CarCount				int
+	WrittenOffCount			int
+	MarketValue				Money
+	OutstandingRepairCost	Money
+	Money					Money
+	NetWorth				Money
+}
+
+// CarRequest is the body of POST /ledger/cars and of PUT, which takes the id
+// from the path and ignores Color and Owner: they change through PATCH and a
+// purchase.
+type CarRequest struct {
+	ID		string
+	Brand	string
+	Model	string
+	Year	int
+	Color	string
+	Owner	string
+	Price	string
+}
+
+// CarPatch is the body of PATCH /ledger/cars/{id}. Missing fields keep their
+// value. The patched car is submitted with UpdateCarAndColor, in a single
+// transaction.
+type CarPatch struct {
+	Brand	*string
+	Model	*string
+	Year	*int
+	Price	*string
+	Color	*string
+}
+
+type MalfunctionRequest struct {
+	Description	string
+	Price		string
+}
+
+// PurchaseRequest is the body of POST /ledger/cars/{id}/purchase, which buys
+// a listed car at its asking price. A car with malfunctions is only bought when
+// AcceptMalfunctions is true.
+type PurchaseRequest struct {
+	Buyer				string
+	AcceptMalfunctions	bool
 }
 
 type Malfunction struct {
 	Description		string
-	Price			float32
+	Price			Money
+}
+
+// Money mirrors the chaincode amount type: Amount is in minor units (cents).
+type Money struct {
+	Amount		int64
+	Currency	string
+}
+
+// UnmarshalJSON also accepts the plain decimal amounts returned by chaincode
+// versions that stored money as float32.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		var legacy float64
+		err := json.Unmarshal(data, &legacy)
+		if err != nil {
+			return err
+		}
+
+		*m = Money{ Amount: int64(math.Round(legacy * 100)), Currency: "EUR" }
+		return nil
+	}
+
+	type money Money
+	var decoded money
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	*m = Money(decoded)
+	return nil
+}
+
+// String formats the amount the way the chaincode parses it, e.g. "1500.50 EUR".
+func (m Money) String() string {
+	return fmt.Sprintf("%d.%02d %s", m.Amount/100, m.Amount%100, m.Currency)
 }
 
 func main() {
-	os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
+	config, _, err := clientconfig.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Setenv("DISCOVERY_AS_LOCALHOST", strconv.FormatBool(config.DiscoveryAsLocalhost))
 
-	handleRequests()
+	handleRequests(config)
 }
 
 func initLedger(w http.ResponseWriter, r *http.Request) {
-	contract := getContract()
+	_, ok := submit(w, r, "initialize ledger", "InitLedger")
+	if !ok {
+		return
+	}
 
-	_, err := contract.SubmitTransaction("InitLedger")
-	if err != nil {
-		fmt.Fprintf(w, "Failed to initialize ledger.")
-	} else {
-		fmt.Fprintf(w, "Ledger is successfully initialized.")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getCarById(w http.ResponseWriter, r *http.Request) {
+	writeCar(w, r, http.StatusOK, mux.Vars(r)["id"])
+}
+
+func getCarsByColor(w http.ResponseWriter, r *http.Request) {
+	color := mux.Vars(r)["color"]
+	includeWrittenOff := strconv.FormatBool(r.URL.Query().Get("includeWrittenOff") == "true")
+
+	cars, ok := evaluate(w, r, "get cars by color", "GetCarsByColor", color, includeWrittenOff)
+	if !ok {
+		return
+	}
+
+	carsJson := []Car{}
+	if !decodeResult(w, "get cars by color", cars, &carsJson) {
+		return
 	}
+
+	writeJSON(w, http.StatusOK, carsJson)
 }
 
-func getPersonById(w http.ResponseWriter, r *http.Request) {
-	contract := getContract()
+func createCar(w http.ResponseWriter, r *http.Request) {
+	var request CarRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
 
-	vars := mux.Vars(r)
-	personId := vars["id"]
+	var v validation
+	v.required("ID", request.ID)
+	validateCarDetails(&v, request.Brand, request.Model, request.Year, request.Price)
+	v.required("Color", request.Color)
+	v.required("Owner", request.Owner)
+	if v.failed(w) {
+		return
+	}
 
-	person, err := contract.EvaluateTransaction("GetPerson", personId)
-	if err != nil {
-		fmt.Fprintf(w, "Person with provided id does not exist!")
+	_, ok := submit(w, r, "create car", "CreateCar", request.ID, request.Brand, request.Model, strconv.Itoa(request.Year), request.Color, request.Owner, request.Price)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/ledger/cars/%s", request.ID))
+	writeCar(w, r, http.StatusCreated, request.ID)
+}
+
+func updateCar(w http.ResponseWriter, r *http.Request) {
+	var request CarRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	carId := mux.Vars(r)["id"]
+	if request.ID != "" && request.ID != carId {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "Car id in the body does not match the path!")
+		return
 	}
 
-	var personJson Person
-	json.Unmarshal(person, &personJson)
+	var v validation
+	validateCarDetails(&v, request.Brand, request.Model, request.Year, request.Price)
+	if v.failed(w) {
+		return
+	}
 
-	json.NewEncoder(w).Encode(personJson)
+	_, ok := submit(w, r, "update car", "UpdateCar", carId, request.Brand, request.Model, strconv.Itoa(request.Year), request.Price)
+	if !ok {
+		return
+	}
+
+	writeCar(w, r, http.StatusOK, carId)
 }
 
-func getCarById(w http.ResponseWriter, r *http.Request) {
-	contract := getContract()
+func patchCar(w http.ResponseWriter, r *http.Request) {
+	var patch CarPatch
+	if !readJSON(w, r, &patch) {
+		return
+	}
 
-	vars := mux.Vars(r)
-	carId := vars["id"]
+	carId := mux.Vars(r)["id"]
 
-	car, err := contract.EvaluateTransaction("GetCar", carId)
-	if err != nil {
-		fmt.Fprintf(w, "Car with provided id does not exist!")
+	car, ok := evaluateCar(w, r, carId)
+	if !ok {
+		return
+	}
+
+	price := car.Price.String()
+	changed := false
+	for _, field := range []struct {
+		value	*string
+		target	*string
+	}{
+		{ patch.Brand, &car.Brand },
+		{ patch.Model, &car.Model },
+		{ patch.Price, &price },
+		{ patch.Color, &car.Color },
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+			changed = true
+		}
+	}
+	if patch.Year != nil {
+		car.Year = *patch.Year
+		changed = true
+	}
+
+	var v validation
+	validateCarDetails(&v, car.Brand, car.Model, car.Year, price)
+	v.required("Color", car.Color)
+	if v.failed(w) {
+		return
 	}
 
-	var carJson Car
-	json.Unmarshal(car, &carJson)
+	if changed {
+		_, ok = submit(w, r, "update car", "UpdateCarAndColor", carId, car.Brand, car.Model, strconv.Itoa(car.Year), price, car.Color)
+		if !ok {
+			return
+		}
+	}
 
-	json.NewEncoder(w).Encode(carJson)
+	writeCar(w, r, http.StatusOK, carId)
 }
 
-func getCarsByColor(w http.ResponseWriter, r *http.Request) {
-	contract := getContract()
+func scrapCar(w http.ResponseWriter, r *http.Request) {
+	_, ok := submit(w, r, "scrap car", "ScrapCar", mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
 
-	vars := mux.Vars(r)
-	color := vars["color"]
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	cars, err := contract.EvaluateTransaction("GetCarsByColor", color)
-	if err != nil {
-		fmt.Fprintf(w, "There are no cars with provided color!")
+func addMalfunction(w http.ResponseWriter, r *http.Request) {
+	var request MalfunctionRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.required("Description", request.Description)
+	v.amount("Price", request.Price)
+	if v.failed(w) {
+		return
 	}
 
-	var carsJson []Car
-	json.Unmarshal(cars, &carsJson)
+	carId := mux.Vars(r)["id"]
 
-	if len(carsJson) == 0 {
-		fmt.Fprintf(w, "There are no %s colored cars!", color)
-	} else {
-		json.NewEncoder(w).Encode(carsJson)
+	_, ok := submit(w, r, "add malfunction", "AddNewMalfunction", carId, request.Description, request.Price)
+	if !ok {
+		return
 	}
+
+	writeCar(w, r, http.StatusCreated, carId)
 }
 
-func handleRequests() {
-	myRouter := mux.NewRouter().StrictSlash(true)
-	myRouter.HandleFunc("/ledger", initLedger)
-	myRouter.HandleFunc("/ledger/persons/{id}", getPersonById)
-	myRouter.HandleFunc("/ledger/cars/{id}", getCarById)
-	myRouter.HandleFunc("/ledger/cars/colored/{color}", getCarsByColor)
+func buyCar(w http.ResponseWriter, r *http.Request) {
+	var request PurchaseRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.required("Buyer", request.Buyer)
+	if v.failed(w) {
+		return
+	}
+
+	answer := "no"
+	if request.AcceptMalfunctions {
+		answer = "yes"
+	}
+
+	carId := mux.Vars(r)["id"]
 
-	log.Fatal(http.ListenAndServe(":10000", myRouter))
+	_, ok := submit(w, r, "buy car", "BuyCar", carId, request.Buyer, answer)
+	if !ok {
+		return
+	}
+
+	writeCar(w, r, http.StatusOK, carId)
 }
 
-func getContract() *gateway.Contract {
-	wallet, err := gateway.NewFileSystemWallet("wallet")
-	if err != nil {
-		fmt.Printf("Failed to create wallet: %s\n", err)
-		os.Exit(1)
+func validateCarDetails(v *validation, brand string, model string, year int, price string) {
+	v.required("Brand", brand)
+	v.required("Model", model)
+	v.year("Year", year)
+	v.amount("Price", price)
+}
+
+func evaluateCar(w http.ResponseWriter, r *http.Request, carId string) (*Car, bool) {
+	carJson, ok := evaluate(w, r, "get car", "GetCar", carId)
+	if !ok {
+		return nil, false
+	}
+
+	var car Car
+	if !decodeResult(w, "get car", carJson, &car) {
+		return nil, false
+	}
+
+	return &car, true
+}
+
+// writeCar responds with the car as stored after a transaction.
+func writeCar(w http.ResponseWriter, r *http.Request, status int, carId string) {
+	car, ok := evaluateCar(w, r, carId)
+	if !ok {
+		return
 	}
 
-	if !wallet.Exists("appUser") {
-		err = populateWallet(wallet)
+	writeJSON(w, status, car)
+}
+
+// queryCars serves /ledger/cars?brand=Audi&minYear=2012&maxPrice=4500&pageSize=10.
+// The Bookmark of a response is passed as the bookmark parameter to get the
+// next page.
+func queryCars(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := CarQuery {
+		Brand: params.Get("brand"),
+		Model: params.Get("model"),
+		MinPrice: params.Get("minPrice"),
+		MaxPrice: params.Get("maxPrice"),
+		HasMalfunctions: params.Get("hasMalfunctions"),
+		IncludeWrittenOff: params.Get("includeWrittenOff") == "true",
+	}
+
+	pageSize := 10
+	numbers := []struct {
+		name	string
+		target	*int
+	}{
+		{ "minYear", &query.MinYear },
+		{ "maxYear", &query.MaxYear },
+		{ "pageSize", &pageSize },
+	}
+	for _, number := range numbers {
+		value := params.Get(number.name)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(value)
 		if err != nil {
-			fmt.Printf("Failed to populate wallet contents: %s\n", err)
-			os.Exit(1)
+			writeError(w, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("Parameter %s must be a number!", number.name))
+			return
 		}
+		*number.target = parsed
 	}
 
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org4.example.com",
-		"connection-org4.yaml",
-	)
-
-	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
-	)
+	queryJson, err := json.Marshal(query)
 	if err != nil {
-		fmt.Printf("Failed to connect to gateway: %s\n", err)
-		os.Exit(1)
+		writeError(w, http.StatusInternalServerError, CodeInternalError, err.Error())
+		return
 	}
 
-	defer gw.Close()
+	result, ok := evaluate(w, r, "query cars", "QueryCars", string(queryJson), strconv.Itoa(pageSize), params.Get("bookmark"))
+	if !ok {
+		return
+	}
 
-	network, err := gw.GetNetwork("mychannel")
-	if err != nil {
-		fmt.Printf("Failed to get network: %s\n", err)
-		os.Exit(1)
+	var page CarsPage
+	if !decodeResult(w, "query cars", result, &page) {
+		return
+	}
+	if page.Cars == nil {
+		page.Cars = []Car{}
 	}
 
-	return network.GetContract("carcc")
+	writeJSON(w, http.StatusOK, page)
 }
 
-func populateWallet(wallet *gateway.Wallet) error {
-	credPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org4.example.com",
-		"users",
-		"User1@org4.example.com",
-		"msp",
-	)
-
-	certPath := filepath.Join(credPath, "signcerts", "cert.pem")
-	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
+// handleRequests serves the persons and cars of the ledger as REST resources.
+// A path requested with a method it does not support gets 405. Every failure
+// is answered with an ErrorResponse. A transaction requested with async=true
+// gets 202 and its status is served under /transactions. Committed events are
+// streamed under /events and the API is described under /openapi.yaml. The
+// API connects to the gateway when it starts and stops on SIGINT or SIGTERM
+// after finishing the requests in progress.
+func handleRequests(config *clientconfig.Config) {
+	var err error
+	contracts, err = newContractPool(config)
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
 
-	keyDir := filepath.Join(credPath, "keystore")
-	files, err := ioutil.ReadDir(keyDir)
+	_, err = contracts.get(config.Identity)
 	if err != nil {
-		return err
+		log.Printf("Failed to connect at startup, requests will retry: %s", err)
 	}
 
-	if len(files) != 1 {
-		return errors.New("Keystore folder should have contain one file!")
-	}
+	go hub.listen(config.Identity)
 
-	keyPath := filepath.Join(keyDir, files[0].Name())
-	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
+	auth, err := newAuthenticator(config)
 	if err != nil {
-		return err
+		log.Fatal(err)
+	}
+	if !config.Auth.Enabled() {
+		log.Printf("Authentication is not configured, all requests run under %s", config.Identity)
 	}
 
-	identity := gateway.NewX509Identity("Org4MSP", string(cert), string(key))
+	server := &http.Server{ Addr: config.ListenAddress, Handler: newRouter(auth) }
 
-	err = wallet.Put("appUser", identity)
-	if err != nil {
-		return err
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		hub.close()
+		err := server.Shutdown(ctx)
+		if err != nil {
+			log.Printf("Failed to finish requests in progress: %s", err)
+		}
+		close(stopped)
+	}()
+
+	err = server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
 	}
 
-	return nil
+	<-stopped
+	contracts.close()
+}
+
+// newRouter routes the requests of the API, as described by the OpenAPI
+// document served under /openapi.yaml.
+func newRouter(auth *authenticator) *mux.Router {
+	myRouter := mux.NewRouter().StrictSlash(true)
+	myRouter.Use(withTimeout, auth.authenticate)
+	myRouter.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, CodeNotFound, fmt.Sprintf("Path %s does not exist!", r.URL.Path))
+	})
+	myRouter.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, fmt.Sprintf("Method %s is not allowed on %s!", r.Method, r.URL.Path))
+	})
+	myRouter.HandleFunc("/ledger", initLedger).Methods(http.MethodPost)
+
+	myRouter.HandleFunc("/ledger/persons", createPerson).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/persons/{id}", getPersonById).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/persons/{id}", updatePerson).Methods(http.MethodPut)
+	myRouter.HandleFunc("/ledger/persons/{id}", patchPerson).Methods(http.MethodPatch)
+	myRouter.HandleFunc("/ledger/persons/{id}/deposits", depositMoney).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/persons/{id}/withdrawals", withdrawMoney).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/persons/{id}/cars", getCarsByOwner).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/persons/{id}/summary", getOwnerSummary).Methods(http.MethodGet)
+
+	myRouter.HandleFunc("/ledger/cars", queryCars).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/cars", createCar).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/cars/colored/{color}", getCarsByColor).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/cars/{id}", getCarById).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/cars/{id}", updateCar).Methods(http.MethodPut)
+	myRouter.HandleFunc("/ledger/cars/{id}", patchCar).Methods(http.MethodPatch)
+	myRouter.HandleFunc("/ledger/cars/{id}", scrapCar).Methods(http.MethodDelete)
+	myRouter.HandleFunc("/ledger/cars/{id}/malfunctions", addMalfunction).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/cars/{id}/purchase", buyCar).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/cars/{id}/listing", getSaleListing).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/cars/{id}/listing", listCarForSale).Methods(http.MethodPut)
+	myRouter.HandleFunc("/ledger/cars/{id}/listing", withdrawListing).Methods(http.MethodDelete)
+	myRouter.HandleFunc("/ledger/cars/{id}/offers", getOffers).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/cars/{id}/offers", makeOffer).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/cars/{id}/offers/{buyer}", getOffer).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/cars/{id}/offers/{buyer}", withdrawOffer).Methods(http.MethodDelete)
+	myRouter.HandleFunc("/ledger/cars/{id}/offers/{buyer}/acceptance", acceptOffer).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/cars/{id}/repair-orders", getRepairOrdersForCar).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/cars/{id}/repair-orders", openRepairOrder).Methods(http.MethodPost)
+
+	myRouter.HandleFunc("/ledger/repair-orders/{id}", getRepairOrder).Methods(http.MethodGet)
+	myRouter.HandleFunc("/ledger/repair-orders/{id}/quote", quoteRepairOrder).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/repair-orders/{id}/approval", approveRepairOrder).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/repair-orders/{id}/start", startRepairOrder).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/repair-orders/{id}/completion", completeRepairOrder).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ledger/repair-orders/{id}/cancellation", cancelRepairOrder).Methods(http.MethodPost)
+
+	myRouter.HandleFunc("/transactions/{txId}", getTransaction).Methods(http.MethodGet)
+	myRouter.HandleFunc("/events", streamEvents).Methods(http.MethodGet).Name(eventsRoute)
+	myRouter.HandleFunc("/openapi.yaml", getOpenAPI).Methods(http.MethodGet)
+
+	return myRouter
+}
+
+// withTimeout limits the transactions of a request to requestTimeout. Event
+// streams are not limited.
+func withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isEventStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
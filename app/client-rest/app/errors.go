@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric-samples/client-submit"
+)
+
+// Error codes of ErrorResponse.
+const (
+	CodeBadRequest				= "BAD_REQUEST"
+	CodeUnauthorized			= "UNAUTHORIZED"
+	CodeUnsupportedMediaType	= "UNSUPPORTED_MEDIA_TYPE"
+	CodeForbidden				= "FORBIDDEN"
+	CodeNotFound				= "NOT_FOUND"
+	CodeMethodNotAllowed		= "METHOD_NOT_ALLOWED"
+	CodeGone					= "GONE"
+	CodeConflict				= "CONFLICT"
+	CodeTransactionInvalid		= "TRANSACTION_INVALID"
+	CodeBadGateway				= "BAD_GATEWAY"
+	CodeGatewayTimeout			= "GATEWAY_TIMEOUT"
+	CodeInternalError			= "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the body of every failed request. ChaincodeError is the
+// message the chaincode rejected the transaction with, and TxID the id of a
+// transaction that reached the orderer. Classification is the class of a
+// failed transaction, e.g. MVCC_CONFLICT, and Attempts how often it was
+// submitted.
+type ErrorResponse struct {
+	Code			string
+	Message			string
+	ChaincodeError	string	`json:",omitempty"`
+	TxID			string	`json:",omitempty"`
+	Classification	string	`json:",omitempty"`
+	Attempts		int		`json:",omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, ErrorResponse { Code: code, Message: message })
+}
+
+// writeTransactionError reports a failed evaluation or submission. action
+// describes what the request tried to do, e.g. "buy car".
+func writeTransactionError(w http.ResponseWriter, action string, err error, txId string) {
+	response := classifyError(err)
+	response.Message = fmt.Sprintf("Failed to %s: %s", action, response.Message)
+	response.TxID = txId
+
+	writeJSON(w, httpStatus(response.Code), response)
+}
+
+func httpStatus(code string) int {
+	switch code {
+	case CodeBadRequest:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case CodeGone:
+		return http.StatusGone
+	case CodeConflict, CodeTransactionInvalid:
+		return http.StatusConflict
+	case CodeGatewayTimeout:
+		return http.StatusGatewayTimeout
+	case CodeInternalError:
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusBadGateway
+}
+
+// classifyError tells rejections by the chaincode apart from failures of the
+// gateway, the endorsing peers or the orderer, which are 502 or 504. A
+// transaction that was submitted more than once reports the classification of
+// its last attempt.
+func classifyError(err error) ErrorResponse {
+	response := ErrorResponse{}
+
+	var failure clientsubmit.Failure
+	var submitErr *clientsubmit.Error
+	if errors.As(err, &submitErr) {
+		failure = submitErr.Failure
+		response.Attempts = submitErr.Attempts
+	} else {
+		failure = clientsubmit.Classify(err)
+	}
+
+	response.Classification = string(failure.Class)
+	response.Message = failure.Message
+
+	switch failure.Class {
+	case clientsubmit.BusinessError:
+		response.Code = chaincodeErrorCode(failure.Message)
+		response.ChaincodeError = failure.Message
+	case clientsubmit.MVCCConflict, clientsubmit.PhantomRead, clientsubmit.Invalid:
+		response.Code = CodeTransactionInvalid
+	case clientsubmit.Timeout:
+		response.Code = CodeGatewayTimeout
+	default:
+		response.Code = CodeBadGateway
+	}
+
+	return response
+}
+
+// chaincodeErrorCode maps the messages of the cars chaincode to error codes.
+// Requests the chaincode finds malformed are bad requests, and everything
+// else it refuses breaks a business rule, e.g. "Buyer does not have enough
+// money!".
+func chaincodeErrorCode(message string) string {
+	switch {
+	case strings.Contains(message, "does not exist"):
+		return CodeNotFound
+	case strings.Contains(message, "is not allowed to"):
+		return CodeForbidden
+	case strings.Contains(message, " must "),
+		strings.Contains(message, "is not valid"),
+		strings.Contains(message, "Conversion error"):
+		return CodeBadRequest
+	}
+
+	return CodeConflict
+}
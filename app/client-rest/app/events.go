@@ -0,0 +1,553 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+)
+
+// Types of StreamEvent.
+const (
+	EventTypeChaincode	= "chaincode"
+	EventTypeBlock		= "block"
+)
+
+// eventHistory is how many events the hub keeps for clients that resume.
+const eventHistory = 1000
+
+// subscriberBuffer is how many events a client may fall behind before the hub
+// drops it. A dropped client resumes where it left off when it reconnects.
+const subscriberBuffer = 256
+
+// eventRetry is the pause before the hub subscribes again after its
+// subscription failed.
+const eventRetry = 5 * time.Second
+
+// keepAlive is how often an idle stream is written to, so proxies keep it
+// open.
+const keepAlive = 15 * time.Second
+
+// eventsRoute names the route of /events, which is neither limited by the
+// request timeout nor requires headers browsers cannot set on a stream.
+const eventsRoute = "events"
+
+// StreamEvent is a message of /events: a chaincode event, or a committed block
+// with the validation codes of its transactions. ID is "<block>/<txId>" for a
+// chaincode event and "<block>" for a block.
+type StreamEvent struct {
+	ID				string
+	Type			string
+	Name			string				`json:",omitempty"`
+	BlockNumber		uint64
+	TxID			string				`json:",omitempty"`
+	Payload			json.RawMessage		`json:",omitempty"`
+	Transactions	[]BlockTransaction	`json:",omitempty"`
+
+	car		string
+	people	[]string
+}
+
+type BlockTransaction struct {
+	TxID			string
+	ValidationCode	string
+}
+
+// eventFilter selects the events of a stream. Empty fields match everything,
+// and blocks are only streamed when blocks is set.
+type eventFilter struct {
+	names	map[string]bool
+	car		string
+	owner	string
+	blocks	bool
+}
+
+func (f eventFilter) matches(event StreamEvent) bool {
+	if event.Type == EventTypeBlock {
+		return f.blocks
+	}
+
+	if len(f.names) > 0 && !f.names[event.Name] {
+		return false
+	}
+	if f.car != "" && event.car != f.car {
+		return false
+	}
+	if f.owner != "" && !contains(event.people, f.owner) {
+		return false
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+type subscriber struct {
+	filter	eventFilter
+	events	chan StreamEvent
+}
+
+// eventHub receives the chaincode and block events of the channel and fans
+// them out to the streams of /events. seen holds the ids of the history, last
+// is the newest block received and oldest the first block whose events the
+// history holds in full, once received is set.
+type eventHub struct {
+	mutex		sync.Mutex
+	history		[]StreamEvent
+	seen		map[string]bool
+	received	bool
+	last		uint64
+	oldest		uint64
+	subscribers	map[*subscriber]bool
+	stop		chan struct{}
+	closed		bool
+}
+
+var hub = &eventHub{ seen: map[string]bool{}, subscribers: map[*subscriber]bool{}, stop: make(chan struct{}) }
+
+// listen receives events as the identity under label until the hub is closed,
+// subscribing again whenever the subscription fails. A new subscription seeks
+// the last block received, so events committed in between are not missed.
+func (h *eventHub) listen(label string) {
+	for {
+		err := h.receive(label)
+		if err == nil {
+			return
+		}
+
+		log.Printf("Failed to receive events, subscribing again in %s: %s", eventRetry, err)
+
+		select {
+		case <-h.stop:
+			return
+		case <-time.After(eventRetry):
+		}
+	}
+}
+
+func (h *eventHub) receive(label string) error {
+	opts := []event.ClientOption{ event.WithBlockEvents() }
+	if block, ok := h.resumeBlock(); ok {
+		opts = append(opts, event.WithSeekType(seek.FromBlock), event.WithBlockNum(block))
+	}
+
+	client, release, err := contracts.events(label, opts...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	registration, ccEvents, err := client.RegisterChaincodeEvent(contracts.config.Chaincode, ".*")
+	if err != nil {
+		return fmt.Errorf("failed to register for chaincode events: %s", err)
+	}
+	defer client.Unregister(registration)
+
+	blockRegistration, blocks, err := client.RegisterFilteredBlockEvent()
+	if err != nil {
+		return fmt.Errorf("failed to register for block events: %s", err)
+	}
+	defer client.Unregister(blockRegistration)
+
+	for {
+		select {
+		case <-h.stop:
+			return nil
+		case ccEvent, ok := <-ccEvents:
+			if !ok {
+				return errors.New("chaincode events were closed")
+			}
+			h.publish(chaincodeEvent(ccEvent))
+		case block, ok := <-blocks:
+			if !ok {
+				return errors.New("block events were closed")
+			}
+			h.publish(blockEvent(block))
+		}
+	}
+}
+
+// resumeBlock is the block a new subscription seeks: the last block received,
+// whose events may not all have been received.
+func (h *eventHub) resumeBlock() (uint64, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.last, h.received
+}
+
+// chaincodeEvent reads the car and the persons of an event from its payload,
+// which holds the owner at the time of the transaction. Events of earlier
+// chaincode versions name no owner and only match streams without one.
+func chaincodeEvent(event *fab.CCEvent) StreamEvent {
+	payload := json.RawMessage(event.Payload)
+	if !json.Valid(payload) {
+		payload, _ = json.Marshal(string(event.Payload))
+	}
+
+	var parties struct {
+		CarID		string
+		PersonID	string
+		Owner		string
+		Seller		string
+		Buyer		string
+	}
+	err := json.Unmarshal(payload, &parties)
+	if err != nil {
+		log.Printf("Event %s of transaction %s has no car or person: %s", event.EventName, event.TxID, err)
+	}
+
+	people := []string{}
+	for _, person := range []string{ parties.PersonID, parties.Owner, parties.Seller, parties.Buyer } {
+		if person != "" {
+			people = append(people, person)
+		}
+	}
+
+	return StreamEvent {
+		ID: fmt.Sprintf("%d/%s", event.BlockNumber, event.TxID),
+		Type: EventTypeChaincode,
+		Name: event.EventName,
+		BlockNumber: event.BlockNumber,
+		TxID: event.TxID,
+		Payload: payload,
+		car: parties.CarID,
+		people: people,
+	}
+}
+
+func blockEvent(event *fab.FilteredBlockEvent) StreamEvent {
+	block := event.FilteredBlock
+
+	transactions := []BlockTransaction{}
+	for _, tx := range block.FilteredTransactions {
+		transactions = append(transactions, BlockTransaction{ TxID: tx.Txid, ValidationCode: tx.TxValidationCode.String() })
+	}
+
+	return StreamEvent {
+		ID: strconv.FormatUint(block.Number, 10),
+		Type: EventTypeBlock,
+		BlockNumber: block.Number,
+		Transactions: transactions,
+	}
+}
+
+// publish keeps event in the history and sends it to the matching
+// subscribers. An event the history already has, received again when the hub
+// resumed, is skipped. A subscriber whose buffer is full is dropped.
+func (h *eventHub) publish(event StreamEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.seen[event.ID] {
+		return
+	}
+
+	if !h.received {
+		h.received, h.oldest = true, event.BlockNumber
+	}
+	if event.BlockNumber > h.last {
+		h.last = event.BlockNumber
+	}
+
+	if len(h.history) == eventHistory {
+		evicted := h.history[0]
+		delete(h.seen, evicted.ID)
+		if evicted.BlockNumber >= h.oldest {
+			h.oldest = evicted.BlockNumber + 1
+		}
+
+		copy(h.history, h.history[1:])
+		h.history = h.history[:eventHistory - 1]
+	}
+	h.history = append(h.history, event)
+	h.seen[event.ID] = true
+
+	for s := range h.subscribers {
+		if !s.filter.matches(event) {
+			continue
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// subscribe returns a subscriber for the events of filter with the events of
+// the history it resumes from: the events after lastEventId when the history
+// still has it, else the events of fromBlock, or of the block of lastEventId,
+// and later. It fails when the history no longer has all events of that
+// block.
+func (h *eventHub) subscribe(filter eventFilter, lastEventId string, fromBlock *uint64) (*subscriber, []StreamEvent, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := &subscriber{ filter: filter, events: make(chan StreamEvent, subscriberBuffer) }
+	if h.closed {
+		close(s.events)
+		return s, nil, nil
+	}
+
+	start, found := len(h.history), false
+	for i, event := range h.history {
+		if lastEventId != "" && event.ID == lastEventId {
+			start, found = i + 1, true
+			break
+		}
+	}
+
+	// An event the history no longer has is resumed from its block.
+	if !found && lastEventId != "" && fromBlock == nil {
+		block, err := strconv.ParseUint(strings.SplitN(lastEventId, "/", 2)[0], 10, 64)
+		if err == nil {
+			fromBlock = &block
+		}
+	}
+
+	if !found && fromBlock != nil {
+		if h.received && *fromBlock < h.oldest {
+			return nil, nil, fmt.Errorf("Events before block %d are no longer available!", h.oldest)
+		}
+
+		for i, event := range h.history {
+			if event.BlockNumber >= *fromBlock {
+				start = i
+				break
+			}
+		}
+	}
+
+	backlog := []StreamEvent{}
+	for _, event := range h.history[start:] {
+		if filter.matches(event) {
+			backlog = append(backlog, event)
+		}
+	}
+
+	h.subscribers[s] = true
+	return s, backlog, nil
+}
+
+func (h *eventHub) unsubscribe(s *subscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.subscribers[s] {
+		delete(h.subscribers, s)
+		close(s.events)
+	}
+}
+
+// close ends the subscription of the hub and every stream.
+func (h *eventHub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	close(h.stop)
+
+	for s := range h.subscribers {
+		delete(h.subscribers, s)
+		close(s.events)
+	}
+}
+
+// isEventStream tells whether r is a request of /events.
+func isEventStream(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	return route != nil && route.GetName() == eventsRoute
+}
+
+// parseEventFilter reads the event, car, owner and blocks parameters, and
+// the fromBlock parameter resuming a stream.
+func parseEventFilter(params url.Values) (eventFilter, *uint64, error) {
+	filter := eventFilter {
+		names: map[string]bool{},
+		car: params.Get("car"),
+		owner: params.Get("owner"),
+		blocks: params.Get("blocks") == "true",
+	}
+
+	for _, value := range params["event"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				filter.names[name] = true
+			}
+		}
+	}
+
+	value := params.Get("fromBlock")
+	if value == "" {
+		return filter, nil, nil
+	}
+
+	fromBlock, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return filter, nil, errors.New("Parameter fromBlock must be a block number!")
+	}
+
+	return filter, &fromBlock, nil
+}
+
+// streamEvents serves /events as Server-Sent Events, or as a WebSocket of JSON
+// messages when the request upgrades to one.
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+	filter, fromBlock, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = r.URL.Query().Get("lastEventId")
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		streamWebSocket(w, r, filter, lastEventId, fromBlock)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, CodeInternalError, "Streaming is not supported!")
+		return
+	}
+
+	s, backlog, err := hub.subscribe(filter, lastEventId, fromBlock)
+	if err != nil {
+		writeError(w, http.StatusGone, CodeGone, err.Error())
+		return
+	}
+	defer hub.unsubscribe(s)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range backlog {
+		writeServerSentEvent(w, event)
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-s.events:
+			if !ok {
+				return
+			}
+			writeServerSentEvent(w, event)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// writeServerSentEvent writes event under its chaincode event name, or
+// "block", so browsers can listen for single events.
+func writeServerSentEvent(w http.ResponseWriter, event StreamEvent) {
+	name := event.Name
+	if event.Type == EventTypeBlock {
+		name = EventTypeBlock
+	}
+
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, name, data)
+}
+
+// upgrader accepts WebSockets from every origin, as callers authenticate with
+// an API key or a token rather than a cookie.
+var upgrader = websocket.Upgrader {
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// streamWebSocket subscribes before upgrading, so a stream that cannot
+// resume is answered with 410 like a stream of Server-Sent Events.
+func streamWebSocket(w http.ResponseWriter, r *http.Request, filter eventFilter, lastEventId string, fromBlock *uint64) {
+	s, backlog, err := hub.subscribe(filter, lastEventId, fromBlock)
+	if err != nil {
+		writeError(w, http.StatusGone, CodeGone, err.Error())
+		return
+	}
+	defer hub.unsubscribe(s)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// The client only sends control messages, read to answer them and to
+	// notice when it goes away.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range backlog {
+		if conn.WriteJSON(event) != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case event, ok := <-s.events:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+				return
+			}
+			if conn.WriteJSON(event) != nil {
+				return
+			}
+		case <-ticker.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(keepAlive)) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHub() *eventHub {
+	return &eventHub{ seen: map[string]bool{}, subscribers: map[*subscriber]bool{}, stop: make(chan struct{}) }
+}
+
+func testEvent(block uint64, txId string) StreamEvent {
+	return StreamEvent{ ID: fmt.Sprintf("%d/%s", block, txId), Type: EventTypeChaincode, Name: "CarSold", BlockNumber: block, TxID: txId }
+}
+
+func TestPublishSkipsResumedEvents(t *testing.T) {
+	h := newTestHub()
+
+	h.publish(testEvent(5, "t1"))
+	h.publish(testEvent(6, "t2"))
+	h.publish(testEvent(6, "t2"))
+
+	require.Len(t, h.history, 2)
+
+	block, ok := h.resumeBlock()
+	require.True(t, ok)
+	require.Equal(t, uint64(6), block)
+}
+
+func TestSubscribeOlderThanHistory(t *testing.T) {
+	h := newTestHub()
+	for i := 0; i <= eventHistory; i++ {
+		h.publish(testEvent(uint64(10 + i), "t"))
+	}
+
+	from := uint64(10)
+	_, _, err := h.subscribe(eventFilter{}, "", &from)
+	require.EqualError(t, err, "Events before block 11 are no longer available!")
+
+	_, _, err = h.subscribe(eventFilter{}, "10/t", nil)
+	require.EqualError(t, err, "Events before block 11 are no longer available!")
+
+	from = 11
+	s, backlog, err := h.subscribe(eventFilter{}, "", &from)
+	require.NoError(t, err)
+	require.Len(t, backlog, eventHistory)
+	h.unsubscribe(s)
+}
+
+func TestOwnerFilter(t *testing.T) {
+	event := chaincodeEvent(&fab.CCEvent{ EventName: "BalanceChanged", TxID: "t1", BlockNumber: 3, Payload: []byte(`{"PersonID":"2"}`) })
+
+	require.True(t, eventFilter{ owner: "2" }.matches(event))
+	require.False(t, eventFilter{ owner: "1" }.matches(event))
+
+	event = chaincodeEvent(&fab.CCEvent{ EventName: "CarRepaired", TxID: "t2", BlockNumber: 4, Payload: []byte(`{"CarID":"c1","Owner":"1"}`) })
+	require.True(t, eventFilter{ owner: "1", car: "c1" }.matches(event))
+
+	event = chaincodeEvent(&fab.CCEvent{ EventName: "CarRepaired", TxID: "t3", BlockNumber: 5, Payload: []byte(`{"CarID":"c1"}`) })
+	require.True(t, eventFilter{ car: "c1" }.matches(event))
+	require.False(t, eventFilter{ owner: "1" }.matches(event))
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/client-config"
+	"github.com/hyperledger/fabric-samples/client-wallet"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/core"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// requestTimeout bounds the transactions of a request, including the wait
+// for a submitted transaction to commit.
+const requestTimeout = 30 * time.Second
+
+// contractPool shares a gateway connection per wallet identity between all
+// requests, as the SDK is safe for concurrent use. The connection of the
+// configured identity is made when the API starts, the others when they are
+// first used, and each is made again after it failed.
+type contractPool struct {
+	config		*clientconfig.Config
+	wallet		*gateway.Wallet
+	mutex		sync.Mutex
+	connections	map[string]*connection
+}
+
+// connection is the gateway of an identity with its network and contract, and
+// the channel client that asynchronous transactions are submitted with. Both
+// use the SDK of the connection.
+type connection struct {
+	sdk			*fabsdk.FabricSDK
+	gateway		*gateway.Gateway
+	network		*gateway.Network
+	contract	ledgerContract
+	client		*channel.Client
+}
+
+// ledgerContract is what requests need of the contract of a connection, so
+// tests can replace it. submit returns the commit event of a transaction that
+// reached the orderer, whether it failed or not.
+type ledgerContract interface {
+	evaluate(ctx context.Context, name string, args ...string) ([]byte, error)
+	submit(ctx context.Context, name string, args ...string) ([]byte, *fab.TxStatusEvent, error)
+}
+
+// gatewayContract is the ledgerContract of a gateway contract.
+type gatewayContract struct {
+	contract	*gateway.Contract
+}
+
+func (g gatewayContract) evaluate(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return withContext(ctx, func() ([]byte, error) {
+		return g.contract.EvaluateTransaction(name, args...)
+	})
+}
+
+func (g gatewayContract) submit(ctx context.Context, name string, args ...string) ([]byte, *fab.TxStatusEvent, error) {
+	txn, err := g.contract.CreateTransaction(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commit := txn.RegisterCommitEvent()
+
+	result, err := withContext(ctx, func() ([]byte, error) {
+		return txn.Submit(args...)
+	})
+
+	var event *fab.TxStatusEvent
+	select {
+	case committed, ok := <-commit:
+		if ok {
+			event = committed
+		}
+	default:
+	}
+
+	return result, event, err
+}
+
+// unknownIdentityError is returned for an identity the wallet does not have.
+type unknownIdentityError struct {
+	label	string
+}
+
+func (e unknownIdentityError) Error() string {
+	return fmt.Sprintf("identity %s is not in the wallet", e.label)
+}
+
+var contracts *contractPool
+
+// newContractPool opens the wallet and imports the configured identity when
+// the wallet does not have it.
+func newContractPool(cfg *clientconfig.Config) (*contractPool, error) {
+	wallet, err := gateway.NewFileSystemWallet(cfg.Wallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %s", err)
+	}
+
+	if !wallet.Exists(cfg.Identity) {
+		err = clientwallet.ImportMSP(wallet, cfg.Identity, cfg.MSPID, cfg.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to populate wallet contents: %s", err)
+		}
+	}
+
+	return &contractPool{ config: cfg, wallet: wallet, connections: map[string]*connection{} }, nil
+}
+
+// get returns the connection of the identity under label, connecting first
+// if there is none.
+func (p *contractPool) get(label string) (*connection, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if c, ok := p.connections[label]; ok {
+		return c, nil
+	}
+
+	if !p.wallet.Exists(label) {
+		return nil, unknownIdentityError{ label }
+	}
+
+	c, err := p.connect(label)
+	if err != nil {
+		return nil, err
+	}
+
+	p.connections[label] = c
+	return c, nil
+}
+
+// reset drops the connection c of the identity under label, so the next
+// request reconnects. A connection made since c was handed out is kept.
+func (p *contractPool) reset(label string, c *connection) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.connections[label] != c {
+		return
+	}
+
+	c.close()
+	delete(p.connections, label)
+}
+
+func (p *contractPool) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for label, c := range p.connections {
+		c.close()
+		delete(p.connections, label)
+	}
+}
+
+// connect connects to the gateway with the wallet identity under label. The
+// gateway and the channel client share one SDK, which signs as the identity.
+func (p *contractPool) connect(label string) (*connection, error) {
+	sdk, err := p.newSDK(label)
+	if err != nil {
+		return nil, err
+	}
+
+	gw, err := gateway.Connect(
+		gateway.WithSDK(sdk),
+		gateway.WithUser(label),
+		gateway.WithTimeout(requestTimeout),
+	)
+	if err != nil {
+		sdk.Close()
+		return nil, fmt.Errorf("failed to connect to gateway: %s", err)
+	}
+
+	c := &connection{ sdk: sdk, gateway: gw }
+
+	c.network, err = gw.GetNetwork(p.config.Channel)
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("failed to get network: %s", err)
+	}
+
+	c.contract = gatewayContract{ c.network.GetContract(p.config.Chaincode) }
+
+	c.client, err = channel.New(sdk.ChannelContext(p.config.Channel, fabsdk.WithUser(label)))
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("failed to create channel client: %s", err)
+	}
+
+	return c, nil
+}
+
+// events returns an event client of the channel for the identity under label,
+// which receives the blocks the options seek. It has an SDK of its own, as the
+// SDK shares one event service between the clients of a connection and
+// ignores the seek options of all but the first. close releases it.
+func (p *contractPool) events(label string, opts ...event.ClientOption) (*event.Client, func(), error) {
+	sdk, err := p.newSDK(label)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := event.New(sdk.ChannelContext(p.config.Channel, fabsdk.WithUser(label)), opts...)
+	if err != nil {
+		sdk.Close()
+		return nil, nil, fmt.Errorf("failed to create event client: %s", err)
+	}
+
+	return client, sdk.Close, nil
+}
+
+// newSDK creates an SDK for the connection profile that signs as the wallet
+// identity under label.
+func (p *contractPool) newSDK(label string) (*fabsdk.FabricSDK, error) {
+	stored, err := p.wallet.Get(label)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, ok := stored.(*gateway.X509Identity)
+	if !ok {
+		return nil, fmt.Errorf("identity %s is not an X.509 identity", label)
+	}
+
+	profile := config.FromFile(filepath.Clean(p.config.ConnectionProfile))
+	sdk, err := fabsdk.New(gatewayProfile(profile, p.config.DiscoveryAsLocalhost, label, identity))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SDK: %s", err)
+	}
+
+	return sdk, nil
+}
+
+// close closes the gateway and the SDK of c. The connections of tests have
+// neither.
+func (c *connection) close() {
+	if c.gateway != nil {
+		c.gateway.Close()
+	}
+	if c.sdk != nil {
+		c.sdk.Close()
+	}
+}
+
+// gatewayProfile adds to a connection profile what gateway.WithConfig adds:
+// entity matchers that map discovered peers and orderers to localhost, and a
+// default channel that uses the peers of the organization for everything when
+// the profile defines no channels. It also embeds the wallet identity as the
+// user label of the organization, which the SDK then signs as.
+func gatewayProfile(profile core.ConfigProvider, asLocalhost bool, label string, identity *gateway.X509Identity) core.ConfigProvider {
+	return func() ([]core.ConfigBackend, error) {
+		backends, err := profile()
+		if err != nil {
+			return nil, err
+		}
+		if len(backends) != 1 {
+			return nil, errors.New("invalid connection profile")
+		}
+
+		backend := &profileBackend{ ConfigBackend: backends[0], label: label, identity: identity }
+
+		org, ok := backend.ConfigBackend.Lookup("client.organization")
+		if !ok {
+			return nil, errors.New("connection profile has no client organization")
+		}
+		backend.org = fmt.Sprint(org)
+
+		mspId, _ := backend.ConfigBackend.Lookup(fmt.Sprintf("organizations.%s.mspid", backend.org))
+		if fmt.Sprint(mspId) != identity.MspID {
+			return nil, fmt.Errorf("identity %s belongs to %s, not to %s of the connection profile", label, identity.MspID, backend.org)
+		}
+
+		if asLocalhost {
+			localhost := []map[string]string{{
+				"pattern": "([^:]+):(\\d+)",
+				"urlSubstitutionExp": "localhost:${2}",
+				"sslTargetOverrideUrlSubstitutionExp": "${1}",
+				"mappedHost": "${1}",
+			}}
+			backend.matchers = map[string][]map[string]string{ "peer": localhost, "orderer": localhost }
+		}
+
+		if _, defined := backend.ConfigBackend.Lookup("channels"); !defined {
+			backend.channels = defaultChannel(backend.ConfigBackend, backend.org)
+		}
+
+		return []core.ConfigBackend{ backend }, nil
+	}
+}
+
+type profileBackend struct {
+	core.ConfigBackend
+	org			string
+	label		string
+	identity	*gateway.X509Identity
+	matchers	map[string][]map[string]string
+	channels	map[string]map[string]map[string]map[string]bool
+}
+
+func (b *profileBackend) Lookup(key string) (interface{}, bool) {
+	switch {
+	case key == "entityMatchers" && b.matchers != nil:
+		return b.matchers, true
+	case key == "channels" && b.channels != nil:
+		return b.channels, true
+	case key == "organizations":
+		return b.organizations()
+	}
+
+	return b.ConfigBackend.Lookup(key)
+}
+
+// organizations returns the organizations of the profile with the wallet
+// identity among the users of the client organization.
+func (b *profileBackend) organizations() (interface{}, bool) {
+	value, ok := b.ConfigBackend.Lookup("organizations")
+	if !ok {
+		return value, ok
+	}
+
+	orgs, isMap := value.(map[string]interface{})
+	if !isMap {
+		return value, ok
+	}
+
+	user := map[string]interface{} {
+		"cert": map[string]interface{}{ "pem": b.identity.Certificate() },
+		"key": map[string]interface{}{ "pem": b.identity.Key() },
+	}
+
+	withUser := map[string]interface{}{}
+	for name, org := range orgs {
+		withUser[name] = org
+
+		orgConfig, isMap := org.(map[string]interface{})
+		if !isMap || !strings.EqualFold(name, b.org) {
+			continue
+		}
+
+		users := map[string]interface{}{}
+		if existing, isMap := orgConfig["users"].(map[string]interface{}); isMap {
+			for userName, userConfig := range existing {
+				users[userName] = userConfig
+			}
+		}
+		users[strings.ToLower(b.label)] = user
+
+		orgWithUser := map[string]interface{}{}
+		for field, fieldValue := range orgConfig {
+			orgWithUser[field] = fieldValue
+		}
+		orgWithUser["users"] = users
+
+		withUser[name] = orgWithUser
+	}
+
+	return withUser, true
+}
+
+func defaultChannel(backend core.ConfigBackend, org string) map[string]map[string]map[string]map[string]bool {
+	orgPeers, ok := backend.Lookup(fmt.Sprintf("organizations.%s.peers", org))
+	if !ok {
+		return nil
+	}
+
+	names, ok := orgPeers.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	roles := map[string]bool{ "endorsingPeer": true, "chaincodeQuery": true, "ledgerQuery": true, "eventSource": true }
+	peers := map[string]map[string]bool{}
+	for _, name := range names {
+		peers[fmt.Sprint(name)] = roles
+	}
+
+	return map[string]map[string]map[string]map[string]bool{ "_default": { "peers": peers } }
+}
+
+// withContext runs a transaction until ctx is done. The SDK calls cannot be
+// cancelled, so a submitted transaction may still commit after its request
+// timed out.
+func withContext(ctx context.Context, transaction func() ([]byte, error)) ([]byte, error) {
+	type outcome struct {
+		result	[]byte
+		err		error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := transaction()
+		done <- outcome{ result, err }
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
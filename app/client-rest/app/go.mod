@@ -3,7 +3,21 @@ module cars
 go 1.13
 
 require (
-	github.com/gorilla/mux v1.8.0 // indirect
+	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200707132912-fee30f3ccd23
+	github.com/hyperledger/fabric-samples/cars-client v0.0.0
+	github.com/hyperledger/fabric-samples/client-config v0.0.0
+	github.com/hyperledger/fabric-samples/client-submit v0.0.0
+	github.com/hyperledger/fabric-samples/client-wallet v0.0.0
 	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+	github.com/stretchr/testify v1.5.1
+)
+
+replace (
+	github.com/hyperledger/fabric-samples/cars-client => ../../cars-client
+	github.com/hyperledger/fabric-samples/client-config => ../../client-config
+	github.com/hyperledger/fabric-samples/client-submit => ../../client-submit
+	github.com/hyperledger/fabric-samples/client-wallet => ../../client-wallet
 )
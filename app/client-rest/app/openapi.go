@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/hyperledger/fabric-samples/cars-client"
+)
+
+// getOpenAPI serves the OpenAPI document of the API, which the cars-client
+// module generates its Go client from.
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(carsclient.Spec)
+}
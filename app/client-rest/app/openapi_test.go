@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/fabric-samples/cars-client"
+	"github.com/hyperledger/fabric-samples/cars-client/openapi"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaTypes are the Go types of the schemas of the OpenAPI document.
+var schemaTypes = map[string]interface{}{
+	"AmountRequest": AmountRequest{},
+	"BlockTransaction": BlockTransaction{},
+	"Car": Car{},
+	"CarPatch": CarPatch{},
+	"CarRequest": CarRequest{},
+	"CarsPage": CarsPage{},
+	"ListingRequest": ListingRequest{},
+	"OfferRequest": OfferRequest{},
+	"ErrorResponse": ErrorResponse{},
+	"Malfunction": Malfunction{},
+	"MalfunctionRequest": MalfunctionRequest{},
+	"Money": Money{},
+	"OwnerSummary": OwnerSummary{},
+	"Person": Person{},
+	"PersonPatch": PersonPatch{},
+	"PersonRequest": PersonRequest{},
+	"PurchaseOffer": PurchaseOffer{},
+	"PurchaseRequest": PurchaseRequest{},
+	"RepairOrder": RepairOrder{},
+	"RepairOrderRequest": RepairOrderRequest{},
+	"SaleListing": SaleListing{},
+	"StreamEvent": StreamEvent{},
+	"TransactionStatus": TransactionStatus{},
+}
+
+func parseSpec(t *testing.T) *openapi.Document {
+	doc, err := openapi.Parse(carsclient.Spec)
+	require.NoError(t, err)
+
+	return doc
+}
+
+func TestOpenAPIDescribesRoutes(t *testing.T) {
+	doc := parseSpec(t)
+
+	var routes []string
+	err := newRouter(&authenticator{}).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+
+		for _, method := range methods {
+			routes = append(routes, method + " " + path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var documented []string
+	for _, path := range doc.Paths {
+		for _, operation := range path.Operations {
+			documented = append(documented, strings.ToUpper(operation.Method) + " " + path.Path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+	require.Equal(t, routes, documented, "openapi.yaml of cars-client must describe every route")
+}
+
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	doc := parseSpec(t)
+
+	var names []string
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var typed []string
+	for name := range schemaTypes {
+		typed = append(typed, name)
+	}
+	sort.Strings(typed)
+	require.Equal(t, typed, names)
+
+	for name, value := range schemaTypes {
+		schema := doc.Components.Schemas[name]
+		goType := reflect.TypeOf(value)
+
+		var fields []string
+		for i := 0; i < goType.NumField(); i++ {
+			field := goType.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fields = append(fields, field.Name)
+
+			property, ok := schema.Property(field.Name)
+			require.True(t, ok, "%s.%s is not in the schema", name, field.Name)
+			requireSchemaType(t, doc, name + "." + field.Name, property, field.Type)
+		}
+
+		var properties []string
+		for _, property := range schema.Properties {
+			properties = append(properties, property.Name)
+		}
+		require.ElementsMatch(t, fields, properties, "properties of %s", name)
+	}
+}
+
+func requireSchemaType(t *testing.T, doc *openapi.Document, name string, schema *openapi.Schema, goType reflect.Type) {
+	if schema.Nullable {
+		require.Equal(t, reflect.Ptr, goType.Kind(), "%s is nullable", name)
+		goType = goType.Elem()
+	}
+
+	if schema.Ref != "" {
+		require.Equal(t, openapi.RefName(schema.Ref), goType.Name(), name)
+		return
+	}
+
+	switch schema.Type {
+	case "":
+		require.Equal(t, reflect.TypeOf(json.RawMessage{}), goType, name)
+	case "string":
+		if schema.Format == "date-time" {
+			require.Equal(t, reflect.TypeOf(time.Time{}), goType, name)
+		} else {
+			require.Equal(t, reflect.String, goType.Kind(), name)
+		}
+	case "integer":
+		require.Contains(t, []reflect.Kind{ reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint64 }, goType.Kind(), name)
+	case "boolean":
+		require.Equal(t, reflect.Bool, goType.Kind(), name)
+	case "array":
+		require.Equal(t, reflect.Slice, goType.Kind(), name)
+		requireSchemaType(t, doc, name + "[]", schema.Items, goType.Elem())
+	default:
+		require.Failf(t, "unsupported schema type", "%s has type %s", name, schema.Type)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	response := httptest.NewRecorder()
+	newRouter(&authenticator{}).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "application/yaml", response.Header().Get("Content-Type"))
+	require.Equal(t, carsclient.Spec, response.Body.Bytes())
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// PersonRequest is the body of POST /ledger/persons and of PUT, which takes
+// the id from the path.
+type PersonRequest struct {
+	ID		string
+	Name	string
+	Surname	string
+	Email	string
+}
+
+// PersonPatch is the body of PATCH /ledger/persons/{id}. Missing fields keep
+// their value.
+type PersonPatch struct {
+	Name	*string
+	Surname	*string
+	Email	*string
+}
+
+// AmountRequest is the body of deposits and withdrawals.
+type AmountRequest struct {
+	Amount	string
+}
+
+func getPersonById(w http.ResponseWriter, r *http.Request) {
+	writePerson(w, r, http.StatusOK, mux.Vars(r)["id"])
+}
+
+func createPerson(w http.ResponseWriter, r *http.Request) {
+	var request PersonRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.required("ID", request.ID)
+	validatePersonDetails(&v, request.Name, request.Surname, request.Email)
+	if v.failed(w) {
+		return
+	}
+
+	_, ok := submit(w, r, "create person", "CreatePerson", request.ID, request.Name, request.Surname, request.Email)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/ledger/persons/%s", request.ID))
+	writePerson(w, r, http.StatusCreated, request.ID)
+}
+
+func updatePerson(w http.ResponseWriter, r *http.Request) {
+	var request PersonRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	personId := mux.Vars(r)["id"]
+	if request.ID != "" && request.ID != personId {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "Person id in the body does not match the path!")
+		return
+	}
+
+	var v validation
+	validatePersonDetails(&v, request.Name, request.Surname, request.Email)
+	if v.failed(w) {
+		return
+	}
+
+	submitPersonUpdate(w, r, personId, request.Name, request.Surname, request.Email)
+}
+
+func patchPerson(w http.ResponseWriter, r *http.Request) {
+	var patch PersonPatch
+	if !readJSON(w, r, &patch) {
+		return
+	}
+
+	personId := mux.Vars(r)["id"]
+
+	person, ok := evaluatePerson(w, r, personId)
+	if !ok {
+		return
+	}
+
+	for _, field := range []struct {
+		value	*string
+		target	*string
+	}{
+		{ patch.Name, &person.Name },
+		{ patch.Surname, &person.Surname },
+		{ patch.Email, &person.Email },
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+
+	var v validation
+	validatePersonDetails(&v, person.Name, person.Surname, person.Email)
+	if v.failed(w) {
+		return
+	}
+
+	submitPersonUpdate(w, r, personId, person.Name, person.Surname, person.Email)
+}
+
+func submitPersonUpdate(w http.ResponseWriter, r *http.Request, personId string, name string, surname string, email string) {
+	_, ok := submit(w, r, "update person", "UpdatePerson", personId, name, surname, email)
+	if !ok {
+		return
+	}
+
+	writePerson(w, r, http.StatusOK, personId)
+}
+
+func depositMoney(w http.ResponseWriter, r *http.Request) {
+	submitAmount(w, r, "DepositMoney", "deposit money")
+}
+
+func withdrawMoney(w http.ResponseWriter, r *http.Request) {
+	submitAmount(w, r, "WithdrawMoney", "withdraw money")
+}
+
+func submitAmount(w http.ResponseWriter, r *http.Request, transaction string, action string) {
+	var request AmountRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.amount("Amount", request.Amount)
+	if v.failed(w) {
+		return
+	}
+
+	personId := mux.Vars(r)["id"]
+
+	_, ok := submit(w, r, action, transaction, personId, request.Amount)
+	if !ok {
+		return
+	}
+
+	writePerson(w, r, http.StatusOK, personId)
+}
+
+// getCarsByOwner serves /ledger/persons/{id}/cars, filtered by the color
+// parameter when it is given.
+func getCarsByOwner(w http.ResponseWriter, r *http.Request) {
+	ownerId := mux.Vars(r)["id"]
+	color := r.URL.Query().Get("color")
+	includeWrittenOff := strconv.FormatBool(r.URL.Query().Get("includeWrittenOff") == "true")
+
+	var cars []byte
+	var ok bool
+	if color == "" {
+		cars, ok = evaluate(w, r, "get cars of the owner", "GetCarsByOwner", ownerId, includeWrittenOff)
+	} else {
+		cars, ok = evaluate(w, r, "get cars of the owner", "GetCarsByOwnerAndColor", ownerId, color, includeWrittenOff)
+	}
+	if !ok {
+		return
+	}
+
+	carsJson := []Car{}
+	if !decodeResult(w, "get cars of the owner", cars, &carsJson) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, carsJson)
+}
+
+func getOwnerSummary(w http.ResponseWriter, r *http.Request) {
+	summary, ok := evaluate(w, r, "get owner summary", "GetOwnerSummary", mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	var summaryJson OwnerSummary
+	if !decodeResult(w, "get owner summary", summary, &summaryJson) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summaryJson)
+}
+
+func validatePersonDetails(v *validation, name string, surname string, email string) {
+	v.required("Name", name)
+	v.required("Surname", surname)
+	v.email("Email", email)
+}
+
+func evaluatePerson(w http.ResponseWriter, r *http.Request, personId string) (*Person, bool) {
+	personJson, ok := evaluate(w, r, "get person", "GetPerson", personId)
+	if !ok {
+		return nil, false
+	}
+
+	var person Person
+	if !decodeResult(w, "get person", personJson, &person) {
+		return nil, false
+	}
+
+	return &person, true
+}
+
+// writePerson responds with the person as stored after a transaction.
+func writePerson(w http.ResponseWriter, r *http.Request, status int, personId string) {
+	person, ok := evaluatePerson(w, r, personId)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, status, person)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RepairOrder mirrors the chaincode repair order. The Quote is taken from the
+// owner on approval and paid to the mechanic on completion.
+type RepairOrder struct {
+	ID				string
+	CarID			string
+	Owner			string
+	Mechanic		string
+	Malfunctions	[]Malfunction
+	Quote			Money
+	Status			string
+	UpdatedAt		time.Time
+}
+
+// RepairOrderRequest is the body of POST /ledger/cars/{id}/repair-orders.
+type RepairOrderRequest struct {
+	ID	string
+}
+
+func getRepairOrder(w http.ResponseWriter, r *http.Request) {
+	writeRepairOrder(w, r, http.StatusOK, mux.Vars(r)["id"])
+}
+
+func getRepairOrdersForCar(w http.ResponseWriter, r *http.Request) {
+	orders, ok := evaluate(w, r, "get repair orders", "GetRepairOrdersForCar", mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	ordersJson := []RepairOrder{}
+	if !decodeResult(w, "get repair orders", orders, &ordersJson) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ordersJson)
+}
+
+// openRepairOrder reports the current malfunctions of the car for repair. It
+// is submitted by the owner.
+func openRepairOrder(w http.ResponseWriter, r *http.Request) {
+	var request RepairOrderRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.required("ID", request.ID)
+	if v.failed(w) {
+		return
+	}
+
+	_, ok := submit(w, r, "open repair order", "OpenRepairOrder", request.ID, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/ledger/repair-orders/%s", request.ID))
+	writeRepairOrder(w, r, http.StatusCreated, request.ID)
+}
+
+// quoteRepairOrder prices the repair. It is submitted by a mechanic.
+func quoteRepairOrder(w http.ResponseWriter, r *http.Request) {
+	var request AmountRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.amount("Amount", request.Amount)
+	if v.failed(w) {
+		return
+	}
+
+	submitRepairOrder(w, r, "quote repair order", "QuoteRepairOrder", request.Amount)
+}
+
+func approveRepairOrder(w http.ResponseWriter, r *http.Request) {
+	submitRepairOrder(w, r, "approve repair order", "ApproveRepairOrder")
+}
+
+func startRepairOrder(w http.ResponseWriter, r *http.Request) {
+	submitRepairOrder(w, r, "start repair order", "StartRepairOrder")
+}
+
+func completeRepairOrder(w http.ResponseWriter, r *http.Request) {
+	submitRepairOrder(w, r, "complete repair order", "CompleteRepairOrder")
+}
+
+func cancelRepairOrder(w http.ResponseWriter, r *http.Request) {
+	submitRepairOrder(w, r, "cancel repair order", "CancelRepairOrder")
+}
+
+// submitRepairOrder submits a step of the repair order in the path and
+// responds with the order as stored.
+func submitRepairOrder(w http.ResponseWriter, r *http.Request, action string, transaction string, args ...string) {
+	orderId := mux.Vars(r)["id"]
+
+	_, ok := submit(w, r, action, transaction, append([]string{ orderId }, args...)...)
+	if !ok {
+		return
+	}
+
+	writeRepairOrder(w, r, http.StatusOK, orderId)
+}
+
+// writeRepairOrder responds with the repair order as stored after a
+// transaction.
+func writeRepairOrder(w http.ResponseWriter, r *http.Request, status int, orderId string) {
+	orderJson, ok := evaluate(w, r, "get repair order", "GetRepairOrder", orderId)
+	if !ok {
+		return
+	}
+
+	var order RepairOrder
+	if !decodeResult(w, "get repair order", orderJson, &order) {
+		return
+	}
+
+	writeJSON(w, status, order)
+}
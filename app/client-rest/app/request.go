@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxBodySize limits the JSON body of a request.
+const maxBodySize = 1 << 20
+
+const firstCarYear = 1886
+
+// amountPattern matches the amounts the chaincode accepts, e.g. "1500",
+// "1500.50" or "1500.50 USD".
+var amountPattern = regexp.MustCompile(`^\d+(\.\d{1,2})?( [A-Za-z]{3})?$`)
+
+// readJSON decodes the JSON body of r into target. Unknown fields and
+// trailing data are rejected.
+func readJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Request body must be JSON (Content-Type: application/json)!")
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(target)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = fmt.Errorf("body must contain a single JSON object")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("Request body is not valid: %s", err))
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// validation collects the problems of a request body.
+type validation struct {
+	problems	[]string
+}
+
+func (v *validation) required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.problems = append(v.problems, fmt.Sprintf("%s must not be empty", field))
+	}
+}
+
+func (v *validation) email(field string, value string) {
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		v.problems = append(v.problems, fmt.Sprintf("%s is not a valid email address", field))
+	}
+}
+
+func (v *validation) year(field string, value int) {
+	maxYear := time.Now().Year() + 1
+	if value < firstCarYear || value > maxYear {
+		v.problems = append(v.problems, fmt.Sprintf("%s must be between %d and %d", field, firstCarYear, maxYear))
+	}
+}
+
+func (v *validation) amount(field string, value string) {
+	if !amountPattern.MatchString(strings.TrimSpace(value)) {
+		v.problems = append(v.problems, fmt.Sprintf("%s must be an amount such as 1500 or 1500.50 EUR", field))
+	}
+}
+
+// duration accepts a positive duration such as 72h, or an empty value.
+func (v *validation) duration(field string, value string) {
+	if value == "" {
+		return
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		v.problems = append(v.problems, fmt.Sprintf("%s must be a positive duration such as 72h", field))
+	}
+}
+
+// failed writes a 400 response listing the problems, if there are any.
+func (v *validation) failed(w http.ResponseWriter) bool {
+	if len(v.problems) == 0 {
+		return false
+	}
+
+	writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid request: " + strings.Join(v.problems, "; ") + ".")
+	return true
+}
@@ -11,4 +11,4 @@ echo "DISCOVERY_AS_LOCALHOST="$DISCOVERY_AS_LOCALHOST
 
 echo "Run client cars REST API"
 
-go run cars_api.go
\ No newline at end of file
+go run . "$@"
\ No newline at end of file
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// SaleListing mirrors the chaincode listing of a car. A zero ExpiresAt means
+// the listing stays open until it is withdrawn.
+type SaleListing struct {
+	CarID		string
+	Seller		string
+	AskingPrice	Money
+	ExpiresAt	time.Time
+}
+
+// PurchaseOffer mirrors the chaincode offer of a buyer for a listed car.
+type PurchaseOffer struct {
+	CarID				string
+	Buyer				string
+	Price				Money
+	AcceptMalfunctions	bool
+	ExpiresAt			time.Time
+}
+
+// ListingRequest is the body of PUT /ledger/cars/{id}/listing. ValidFor is a
+// duration such as 72h; an empty value keeps the listing open until it is
+// withdrawn.
+type ListingRequest struct {
+	AskingPrice	string
+	ValidFor	string
+}
+
+// OfferRequest is the body of POST /ledger/cars/{id}/offers. A car with
+// malfunctions is only bought when AcceptMalfunctions is true.
+type OfferRequest struct {
+	Buyer				string
+	Price				string
+	AcceptMalfunctions	bool
+	ValidFor			string
+}
+
+func getSaleListing(w http.ResponseWriter, r *http.Request) {
+	writeSaleListing(w, r, http.StatusOK, mux.Vars(r)["id"])
+}
+
+// listCarForSale lists the car, or replaces the asking price and expiry of
+// its listing. It is submitted by the owner.
+func listCarForSale(w http.ResponseWriter, r *http.Request) {
+	var request ListingRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.amount("AskingPrice", request.AskingPrice)
+	v.duration("ValidFor", request.ValidFor)
+	if v.failed(w) {
+		return
+	}
+
+	carId := mux.Vars(r)["id"]
+
+	_, ok := submit(w, r, "list car for sale", "ListCarForSale", carId, request.AskingPrice, request.ValidFor)
+	if !ok {
+		return
+	}
+
+	writeSaleListing(w, r, http.StatusOK, carId)
+}
+
+func withdrawListing(w http.ResponseWriter, r *http.Request) {
+	_, ok := submit(w, r, "withdraw listing", "WithdrawListing", mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getOffers(w http.ResponseWriter, r *http.Request) {
+	offers, ok := evaluateOffers(w, r, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, offers)
+}
+
+func getOffer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	writeOffer(w, r, http.StatusOK, vars["id"], vars["buyer"])
+}
+
+// makeOffer offers a price for a listed car. It is submitted by the buyer.
+func makeOffer(w http.ResponseWriter, r *http.Request) {
+	var request OfferRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	var v validation
+	v.required("Buyer", request.Buyer)
+	v.amount("Price", request.Price)
+	v.duration("ValidFor", request.ValidFor)
+	if v.failed(w) {
+		return
+	}
+
+	answer := "no"
+	if request.AcceptMalfunctions {
+		answer = "yes"
+	}
+
+	carId := mux.Vars(r)["id"]
+
+	_, ok := submit(w, r, "make offer", "MakeOffer", carId, request.Buyer, request.Price, answer, request.ValidFor)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/ledger/cars/%s/offers/%s", carId, request.Buyer))
+	writeOffer(w, r, http.StatusCreated, carId, request.Buyer)
+}
+
+func withdrawOffer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	_, ok := submit(w, r, "withdraw offer", "WithdrawOffer", vars["id"], vars["buyer"])
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// acceptOffer sells the car for the offer of the buyer. It is submitted by
+// the owner and responds with the car as stored after the sale.
+func acceptOffer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	_, ok := submit(w, r, "accept offer", "AcceptOffer", vars["id"], vars["buyer"])
+	if !ok {
+		return
+	}
+
+	writeCar(w, r, http.StatusOK, vars["id"])
+}
+
+// writeSaleListing responds with the listing of the car as stored after a
+// transaction.
+func writeSaleListing(w http.ResponseWriter, r *http.Request, status int, carId string) {
+	listingJson, ok := evaluate(w, r, "get sale listing", "GetSaleListing", carId)
+	if !ok {
+		return
+	}
+
+	var listing SaleListing
+	if !decodeResult(w, "get sale listing", listingJson, &listing) {
+		return
+	}
+
+	writeJSON(w, status, listing)
+}
+
+func evaluateOffers(w http.ResponseWriter, r *http.Request, carId string) ([]PurchaseOffer, bool) {
+	offersJson, ok := evaluate(w, r, "get offers", "GetOffers", carId)
+	if !ok {
+		return nil, false
+	}
+
+	offers := []PurchaseOffer{}
+	if !decodeResult(w, "get offers", offersJson, &offers) {
+		return nil, false
+	}
+
+	return offers, true
+}
+
+// writeOffer responds with the offer of the buyer for the car as stored after
+// a transaction.
+func writeOffer(w http.ResponseWriter, r *http.Request, status int, carId string, buyerId string) {
+	offers, ok := evaluateOffers(w, r, carId)
+	if !ok {
+		return
+	}
+
+	for _, offer := range offers {
+		if offer.Buyer == buyerId {
+			writeJSON(w, status, offer)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, CodeNotFound, fmt.Sprintf("Buyer %s has no offer for car %s!", buyerId, carId))
+}
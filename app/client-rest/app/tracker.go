@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// Statuses of a transaction: its proposal was endorsed, it was sent to the
+// orderer, and it was committed valid or invalid.
+const (
+	StatusEndorsed	= "ENDORSED"
+	StatusSubmitted	= "SUBMITTED"
+	StatusCommitted	= "COMMITTED"
+	StatusInvalid	= "INVALID"
+)
+
+// commitTimeout bounds the wait of an asynchronous transaction for its
+// commit event.
+const commitTimeout = 5 * time.Minute
+
+// transactionRetention is how long the status of a committed or invalid
+// transaction stays available.
+const transactionRetention = 15 * time.Minute
+
+// TransactionStatus is the body of GET /transactions/{txId} and of the 202 of
+// an asynchronous request. ValidationCode and BlockNumber come with the commit
+// event, e.g. VALID or MVCC_READ_CONFLICT.
+type TransactionStatus struct {
+	TxID			string
+	Function		string
+	Status			string
+	ValidationCode	string	`json:",omitempty"`
+	BlockNumber		uint64	`json:",omitempty"`
+	Error			string	`json:",omitempty"`
+	Submitted		time.Time
+}
+
+type trackedTransaction struct {
+	identity	string
+	status		TransactionStatus
+	done		chan struct{}
+}
+
+// transactionTracker keeps the status of the transactions submitted through
+// the API for the identity that submitted them.
+type transactionTracker struct {
+	mutex			sync.Mutex
+	transactions	map[string]*trackedTransaction
+}
+
+var transactions = &transactionTracker{ transactions: map[string]*trackedTransaction{} }
+
+// add tracks an endorsed transaction.
+func (t *transactionTracker) add(identity string, txId string, function string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.transactions[txId] = &trackedTransaction {
+		identity: identity,
+		status: TransactionStatus{ TxID: txId, Function: function, Status: StatusEndorsed, Submitted: time.Now().UTC() },
+		done: make(chan struct{}),
+	}
+}
+
+func (t *transactionTracker) submitted(txId string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if tx, ok := t.transactions[txId]; ok && tx.status.Status == StatusEndorsed {
+		tx.status.Status = StatusSubmitted
+	}
+}
+
+// committed records the commit event of a transaction and forgets the
+// transaction after transactionRetention.
+func (t *transactionTracker) committed(txId string, event *fab.TxStatusEvent) {
+	t.finish(txId, func(status *TransactionStatus) {
+		status.Status = StatusCommitted
+		if event.TxValidationCode != peer.TxValidationCode_VALID {
+			status.Status = StatusInvalid
+		}
+		status.ValidationCode = event.TxValidationCode.String()
+		status.BlockNumber = event.BlockNumber
+	})
+}
+
+// expired records that no commit event came within commitTimeout. The
+// transaction may still have been committed.
+func (t *transactionTracker) expired(txId string) {
+	t.finish(txId, func(status *TransactionStatus) {
+		status.Error = fmt.Sprintf("No commit event was received within %s!", commitTimeout)
+	})
+}
+
+// record tracks a transaction submitted synchronously.
+func (t *transactionTracker) record(identity string, function string, event *fab.TxStatusEvent) {
+	t.add(identity, event.TxID, function)
+	t.committed(event.TxID, event)
+}
+
+func (t *transactionTracker) finish(txId string, change func(status *TransactionStatus)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tx, ok := t.transactions[txId]
+	if !ok {
+		return
+	}
+
+	change(&tx.status)
+	close(tx.done)
+
+	time.AfterFunc(transactionRetention, func() {
+		t.remove(txId)
+	})
+}
+
+func (t *transactionTracker) remove(txId string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.transactions, txId)
+}
+
+// get returns the status of a transaction of identity and a channel closed
+// once the transaction is committed or invalid.
+func (t *transactionTracker) get(identity string, txId string) (TransactionStatus, <-chan struct{}, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tx, ok := t.transactions[txId]
+	if !ok || tx.identity != identity {
+		return TransactionStatus{}, nil, false
+	}
+
+	return tx.status, tx.done, true
+}
+
+// wait returns the status of a transaction of identity once it is committed
+// or invalid, wait has passed or ctx is done.
+func (t *transactionTracker) wait(ctx context.Context, identity string, txId string, wait time.Duration) (TransactionStatus, bool) {
+	_, done, ok := t.get(identity, txId)
+	if !ok {
+		return TransactionStatus{}, false
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	status, _, ok := t.get(identity, txId)
+	return status, ok
+}
+
+// asyncCommitHandler sends an endorsed transaction to the orderer and waits
+// for its commit in the background, where the handler of the gateway waits
+// before it returns.
+type asyncCommitHandler struct {
+	identity	string
+	function	string
+}
+
+func (h *asyncCommitHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	txId := string(requestContext.Response.TransactionID)
+	transactions.add(h.identity, txId, h.function)
+
+	registration, statuses, err := clientContext.EventService.RegisterTxStatusEvent(txId)
+	if err != nil {
+		transactions.remove(txId)
+		requestContext.Error = fmt.Errorf("failed to register for the commit event: %s", err)
+		return
+	}
+
+	tx, err := clientContext.Transactor.CreateTransaction(fab.TransactionRequest {
+		Proposal: requestContext.Response.Proposal,
+		ProposalResponses: requestContext.Response.Responses,
+	})
+	if err == nil {
+		_, err = clientContext.Transactor.SendTransaction(tx)
+	}
+	if err != nil {
+		clientContext.EventService.Unregister(registration)
+		transactions.remove(txId)
+		requestContext.Error = err
+		return
+	}
+
+	transactions.submitted(txId)
+
+	go func() {
+		defer clientContext.EventService.Unregister(registration)
+
+		select {
+		case event := <-statuses:
+			transactions.committed(txId, event)
+		case <-time.After(commitTimeout):
+			transactions.expired(txId)
+		}
+	}()
+}
+
+// getTransaction serves /transactions/{txId}?wait=10s. With wait it answers
+// once the transaction is committed or invalid, or when the wait or the
+// request timeout is over, whichever comes first.
+func getTransaction(w http.ResponseWriter, r *http.Request) {
+	txId := mux.Vars(r)["txId"]
+
+	var wait time.Duration
+	if value := r.URL.Query().Get("wait"); value != "" {
+		var err error
+		wait, err = time.ParseDuration(value)
+		if err != nil || wait < 0 {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "Parameter wait must be a duration such as 10s!")
+			return
+		}
+	}
+
+	status, ok := transactions.wait(r.Context(), identityOf(r), txId, wait)
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeNotFound, fmt.Sprintf("Transaction %s does not exist!", txId))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
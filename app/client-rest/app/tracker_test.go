@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerStatuses(t *testing.T) {
+	tracker := &transactionTracker{ transactions: map[string]*trackedTransaction{} }
+
+	tracker.add("User1", "t1", "BuyCar")
+	status, done, ok := tracker.get("User1", "t1")
+	require.True(t, ok)
+	require.Equal(t, StatusEndorsed, status.Status)
+
+	_, _, ok = tracker.get("User2", "t1")
+	require.False(t, ok, "transactions of other identities are not visible")
+
+	tracker.submitted("t1")
+	status, _, _ = tracker.get("User1", "t1")
+	require.Equal(t, StatusSubmitted, status.Status)
+
+	tracker.committed("t1", &fab.TxStatusEvent{ TxID: "t1", TxValidationCode: peer.TxValidationCode_VALID, BlockNumber: 12 })
+	status, _, _ = tracker.get("User1", "t1")
+	require.Equal(t, StatusCommitted, status.Status)
+	require.Equal(t, "VALID", status.ValidationCode)
+	require.Equal(t, uint64(12), status.BlockNumber)
+	require.NotNil(t, done)
+	<-done
+
+	tracker.add("User1", "t2", "BuyCar")
+	tracker.submitted("t2")
+	tracker.committed("t2", &fab.TxStatusEvent{ TxID: "t2", TxValidationCode: peer.TxValidationCode_MVCC_READ_CONFLICT, BlockNumber: 13 })
+	status, _, _ = tracker.get("User1", "t2")
+	require.Equal(t, StatusInvalid, status.Status)
+	require.Equal(t, "MVCC_READ_CONFLICT", status.ValidationCode)
+
+	tracker.add("User1", "t3", "BuyCar")
+	tracker.submitted("t3")
+	tracker.expired("t3")
+	status, _, _ = tracker.get("User1", "t3")
+	require.Equal(t, StatusSubmitted, status.Status)
+	require.Equal(t, "No commit event was received within 5m0s!", status.Error)
+}
+
+// getTransactionStatus serves GET target for User1.
+func getTransactionStatus(t *testing.T, target string) (*httptest.ResponseRecorder, TransactionStatus) {
+	w := httptest.NewRecorder()
+	newRouter(&authenticator{ defaultIdentity: "User1" }).ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	var status TransactionStatus
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	}
+
+	return w, status
+}
+
+func TestGetTransactionWait(t *testing.T) {
+	transactions.add("User1", "wait-t1", "ScrapCar")
+	transactions.submitted("wait-t1")
+
+	started := time.Now()
+	w, status := getTransactionStatus(t, "/transactions/wait-t1?wait=50ms")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, StatusSubmitted, status.Status)
+	require.True(t, time.Since(started) >= 50 * time.Millisecond, "answered before the wait was over")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		transactions.committed("wait-t1", &fab.TxStatusEvent{ TxID: "wait-t1", TxValidationCode: peer.TxValidationCode_VALID, BlockNumber: 5 })
+	}()
+
+	started = time.Now()
+	w, status = getTransactionStatus(t, "/transactions/wait-t1?wait=10s")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, StatusCommitted, status.Status)
+	require.True(t, time.Since(started) < 5 * time.Second, "did not answer on the commit")
+
+	w, _ = getTransactionStatus(t, "/transactions/wait-t1?wait=-1s")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = getTransactionStatus(t, "/transactions/wait-t2?wait=10s")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
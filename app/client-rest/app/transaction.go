@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/fabric-samples/client-submit"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// txIdHeader carries the id of each transaction a request submitted.
+const txIdHeader = "X-Transaction-ID"
+
+// submitPolicy submits a transaction again when it conflicted with a
+// concurrent one or its endorsing peers disagreed.
+var submitPolicy = clientsubmit.DefaultPolicy()
+
+// connectionFor returns the connection of the identity r runs under, with the
+// label of the identity.
+func connectionFor(w http.ResponseWriter, r *http.Request, action string) (string, *connection, bool) {
+	label := identityOf(r)
+
+	c, err := contracts.get(label)
+	if _, unknown := err.(unknownIdentityError); unknown {
+		writeError(w, http.StatusForbidden, CodeForbidden, fmt.Sprintf("Failed to %s: %s", action, err))
+		return "", nil, false
+	}
+	if err != nil {
+		writeTransactionError(w, action, err, "")
+		return "", nil, false
+	}
+
+	return label, c, true
+}
+
+// evaluate evaluates a transaction within the context of r and reports a
+// failure to w. action describes the request in the error message, e.g.
+// "get car".
+func evaluate(w http.ResponseWriter, r *http.Request, action string, name string, args ...string) ([]byte, bool) {
+	label, c, ok := connectionFor(w, r, action)
+	if !ok {
+		return nil, false
+	}
+
+	result, err := c.contract.evaluate(r.Context(), name, args...)
+	if err != nil {
+		failed(w, r, label, c, action, err, "")
+		return nil, false
+	}
+
+	return result, true
+}
+
+// decodeResult decodes the JSON result of a transaction into target. An empty
+// result, which the chaincode returns for a nil slice, leaves target as it is.
+// A result that does not decode is reported to w as a failure of the gateway.
+func decodeResult(w http.ResponseWriter, action string, result []byte, target interface{}) bool {
+	if len(result) == 0 {
+		return true
+	}
+
+	err := json.Unmarshal(result, target)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, CodeBadGateway, fmt.Sprintf("Failed to %s: %s", action, err))
+		return false
+	}
+
+	return true
+}
+
+// asyncRequested tells whether r asks for its transaction to be submitted
+// without waiting for the commit, with async=true.
+func asyncRequested(r *http.Request) bool {
+	return r.URL.Query().Get("async") == "true"
+}
+
+// submit submits a transaction within the context of r and reports a failure
+// to w. A transaction that conflicts with a concurrent one is submitted again
+// as a new transaction, up to the attempts of submitPolicy. The failure
+// carries the id of the last transaction once it reached the orderer. When r
+// is asynchronous, submit answers 202 itself and returns false, as there is
+// nothing left for the handler to answer.
+func submit(w http.ResponseWriter, r *http.Request, action string, name string, args ...string) ([]byte, bool) {
+	if asyncRequested(r) {
+		submitAsync(w, r, action, name, args...)
+		return nil, false
+	}
+
+	label, c, ok := connectionFor(w, r, action)
+	if !ok {
+		return nil, false
+	}
+
+	txId := ""
+	result, err := submitPolicy.Submit(r.Context(), func() ([]byte, error) {
+		result, event, err := c.contract.submit(r.Context(), name, args...)
+
+		txId = ""
+		if event != nil {
+			txId = event.TxID
+			transactions.record(label, name, event)
+			w.Header().Add(txIdHeader, txId)
+		}
+
+		return result, err
+	})
+	if err != nil {
+		failed(w, r, label, c, action, err, txId)
+		return nil, false
+	}
+
+	return result, true
+}
+
+// submitAsync sends a transaction to the orderer once it is endorsed and
+// answers 202 with its status, which GET /transactions/{txId} keeps
+// reporting until the transaction is committed or invalid.
+func submitAsync(w http.ResponseWriter, r *http.Request, action string, name string, args ...string) {
+	label, c, ok := connectionFor(w, r, action)
+	if !ok {
+		return
+	}
+
+	request := channel.Request{ ChaincodeID: contracts.config.Chaincode, Fcn: name }
+	for _, arg := range args {
+		request.Args = append(request.Args, []byte(arg))
+	}
+
+	handler := invoke.NewSelectAndEndorseHandler(
+		invoke.NewEndorsementValidationHandler(
+			invoke.NewSignatureValidationHandler(&asyncCommitHandler{ identity: label, function: name }),
+		),
+	)
+
+	var response channel.Response
+	_, err := submitPolicy.Submit(r.Context(), func() ([]byte, error) {
+		var err error
+		response, err = c.client.InvokeHandler(handler, request,
+			channel.WithParentContext(r.Context()),
+			channel.WithTimeout(fab.Execute, requestTimeout),
+			channel.WithRetry(retry.DefaultChannelOpts),
+		)
+		return nil, err
+	})
+	if err != nil {
+		failed(w, r, label, c, action, err, "")
+		return
+	}
+
+	txId := string(response.TransactionID)
+	status, _, _ := transactions.get(label, txId)
+
+	w.Header().Set(txIdHeader, txId)
+	w.Header().Set("Location", fmt.Sprintf("/transactions/%s", txId))
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// failed reports a failed transaction and drops the connection when the
+// gateway, rather than the chaincode or the request, failed.
+func failed(w http.ResponseWriter, r *http.Request, label string, c *connection, action string, err error, txId string) {
+	if r.Context().Err() == nil && classifyError(err).Code == CodeBadGateway {
+		contracts.reset(label, c)
+	}
+
+	writeTransactionError(w, action, err, txId)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/client-config"
+	"github.com/hyperledger/fabric-samples/client-submit"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeContract answers the transactions of the handlers under test. Its
+// submissions fail with the errors in order and succeed once they are used
+// up. A submission failing with an event server status, as an invalidated
+// transaction does, still has a commit event.
+type fakeContract struct {
+	results		map[string][]byte
+	evaluateErr	error
+	submitErrs	[]error
+	submitted	[]string
+}
+
+func (f *fakeContract) evaluate(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if f.evaluateErr != nil {
+		return nil, f.evaluateErr
+	}
+
+	return f.results[name], nil
+}
+
+func (f *fakeContract) submit(ctx context.Context, name string, args ...string) ([]byte, *fab.TxStatusEvent, error) {
+	f.submitted = append(f.submitted, name)
+	event := &fab.TxStatusEvent{ TxID: fmt.Sprintf("%s-tx%d", name, len(f.submitted)), TxValidationCode: peer.TxValidationCode_VALID, BlockNumber: 7 }
+
+	if len(f.submitErrs) == 0 {
+		return f.results[name], event, nil
+	}
+
+	err := f.submitErrs[0]
+	f.submitErrs = f.submitErrs[1:]
+
+	if s, ok := status.FromError(err); ok && s.Group == status.EventServerStatus {
+		event.TxValidationCode = peer.TxValidationCode(s.Code)
+		return nil, event, err
+	}
+
+	return nil, nil, err
+}
+
+// serveFake serves a request with the routes of the API running under User1,
+// whose connection has contract.
+func serveFake(t *testing.T, contract *fakeContract, method string, target string) *httptest.ResponseRecorder {
+	previousContracts, previousPolicy := contracts, submitPolicy
+	defer func() {
+		contracts, submitPolicy = previousContracts, previousPolicy
+	}()
+
+	contracts = &contractPool {
+		config: &clientconfig.Config{ Identity: "User1", Chaincode: "carcc" },
+		connections: map[string]*connection{ "User1": { contract: contract } },
+	}
+	submitPolicy = clientsubmit.Policy{ Attempts: 3 }
+
+	w := httptest.NewRecorder()
+	newRouter(&authenticator{ defaultIdentity: "User1" }).ServeHTTP(w, httptest.NewRequest(method, target, nil))
+
+	return w
+}
+
+func chaincodeError(message string) error {
+	return status.New(status.ChaincodeStatus, 500, message, nil)
+}
+
+func invalidated(code peer.TxValidationCode) error {
+	return status.New(status.EventServerStatus, int32(code), "received invalid transaction", nil)
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	return response
+}
+
+func TestSubmitErrorStatus(t *testing.T) {
+	tests := []struct {
+		name		string
+		errs		[]error
+		status		int
+		code		string
+		attempts	int
+		txId		string
+	}{
+		{ "committed", nil, http.StatusNoContent, "", 1, "WithdrawListing-tx1" },
+		{ "conflict retried", []error{ invalidated(peer.TxValidationCode_MVCC_READ_CONFLICT) }, http.StatusNoContent, "", 2, "WithdrawListing-tx2" },
+		{ "not found", []error{ chaincodeError("Car with id c9 does not exist!") }, http.StatusNotFound, CodeNotFound, 1, "" },
+		{ "forbidden", []error{ chaincodeError("Submitting client is not allowed to withdraw the listing!") }, http.StatusForbidden, CodeForbidden, 1, "" },
+		{ "bad request", []error{ chaincodeError("Asking price must be greater than zero!") }, http.StatusBadRequest, CodeBadRequest, 1, "" },
+		{ "business rule", []error{ chaincodeError("Car c1 is not listed for sale!") }, http.StatusConflict, CodeConflict, 1, "" },
+		{
+			"conflicts exhausted",
+			[]error{ invalidated(peer.TxValidationCode_MVCC_READ_CONFLICT), invalidated(peer.TxValidationCode_PHANTOM_READ_CONFLICT), invalidated(peer.TxValidationCode_MVCC_READ_CONFLICT) },
+			http.StatusConflict, CodeTransactionInvalid, 3, "WithdrawListing-tx3",
+		},
+		{ "endorsement policy", []error{ invalidated(peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE) }, http.StatusConflict, CodeTransactionInvalid, 1, "WithdrawListing-tx1" },
+		{ "timeout", []error{ status.New(status.ClientStatus, status.Timeout.ToInt32(), "Execute didn't receive block event", nil) }, http.StatusGatewayTimeout, CodeGatewayTimeout, 1, "" },
+		{ "gateway", []error{ errors.New("connection refused") }, http.StatusBadGateway, CodeBadGateway, 1, "" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &fakeContract{ submitErrs: test.errs }
+
+			w := serveFake(t, contract, http.MethodDelete, "/ledger/cars/c1/listing")
+
+			require.Equal(t, test.status, w.Code)
+			require.Len(t, contract.submitted, test.attempts)
+			if test.txId != "" {
+				require.Equal(t, test.txId, w.Header().Values(txIdHeader)[test.attempts - 1])
+			}
+			if test.code == "" {
+				return
+			}
+
+			response := decodeError(t, w)
+			require.Equal(t, test.code, response.Code)
+			require.Equal(t, test.attempts, response.Attempts)
+			require.Equal(t, test.txId, response.TxID)
+		})
+	}
+}
+
+func TestSubmitRecordsTransaction(t *testing.T) {
+	w := serveFake(t, &fakeContract{}, http.MethodDelete, "/ledger/cars/c1/listing")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	status, _, ok := transactions.get("User1", w.Header().Get(txIdHeader))
+	require.True(t, ok)
+	require.Equal(t, StatusCommitted, status.Status)
+	require.Equal(t, "WithdrawListing", status.Function)
+	require.Equal(t, uint64(7), status.BlockNumber)
+}
+
+func TestEvaluateErrorStatus(t *testing.T) {
+	contract := &fakeContract{ evaluateErr: chaincodeError("Car with id c9 does not exist!") }
+
+	w := serveFake(t, contract, http.MethodGet, "/ledger/cars/c9")
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	response := decodeError(t, w)
+	require.Equal(t, CodeNotFound, response.Code)
+	require.Equal(t, "Car with id c9 does not exist!", response.ChaincodeError)
+	require.Equal(t, "Failed to get car: Car with id c9 does not exist!", response.Message)
+}
+
+func TestGatewayFailureResetsConnection(t *testing.T) {
+	contract := &fakeContract{ evaluateErr: errors.New("connection refused") }
+
+	previous := contracts
+	defer func() {
+		contracts = previous
+	}()
+
+	contracts = &contractPool {
+		config: &clientconfig.Config{ Identity: "User1" },
+		connections: map[string]*connection{ "User1": { contract: contract } },
+	}
+	pool := contracts
+
+	w := httptest.NewRecorder()
+	newRouter(&authenticator{ defaultIdentity: "User1" }).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ledger/cars/c1", nil))
+
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	require.Empty(t, pool.connections)
+}
+
+func TestEvaluateResultNotJSON(t *testing.T) {
+	contract := &fakeContract{ results: map[string][]byte{ "GetCarsByColor": []byte("not json") } }
+
+	w := serveFake(t, contract, http.MethodGet, "/ledger/cars/colored/red")
+
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	require.Equal(t, CodeBadGateway, decodeError(t, w).Code)
+
+	w = serveFake(t, &fakeContract{}, http.MethodGet, "/ledger/cars/colored/red")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, "[]", w.Body.String())
+}
@@ -0,0 +1,9 @@
+module github.com/hyperledger/fabric-samples/client-submit
+
+go 1.14
+
+require (
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200707132912-fee30f3ccd23
+	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+	github.com/stretchr/testify v1.5.1
+)
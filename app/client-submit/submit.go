@@ -0,0 +1,205 @@
+// Package clientsubmit submits the transactions of the cars client
+// applications. It classifies why a transaction failed and submits it again
+// when the failure is transient: a read conflict with a concurrent
+// transaction, endorsing peers that disagreed or an endorsing peer that could
+// not be reached.
+package clientsubmit
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// Class is the kind of failure of a transaction.
+type Class string
+
+const (
+	// MVCCConflict is a transaction invalidated because a concurrent
+	// transaction changed a key it read.
+	MVCCConflict			Class = "MVCC_CONFLICT"
+	// PhantomRead is a transaction invalidated because a concurrent
+	// transaction changed the result of a range or rich query it ran.
+	PhantomRead				Class = "PHANTOM_READ"
+	// EndorsementMismatch is a transaction the endorsing peers simulated
+	// with different results, e.g. while one of them lagged behind.
+	EndorsementMismatch		Class = "ENDORSEMENT_MISMATCH"
+	// EndorsementUnavailable is a transaction an endorsing peer could not be
+	// reached for, e.g. while it restarted.
+	EndorsementUnavailable	Class = "ENDORSEMENT_UNAVAILABLE"
+	// Timeout is a transaction that did not complete in time. It may still
+	// be committed.
+	Timeout					Class = "TIMEOUT"
+	// BusinessError is a transaction the chaincode rejected, e.g. a buyer
+	// without enough money.
+	BusinessError			Class = "BUSINESS_ERROR"
+	// Invalid is a transaction invalidated for another reason, e.g. an
+	// endorsement policy failure.
+	Invalid					Class = "INVALID"
+	// Other is any other failure, e.g. of the gateway, the peers or the
+	// orderer.
+	Other					Class = "OTHER"
+)
+
+// Retryable tells whether a transaction that failed with c may succeed when
+// it is submitted again. Timeouts are not retried, as the transaction may
+// still be committed.
+func (c Class) Retryable() bool {
+	return c == MVCCConflict || c == PhantomRead || c == EndorsementMismatch || c == EndorsementUnavailable
+}
+
+// Failure is a classified transaction error. Message describes it: the
+// message of the chaincode for a business error, the validation code of an
+// invalidated transaction, or the error itself.
+type Failure struct {
+	Class			Class
+	Message			string
+	// ValidationCode is the code a committed transaction was invalidated
+	// with, e.g. MVCC_READ_CONFLICT.
+	ValidationCode	string
+}
+
+// Classify tells why a transaction failed.
+func Classify(err error) Failure {
+	statuses := fabricStatuses(err)
+
+	for _, s := range statuses {
+		if s.Group == status.ChaincodeStatus {
+			return Failure{ Class: BusinessError, Message: s.Message }
+		}
+	}
+
+	for _, s := range statuses {
+		switch {
+		case s.Group == status.EventServerStatus:
+			code := status.ToTransactionValidationCode(s.Code)
+			failure := Failure {
+				Class: Invalid,
+				Message: fmt.Sprintf("transaction was invalidated with %s", code),
+				ValidationCode: code.String(),
+			}
+
+			switch code {
+			case peer.TxValidationCode_MVCC_READ_CONFLICT:
+				failure.Class = MVCCConflict
+			case peer.TxValidationCode_PHANTOM_READ_CONFLICT:
+				failure.Class = PhantomRead
+			}
+			return failure
+		case s.Group == status.ClientStatus && s.Code == status.Timeout.ToInt32(),
+			s.Group == status.GRPCTransportStatus && status.ToGRPCStatusCode(s.Code).String() == "DeadlineExceeded":
+			return Failure{ Class: Timeout, Message: s.Message }
+		case s.Group == status.EndorserClientStatus && s.Code == status.EndorsementMismatch.ToInt32():
+			return Failure{ Class: EndorsementMismatch, Message: "endorsing peers returned different results" }
+		case s.Group == status.EndorserClientStatus && s.Code == status.ConnectionFailed.ToInt32(),
+			s.Group == status.GRPCTransportStatus && status.ToGRPCStatusCode(s.Code).String() == "Unavailable":
+			return Failure{ Class: EndorsementUnavailable, Message: s.Message }
+		}
+	}
+
+	if strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		return Failure{ Class: Timeout, Message: err.Error() }
+	}
+
+	return Failure{ Class: Other, Message: err.Error() }
+}
+
+// fabricStatuses flattens the statuses of an SDK error, which holds one
+// status per endorsing peer when several of them failed.
+func fabricStatuses(err error) []*status.Status {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	if s.Group != status.ClientStatus || s.Code != status.MultipleErrors.ToInt32() {
+		return []*status.Status{s}
+	}
+
+	statuses := []*status.Status{}
+	for _, detail := range s.Details {
+		if detailErr, isError := detail.(error); isError {
+			statuses = append(statuses, fabricStatuses(detailErr)...)
+		}
+	}
+
+	return statuses
+}
+
+// Error is a transaction that failed for good, with the classification of
+// its last failure and the number of times it was submitted.
+type Error struct {
+	Failure
+	Attempts	int
+	Err			error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Policy tells how often a transaction is submitted and how long to wait
+// between attempts. The wait doubles after every attempt, from Backoff up to
+// MaxBackoff, and a random half of it is left out so that conflicting clients
+// do not retry in step.
+type Policy struct {
+	Attempts	int
+	Backoff		time.Duration
+	MaxBackoff	time.Duration
+}
+
+// DefaultPolicy submits a transaction up to 5 times, waiting at most 1.5
+// seconds in total between the attempts.
+func DefaultPolicy() Policy {
+	return Policy{ Attempts: 5, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second }
+}
+
+// jitter returns a random number in [0, 1).
+var jitter = rand.Float64
+
+// Submit calls submit until it succeeds, fails with an error that is not
+// retryable, the attempts are used up or ctx is done. Every attempt must be a
+// new transaction. An error of Submit is an *Error.
+func (p Policy) Submit(ctx context.Context, submit func() ([]byte, error)) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := submit()
+		if err == nil {
+			return result, nil
+		}
+
+		failure := Classify(err)
+		if !failure.Class.Retryable() || attempt >= p.Attempts {
+			return nil, &Error{ Failure: failure, Attempts: attempt, Err: err }
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &Error{ Failure: failure, Attempts: attempt, Err: err }
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait after the attempt-th attempt.
+func (p Policy) backoff(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	return wait / 2 + time.Duration(jitter() * float64(wait / 2))
+}
@@ -0,0 +1,128 @@
+package clientsubmit
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/multi"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/stretchr/testify/require"
+)
+
+func invalidated(code peer.TxValidationCode) error {
+	return status.New(status.EventServerStatus, int32(code), "received invalid transaction", nil)
+}
+
+func TestClassify(t *testing.T) {
+	chaincodeErr := status.New(status.ChaincodeStatus, 500, "Car c1 is written off!", nil)
+	mismatch := status.New(status.EndorserClientStatus, status.EndorsementMismatch.ToInt32(), "ProposalResponsePayloads do not match", nil)
+	// 14 is the gRPC code Unavailable.
+	unavailable := status.New(status.GRPCTransportStatus, 14, "transport is closing", nil)
+
+	tests := []struct {
+		name	string
+		err		error
+		class	Class
+		code	string
+	}{
+		{ "mvcc", invalidated(peer.TxValidationCode_MVCC_READ_CONFLICT), MVCCConflict, "MVCC_READ_CONFLICT" },
+		{ "phantom", invalidated(peer.TxValidationCode_PHANTOM_READ_CONFLICT), PhantomRead, "PHANTOM_READ_CONFLICT" },
+		{ "invalid", invalidated(peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), Invalid, "ENDORSEMENT_POLICY_FAILURE" },
+		{ "mismatch", mismatch, EndorsementMismatch, "" },
+		{ "peer unreachable", status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil), EndorsementUnavailable, "" },
+		{ "peer unavailable", unavailable, EndorsementUnavailable, "" },
+		{ "commit timeout", status.New(status.ClientStatus, status.Timeout.ToInt32(), "Execute didn't receive block event", nil), Timeout, "" },
+		{ "deadline", errors.New("failed to send: " + context.DeadlineExceeded.Error()), Timeout, "" },
+		{ "business", chaincodeErr, BusinessError, "" },
+		{ "business of one peer", multi.New(mismatch, chaincodeErr), BusinessError, "" },
+		{ "other", errors.New("connection refused"), Other, "" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failure := Classify(test.err)
+			require.Equal(t, test.class, failure.Class)
+			require.Equal(t, test.code, failure.ValidationCode)
+		})
+	}
+
+	require.Equal(t, "Car c1 is written off!", Classify(chaincodeErr).Message)
+	require.True(t, Classify(unavailable).Class.Retryable())
+}
+
+func TestSubmitRetriesConflicts(t *testing.T) {
+	jitter = func() float64 { return 0 }
+	defer func() { jitter = rand.Float64 }()
+
+	attempts := 0
+	result, err := Policy{ Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond }.Submit(context.Background(), func() ([]byte, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, invalidated(peer.TxValidationCode_MVCC_READ_CONFLICT)
+		}
+		return []byte("ok"), nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(result))
+	require.Equal(t, 3, attempts)
+}
+
+func TestSubmitGivesUp(t *testing.T) {
+	attempts := 0
+	_, err := Policy{ Attempts: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond }.Submit(context.Background(), func() ([]byte, error) {
+		attempts++
+		return nil, invalidated(peer.TxValidationCode_PHANTOM_READ_CONFLICT)
+	})
+
+	var submitErr *Error
+	require.True(t, errors.As(err, &submitErr))
+	require.Equal(t, PhantomRead, submitErr.Class)
+	require.Equal(t, 2, submitErr.Attempts)
+	require.Equal(t, 2, attempts)
+	require.Contains(t, submitErr.Error(), "PHANTOM_READ_CONFLICT")
+}
+
+func TestSubmitDoesNotRetry(t *testing.T) {
+	for _, failure := range []error{
+		status.New(status.ChaincodeStatus, 500, "Person 2 cannot afford car c1!", nil),
+		status.New(status.ClientStatus, status.Timeout.ToInt32(), "Execute didn't receive block event", nil),
+	} {
+		attempts := 0
+		_, err := DefaultPolicy().Submit(context.Background(), func() ([]byte, error) {
+			attempts++
+			return nil, failure
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, attempts)
+		require.Equal(t, 1, err.(*Error).Attempts)
+	}
+}
+
+func TestSubmitStopsWhenDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	_, err := Policy{ Attempts: 5, Backoff: time.Hour, MaxBackoff: time.Hour }.Submit(ctx, func() ([]byte, error) {
+		attempts++
+		return nil, invalidated(peer.TxValidationCode_MVCC_READ_CONFLICT)
+	})
+	require.Equal(t, MVCCConflict, err.(*Error).Class)
+	require.Equal(t, 1, attempts)
+}
+
+func TestBackoff(t *testing.T) {
+	jitter = func() float64 { return 0.5 }
+	defer func() { jitter = rand.Float64 }()
+
+	p := Policy{ Backoff: 100 * time.Millisecond, MaxBackoff: time.Second }
+	require.Equal(t, 75 * time.Millisecond, p.backoff(1))
+	require.Equal(t, 150 * time.Millisecond, p.backoff(2))
+	require.Equal(t, 600 * time.Millisecond, p.backoff(4))
+	require.Equal(t, 750 * time.Millisecond, p.backoff(5))
+	require.Equal(t, 750 * time.Millisecond, p.backoff(9))
+}
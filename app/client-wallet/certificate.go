@@ -0,0 +1,107 @@
+package clientwallet
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// attributesOID is the certificate extension the Fabric CA stores enrollment
+// attributes in, such as the role the cars chaincode checks.
+var attributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// Details describes the certificate of a wallet identity.
+type Details struct {
+	Label		string
+	MSPID		string
+	Subject		string
+	Issuer		string
+	Serial		string
+	NotBefore	time.Time
+	NotAfter	time.Time
+	Attributes	map[string]string
+}
+
+// Expired tells whether the certificate is no longer valid at now.
+func (d Details) Expired(now time.Time) bool {
+	return now.After(d.NotAfter)
+}
+
+// Describe returns the certificate details of the identity under label.
+func Describe(wallet *gateway.Wallet, label string) (*Details, error) {
+	identity, err := Get(wallet, label)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := parseCertificate([]byte(identity.Certificate()))
+	if err != nil {
+		return nil, fmt.Errorf("identity %s: %w", label, err)
+	}
+
+	attributes, err := certificateAttributes(cert)
+	if err != nil {
+		return nil, fmt.Errorf("identity %s: %w", label, err)
+	}
+
+	return &Details {
+		Label: label,
+		MSPID: identity.MspID,
+		Subject: cert.Subject.String(),
+		Issuer: cert.Issuer.String(),
+		Serial: cert.SerialNumber.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter: cert.NotAfter,
+		Attributes: attributes,
+	}, nil
+}
+
+// List describes every identity of the wallet, sorted by label.
+func List(wallet *gateway.Wallet) ([]Details, error) {
+	labels, err := wallet.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(labels)
+
+	identities := []Details{}
+	for _, label := range labels {
+		details, err := Describe(wallet, label)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, *details)
+	}
+
+	return identities, nil
+}
+
+func certificateAttributes(cert *x509.Certificate) (map[string]string, error) {
+	attributes := map[string]string{}
+
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(attributesOID) {
+			continue
+		}
+
+		var value struct {
+			Attrs	map[string]string	`json:"attrs"`
+		}
+		err := json.Unmarshal(extension.Value, &value)
+		if err != nil {
+			return nil, fmt.Errorf("certificate attributes are not valid: %w", err)
+		}
+
+		for name, attribute := range value.Attrs {
+			attributes[name] = attribute
+		}
+	}
+
+	return attributes, nil
+}
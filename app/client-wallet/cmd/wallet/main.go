@@ -0,0 +1,152 @@
+// Command wallet manages the identities of the wallet the cars client
+// applications sign transactions with. The wallet and the MSP of imported
+// identities come from the client configuration, e.g.:
+//
+//	wallet -wallet ../../client-rest/app/wallet -msp-id Org1MSP import-msp mechanic path/to/msp
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/client-config"
+	"github.com/hyperledger/fabric-samples/client-wallet"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+const usage = `Usage: wallet [flags] <command>
+
+Commands:
+  list                                  list the identities with their role and expiry
+  show <label>                          show the certificate of an identity
+  import-msp <label> <msp-dir>          import the identity of an MSP directory
+  import-pem <label> <cert> <key>       import a PEM certificate and private key
+  export <label> <msp-dir>              write an identity as an MSP directory
+  remove <label>                        remove an identity
+
+Flags:
+`
+
+// command is a subcommand with the number of arguments it takes.
+type command struct {
+	args	int
+	run		func(wallet *gateway.Wallet, config *clientconfig.Config, args []string) error
+}
+
+var commands = map[string]command {
+	"list": { 0, list },
+	"show": { 1, show },
+	"import-msp": { 2, func(wallet *gateway.Wallet, config *clientconfig.Config, args []string) error {
+		return clientwallet.ImportMSP(wallet, args[0], config.MSPID, args[1])
+	} },
+	"import-pem": { 3, func(wallet *gateway.Wallet, config *clientconfig.Config, args []string) error {
+		return clientwallet.ImportPEM(wallet, args[0], config.MSPID, args[1], args[2])
+	} },
+	"export": { 2, func(wallet *gateway.Wallet, config *clientconfig.Config, args []string) error {
+		return clientwallet.Export(wallet, args[0], args[1])
+	} },
+	"remove": { 1, func(wallet *gateway.Wallet, config *clientconfig.Config, args []string) error {
+		return clientwallet.Remove(wallet, args[0])
+	} },
+}
+
+func main() {
+	flags := flag.NewFlagSet("wallet", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	config, args, err := clientconfig.Load(flags, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok || len(args) - 1 != cmd.args {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	wallet, err := gateway.NewFileSystemWallet(config.Wallet)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open wallet: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = cmd.run(wallet, config, args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+}
+
+func list(wallet *gateway.Wallet, config *clientconfig.Config, args []string) error {
+	identities, err := clientwallet.List(wallet)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "LABEL\tMSP\tROLE\tEXPIRES")
+	for _, identity := range identities {
+		role := identity.Attributes["role"]
+		if role == "" {
+			role = "-"
+		}
+
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", identity.Label, identity.MSPID, role, expiry(identity, now))
+	}
+
+	return out.Flush()
+}
+
+func show(wallet *gateway.Wallet, config *clientconfig.Config, args []string) error {
+	identity, err := clientwallet.Describe(wallet, args[0])
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range identity.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attributes := []string{}
+	for _, name := range names {
+		attributes = append(attributes, fmt.Sprintf("%s=%s", name, identity.Attributes[name]))
+	}
+
+	fmt.Printf("Label:       %s\n", identity.Label)
+	fmt.Printf("MSP:         %s\n", identity.MSPID)
+	fmt.Printf("Subject:     %s\n", identity.Subject)
+	fmt.Printf("Issuer:      %s\n", identity.Issuer)
+	fmt.Printf("Serial:      %s\n", identity.Serial)
+	fmt.Printf("Valid from:  %s\n", identity.NotBefore.Format(time.RFC3339))
+	fmt.Printf("Valid until: %s\n", expiry(*identity, time.Now()))
+	fmt.Printf("Attributes:  %s\n", strings.Join(attributes, ", "))
+
+	return nil
+}
+
+func expiry(identity clientwallet.Details, now time.Time) string {
+	notAfter := identity.NotAfter.Format(time.RFC3339)
+	if identity.Expired(now) {
+		return notAfter + " (expired)"
+	}
+
+	return notAfter
+}
@@ -0,0 +1,11 @@
+module github.com/hyperledger/fabric-samples/client-wallet
+
+go 1.14
+
+require (
+	github.com/hyperledger/fabric-samples/client-config v0.0.0
+	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+	github.com/stretchr/testify v1.5.1
+)
+
+replace github.com/hyperledger/fabric-samples/client-config => ../client-config
@@ -0,0 +1,203 @@
+// Package clientwallet manages the X.509 identities of a file system wallet
+// for the cars client applications: importing them from an MSP directory or
+// a PEM certificate and key, exporting them as an MSP directory and
+// describing their certificates.
+package clientwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// ImportMSP imports the signing certificate of an MSP directory under label,
+// together with the keystore key that belongs to it.
+func ImportMSP(wallet *gateway.Wallet, label string, mspID string, mspDir string) error {
+	certPath, err := signCertPath(filepath.Join(mspDir, "signcerts"))
+	if err != nil {
+		return err
+	}
+
+	certPEM, err := ioutil.ReadFile(filepath.Clean(certPath))
+	if err != nil {
+		return err
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return fmt.Errorf("%s: %w", certPath, err)
+	}
+
+	keyDir := filepath.Join(mspDir, "keystore")
+	files, err := ioutil.ReadDir(keyDir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		keyPEM, err := ioutil.ReadFile(filepath.Clean(filepath.Join(keyDir, file.Name())))
+		if err != nil {
+			return err
+		}
+
+		if keyMatches(cert, keyPEM) == nil {
+			return wallet.Put(label, gateway.NewX509Identity(mspID, string(certPEM), string(keyPEM)))
+		}
+	}
+
+	return fmt.Errorf("keystore %s has no key for the certificate %s", keyDir, certPath)
+}
+
+// ImportPEM imports a certificate and its private key under label.
+func ImportPEM(wallet *gateway.Wallet, label string, mspID string, certPath string, keyPath string) error {
+	certPEM, err := ioutil.ReadFile(filepath.Clean(certPath))
+	if err != nil {
+		return err
+	}
+
+	keyPEM, err := ioutil.ReadFile(filepath.Clean(keyPath))
+	if err != nil {
+		return err
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return fmt.Errorf("%s: %w", certPath, err)
+	}
+
+	err = keyMatches(cert, keyPEM)
+	if err != nil {
+		return fmt.Errorf("%s: %w", keyPath, err)
+	}
+
+	return wallet.Put(label, gateway.NewX509Identity(mspID, string(certPEM), string(keyPEM)))
+}
+
+// Export writes the identity under label as an MSP directory, with the
+// certificate in signcerts/cert.pem and the key in keystore/priv_sk. The key
+// is only readable by the owner.
+func Export(wallet *gateway.Wallet, label string, mspDir string) error {
+	identity, err := Get(wallet, label)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range []struct {
+		dir		string
+		name	string
+		content	string
+		mode	os.FileMode
+	}{
+		{ "signcerts", "cert.pem", identity.Certificate(), 0644 },
+		{ "keystore", "priv_sk", identity.Key(), 0600 },
+	} {
+		dir := filepath.Join(mspDir, file.dir)
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, file.name), []byte(file.content), file.mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns the X.509 identity under label.
+func Get(wallet *gateway.Wallet, label string) (*gateway.X509Identity, error) {
+	if !wallet.Exists(label) {
+		return nil, fmt.Errorf("identity %s is not in the wallet", label)
+	}
+
+	identity, err := wallet.Get(label)
+	if err != nil {
+		return nil, err
+	}
+
+	x509Identity, ok := identity.(*gateway.X509Identity)
+	if !ok {
+		return nil, fmt.Errorf("identity %s is not an X.509 identity", label)
+	}
+
+	return x509Identity, nil
+}
+
+// Remove removes the identity under label.
+func Remove(wallet *gateway.Wallet, label string) error {
+	if !wallet.Exists(label) {
+		return fmt.Errorf("identity %s is not in the wallet", label)
+	}
+
+	return wallet.Remove(label)
+}
+
+// signCertPath returns signcerts/cert.pem as written by the Fabric CA client,
+// or else the only file of signcerts as written by cryptogen.
+func signCertPath(dir string) (string, error) {
+	path := filepath.Join(dir, "cert.pem")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) != 1 || files[0].IsDir() {
+		return "", fmt.Errorf("%s must contain cert.pem or a single certificate", dir)
+	}
+
+	return filepath.Join(dir, files[0].Name()), nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("not a PEM certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// keyMatches checks that keyPEM is the private key of cert.
+func keyMatches(cert *x509.Certificate, keyPEM []byte) error {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return errors.New("not a PEM private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return errors.New("not a PKCS #8 or EC private key")
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return errors.New("not an ECDSA private key")
+	}
+
+	certKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || certKey.X.Cmp(ecKey.X) != 0 || certKey.Y.Cmp(ecKey.Y) != 0 {
+		return errors.New("private key does not belong to the certificate")
+	}
+
+	return nil
+}
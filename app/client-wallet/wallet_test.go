@@ -0,0 +1,170 @@
+package clientwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/stretchr/testify/require"
+)
+
+var notAfter = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "clientwallet")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+func newWallet(t *testing.T) *gateway.Wallet {
+	wallet, err := gateway.NewFileSystemWallet(filepath.Join(tempDir(t), "wallet"))
+	require.NoError(t, err)
+
+	return wallet
+}
+
+// newCredentials returns a certificate with the role attribute of the Fabric
+// CA and its PKCS #8 key, both PEM encoded.
+func newCredentials(t *testing.T, name string, role string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate {
+		SerialNumber: big.NewInt(42),
+		Subject: pkix.Name{ CommonName: name, OrganizationalUnit: []string{"client"} },
+		NotBefore: notAfter.AddDate(-1, 0, 0),
+		NotAfter: notAfter,
+	}
+	if role != "" {
+		template.ExtraExtensions = []pkix.Extension{
+			{ Id: attributesOID, Value: []byte(`{"attrs":{"role":"` + role + `","hf.EnrollmentID":"` + name + `"}}`) },
+		}
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{ Type: "CERTIFICATE", Bytes: cert }),
+		pem.EncodeToMemory(&pem.Block{ Type: "PRIVATE KEY", Bytes: keyBytes })
+}
+
+// writeMSP writes an MSP directory the way cryptogen does, with keys of other
+// identities next to the matching one.
+func writeMSP(t *testing.T, certPEM []byte, keys ...[]byte) string {
+	dir := tempDir(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "signcerts"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "keystore"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "signcerts", "User1@org1.example.com-cert.pem"), certPEM, 0644))
+
+	for i, key := range keys {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "keystore", string(rune('a' + i)) + "_sk"), key, 0600))
+	}
+
+	return dir
+}
+
+func TestImportMSP(t *testing.T) {
+	wallet := newWallet(t)
+	certPEM, keyPEM := newCredentials(t, "mechanic1", "mechanic")
+	_, otherKey := newCredentials(t, "other", "")
+
+	err := ImportMSP(wallet, "mechanic", "Org1MSP", writeMSP(t, certPEM, otherKey, keyPEM))
+	require.NoError(t, err)
+
+	identity, err := Get(wallet, "mechanic")
+	require.NoError(t, err)
+	require.Equal(t, "Org1MSP", identity.MspID)
+	require.Equal(t, string(certPEM), identity.Certificate())
+	require.Equal(t, string(keyPEM), identity.Key())
+
+	err = ImportMSP(wallet, "nokey", "Org1MSP", writeMSP(t, certPEM, otherKey))
+	require.Error(t, err)
+	require.False(t, wallet.Exists("nokey"))
+}
+
+func TestImportPEMAndExport(t *testing.T) {
+	wallet := newWallet(t)
+	certPEM, keyPEM := newCredentials(t, "dealer1", "dealer")
+	_, otherKey := newCredentials(t, "other", "")
+
+	dir := tempDir(t)
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	otherKeyPath := filepath.Join(dir, "other.pem")
+	require.NoError(t, ioutil.WriteFile(certPath, certPEM, 0644))
+	require.NoError(t, ioutil.WriteFile(keyPath, keyPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(otherKeyPath, otherKey, 0600))
+
+	err := ImportPEM(wallet, "dealer", "Org4MSP", certPath, otherKeyPath)
+	require.EqualError(t, err, otherKeyPath + ": private key does not belong to the certificate")
+
+	err = ImportPEM(wallet, "dealer", "Org4MSP", keyPath, keyPath)
+	require.EqualError(t, err, keyPath + ": not a PEM certificate")
+
+	require.NoError(t, ImportPEM(wallet, "dealer", "Org4MSP", certPath, keyPath))
+
+	mspDir := filepath.Join(tempDir(t), "msp")
+	require.NoError(t, Export(wallet, "dealer", mspDir))
+
+	info, err := os.Stat(filepath.Join(mspDir, "keystore", "priv_sk"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(t, ImportMSP(wallet, "copy", "Org4MSP", mspDir))
+	identity, err := Get(wallet, "copy")
+	require.NoError(t, err)
+	require.Equal(t, string(keyPEM), identity.Key())
+
+	require.EqualError(t, Export(wallet, "missing", mspDir), "identity missing is not in the wallet")
+}
+
+func TestListDescribeAndRemove(t *testing.T) {
+	wallet := newWallet(t)
+	for _, identity := range []struct {
+		label	string
+		role	string
+	}{
+		{ "registry", "registry" },
+		{ "appUser", "" },
+	} {
+		certPEM, keyPEM := newCredentials(t, identity.label, identity.role)
+		require.NoError(t, wallet.Put(identity.label, gateway.NewX509Identity("Org4MSP", string(certPEM), string(keyPEM))))
+	}
+
+	identities, err := List(wallet)
+	require.NoError(t, err)
+	require.Len(t, identities, 2)
+	require.Equal(t, "appUser", identities[0].Label)
+	require.Empty(t, identities[0].Attributes)
+
+	registry := identities[1]
+	require.Equal(t, "registry", registry.Label)
+	require.Equal(t, "Org4MSP", registry.MSPID)
+	require.Equal(t, "CN=registry,OU=client", registry.Subject)
+	require.Equal(t, "42", registry.Serial)
+	require.Equal(t, map[string]string{"role": "registry", "hf.EnrollmentID": "registry"}, registry.Attributes)
+	require.True(t, registry.NotAfter.Equal(notAfter))
+	require.False(t, registry.Expired(notAfter))
+	require.True(t, registry.Expired(notAfter.Add(time.Second)))
+
+	require.NoError(t, Remove(wallet, "registry"))
+	require.EqualError(t, Remove(wallet, "registry"), "identity registry is not in the wallet")
+
+	_, err = Describe(wallet, "registry")
+	require.Error(t, err)
+}